@@ -0,0 +1,402 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// accountsFile 账号池的持久化文件名
+const accountsFile = "accounts.json"
+
+// 账号选择策略
+const (
+	AccountRoundRobin   = "round_robin"   // 轮流使用
+	AccountLeastFlooded = "least_flooded" // 优先使用最久没有触发限流的账号
+	AccountPinned       = "pinned"        // 按 UserAccounts 固定账号，固定账号不可用时轮流使用其他账号
+)
+
+// 账号暂停原因
+const (
+	cooldownFlood    = "flood"
+	cooldownFailures = "failures"
+	cooldownAuth     = "auth"
+)
+
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+var (
+	errAccountExists   = newError("err.accounts.exists")
+	errAccountNotFound = newError("err.accounts.not_found")
+	errAccountInvalid  = newError("err.accounts.invalid")
+	errAccountLast     = newError("err.accounts.last")
+)
+
+// tdlAccount 一个 Telegram 用户账号，对应 tdl 的一个命名空间 (-n)
+type tdlAccount struct {
+	Name           string    `json:"name"`
+	AddedAt        time.Time `json:"added_at"`
+	LastUsed       time.Time `json:"last_used,omitempty"`
+	LastFlood      time.Time `json:"last_flood,omitempty"`
+	CooldownUntil  time.Time `json:"cooldown_until,omitempty"`
+	CooldownReason string    `json:"cooldown_reason,omitempty"`
+	Failures       int       `json:"failures,omitempty"` // 连续失败次数
+	Tasks          int       `json:"tasks"`
+	Floods         int       `json:"floods"`
+}
+
+// available 账号当前是否可用
+func (a *tdlAccount) available(now time.Time) bool {
+	return !now.Before(a.CooldownUntil)
+}
+
+// accountPool 管理多个用户账号，为每个任务选择账号并记录健康状态
+type accountPool struct {
+	mu       sync.Mutex
+	accounts []*tdlAccount
+	next     int // 轮流使用的下一个位置
+}
+
+func newAccountPool() *accountPool {
+	return &accountPool{}
+}
+
+// load 加载账号池，首次启动时使用 TDLAccounts
+func (p *accountPool) load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	found, err := loadJSONFile(accountsFile, &p.accounts)
+	if err != nil || found && len(p.accounts) > 0 {
+		return err
+	}
+	now := time.Now()
+	for _, name := range TDLAccounts {
+		p.accounts = append(p.accounts, &tdlAccount{Name: name, AddedAt: now})
+	}
+	return p.saveLocked()
+}
+
+func (p *accountPool) saveLocked() error {
+	return saveJSONFile(accountsFile, p.accounts)
+}
+
+func (p *accountPool) findLocked(name string) *tdlAccount {
+	for _, a := range p.accounts {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// Pick 按 AccountStrategy 为用户的任务选择一个可用账号。
+// 所有账号都在暂停中时返回空名称和最早恢复的时间
+func (p *accountPool) Pick(userID int64, now time.Time) (string, time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var chosen *tdlAccount
+	if AccountStrategy == AccountPinned {
+		if a := p.findLocked(UserAccounts[userID]); a != nil && a.available(now) {
+			chosen = a
+		}
+	}
+	if chosen == nil && AccountStrategy == AccountLeastFlooded {
+		for _, a := range p.accounts {
+			if !a.available(now) {
+				continue
+			}
+			if chosen == nil || a.LastFlood.Before(chosen.LastFlood) ||
+				a.LastFlood.Equal(chosen.LastFlood) && a.LastUsed.Before(chosen.LastUsed) {
+				chosen = a
+			}
+		}
+	}
+	if chosen == nil {
+		for i := range p.accounts {
+			idx := (p.next + i) % len(p.accounts)
+			if p.accounts[idx].available(now) {
+				chosen = p.accounts[idx]
+				p.next = idx + 1
+				break
+			}
+		}
+	}
+
+	if chosen == nil {
+		var ready time.Time
+		for _, a := range p.accounts {
+			if ready.IsZero() || a.CooldownUntil.Before(ready) {
+				ready = a.CooldownUntil
+			}
+		}
+		return "", ready
+	}
+	chosen.LastUsed = now
+	chosen.Tasks++
+	p.saveLocked()
+	return chosen.Name, time.Time{}
+}
+
+// Flood 记录账号触发 FLOOD_WAIT，在等待时间内不再分配任务
+func (p *accountPool) Flood(name string, wait time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a := p.findLocked(name)
+	if a == nil {
+		return nil
+	}
+	now := time.Now()
+	a.LastFlood = now
+	a.Floods++
+	if until := now.Add(wait); until.After(a.CooldownUntil) {
+		a.CooldownUntil = until
+		a.CooldownReason = cooldownFlood
+	}
+	return p.saveLocked()
+}
+
+// Failure 记录一次失败。auth 为 true 表示会话失效或账号被封禁，立即暂停 AccountAuthCooldown；
+// 否则连续失败 AccountMaxFailures 次后暂停 AccountFailureCooldown
+func (p *accountPool) Failure(name string, auth bool) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a := p.findLocked(name)
+	if a == nil {
+		return nil
+	}
+	now := time.Now()
+	switch {
+	case auth:
+		a.Failures = 0
+		a.CooldownUntil = now.Add(AccountAuthCooldown)
+		a.CooldownReason = cooldownAuth
+	case AccountMaxFailures > 0:
+		a.Failures++
+		if a.Failures >= AccountMaxFailures {
+			a.Failures = 0
+			a.CooldownUntil = now.Add(AccountFailureCooldown)
+			a.CooldownReason = cooldownFailures
+		}
+	}
+	return p.saveLocked()
+}
+
+// Success 任务成功后清除连续失败计数
+func (p *accountPool) Success(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a := p.findLocked(name)
+	if a == nil || a.Failures == 0 {
+		return nil
+	}
+	a.Failures = 0
+	return p.saveLocked()
+}
+
+// Add 添加账号
+func (p *accountPool) Add(name string) error {
+	if !accountNamePattern.MatchString(name) {
+		return errAccountInvalid
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.findLocked(name) != nil {
+		return errAccountExists
+	}
+	p.accounts = append(p.accounts, &tdlAccount{Name: name, AddedAt: time.Now()})
+	return p.saveLocked()
+}
+
+// Remove 删除账号 (tdl 中的会话数据保留)
+func (p *accountPool) Remove(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, a := range p.accounts {
+		if a.Name != name {
+			continue
+		}
+		if len(p.accounts) == 1 {
+			return errAccountLast
+		}
+		p.accounts = append(p.accounts[:i], p.accounts[i+1:]...)
+		return p.saveLocked()
+	}
+	return errAccountNotFound
+}
+
+// Reset 清除账号的暂停状态 (如重新登录之后)
+func (p *accountPool) Reset(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a := p.findLocked(name)
+	if a == nil {
+		return errAccountNotFound
+	}
+	a.CooldownUntil = time.Time{}
+	a.CooldownReason = ""
+	a.Failures = 0
+	return p.saveLocked()
+}
+
+// Restore 会话检查通过后解除因会话失效导致的暂停，其他原因的暂停保持不变
+func (p *accountPool) Restore(name string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a := p.findLocked(name)
+	if a == nil || a.CooldownReason != cooldownAuth {
+		return nil
+	}
+	a.CooldownUntil = time.Time{}
+	a.CooldownReason = ""
+	return p.saveLocked()
+}
+
+// Names 全部账号名
+func (p *accountPool) Names() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, len(p.accounts))
+	for i, a := range p.accounts {
+		names[i] = a.Name
+	}
+	return names
+}
+
+// Status 每个账号一行的状态
+func (p *accountPool) Status(lang string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	var lines []string
+	for _, a := range p.accounts {
+		state := T(lang, "accounts.available")
+		if !a.available(now) {
+			state = T(lang, "accounts.cooldown", "until", a.CooldownUntil.Format("01-02 15:04"), "reason", T(lang, "accounts.reason."+a.CooldownReason))
+		}
+		lines = append(lines, T(lang, "accounts.line", "name", a.Name, "state", state, "tasks", a.Tasks, "floods", a.Floods))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ==================== tdl 输出识别 ====================
+
+var floodWaitPattern = regexp.MustCompile(`(?i)FLOOD_(?:PREMIUM_)?WAIT[_ ]\(?(\d+)\)?`)
+
+// parseFloodWait 从 tdl 输出中识别 FLOOD_WAIT 及需要等待的时间
+func parseFloodWait(line string) (time.Duration, bool) {
+	m := floodWaitPattern.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	seconds, _ := strconv.Atoi(m[1])
+	return time.Duration(seconds) * time.Second, true
+}
+
+// accountAuthError tdl 输出是否表示会话失效或账号被封禁
+func accountAuthError(line string) bool {
+	upper := strings.ToUpper(line)
+	for _, s := range []string{"AUTH_KEY_UNREGISTERED", "AUTH_KEY_DUPLICATED", "SESSION_REVOKED", "USER_DEACTIVATED", "PHONE_NUMBER_BANNED"} {
+		if strings.Contains(upper, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== Bot 集成 ====================
+
+// waitForAccount 为任务选择账号；所有账号都在暂停中时等待最早恢复的账号。
+// onWait 在开始等待时调用，用于更新状态消息。ctx 结束时返回 false
+func (b *Bot) waitForAccount(ctx context.Context, userID int64, onWait func(until time.Time)) (string, bool) {
+	for {
+		name, ready := b.accounts.Pick(userID, time.Now())
+		if name != "" {
+			return name, true
+		}
+		onWait(ready)
+		select {
+		case <-ctx.Done():
+			return "", false
+		case <-time.After(time.Until(ready)):
+		}
+	}
+}
+
+// handleAccounts 处理 /accounts [add|remove|reset] [账号名] 命令
+func (b *Bot) handleAccounts(c *commandContext) {
+	lang := c.Lang
+	if len(c.Args) == 0 {
+		b.replyText(c.Message, T(lang, "accounts.list", "strategy", AccountStrategy, "accounts", b.accounts.Status(lang)))
+		return
+	}
+	if len(c.Args) < 2 {
+		b.replyText(c.Message, T(lang, "cmd.usage", "usage", c.Command.usage(lang)))
+		return
+	}
+
+	action, name := strings.ToLower(c.Args[0]), c.Args[1]
+	var err error
+	switch action {
+	case "add":
+		err = b.accounts.Add(name)
+	case "remove":
+		if t := b.taskManager.GetCurrentTask(); t != nil && t.Account == name {
+			b.replyText(c.Message, T(lang, "accounts.in_use", "name", name))
+			return
+		}
+		err = b.accounts.Remove(name)
+	case "reset":
+		err = b.accounts.Reset(name)
+	}
+	if err != nil {
+		b.logger.Warn("修改账号池失败", "operator_id", c.Message.From.ID, "action", action, "account", name, "error", err)
+		b.replyText(c.Message, T(lang, "accounts.failed", "error", errorText(lang, err)))
+		return
+	}
+	b.logger.Info("账号池已修改", "operator_id", c.Message.From.ID, "action", action, "account", name)
+	b.replyText(c.Message, T(lang, "accounts.done_"+action, "name", name)+"\n\n"+b.accounts.Status(lang))
+	// 重新登录后立即检查，无需等待下一轮
+	if action != "remove" && SessionCheckInterval > 0 {
+		go b.checkSessions()
+	}
+}
+
+// markAccountTask 根据任务结果更新账号健康状态
+func (b *Bot) markAccountTask(task *Task, failed, authFailed bool) {
+	var err error
+	switch {
+	case authFailed:
+		err = b.accounts.Failure(task.Account, true)
+		b.notifyAccountCooldown(task.Account, cooldownAuth)
+	case failed:
+		err = b.accounts.Failure(task.Account, false)
+	default:
+		err = b.accounts.Success(task.Account)
+	}
+	if err != nil {
+		b.logger.Error("保存账号状态失败", "account", task.Account, "error", err)
+	}
+}
+
+// notifyAccountCooldown 账号被暂停时通知管理员
+func (b *Bot) notifyAccountCooldown(name, reason string) {
+	for adminID, ok := range AdminUsers {
+		if !ok {
+			continue
+		}
+		lang := b.langOf(adminID)
+		text := T(lang, "accounts.admin_alert", "name", name, "reason", T(lang, "accounts.reason."+reason))
+		if _, err := b.api.Send(tgbotapi.NewMessage(adminID, text)); err != nil {
+			b.logger.Warn("通知管理员失败", "admin_id", adminID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFloodWait(t *testing.T) {
+	tests := []struct {
+		line string
+		want time.Duration
+		ok   bool
+	}{
+		{"rpc error code 420: FLOOD_WAIT_30", 30 * time.Second, true},
+		{"FLOOD_PREMIUM_WAIT_5", 5 * time.Second, true},
+		{"flood_wait_120 (caused by messages.forwardMessages)", 2 * time.Minute, true},
+		{"FLOOD_WAIT (45)", 45 * time.Second, true},
+		{"FLOOD_WAIT_0", 0, true},
+		{"FLOOD_WAIT", 0, false},
+		{"PEER_FLOOD", 0, false},
+		{"done", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseFloodWait(tt.line)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseFloodWait(%q) = %v, %v, want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// newTestAccountPool 创建包含指定账号的账号池，持久化到临时目录
+func newTestAccountPool(t *testing.T, names ...string) *accountPool {
+	t.Helper()
+	savedDir := BotDataDir
+	BotDataDir = t.TempDir()
+	t.Cleanup(func() { BotDataDir = savedDir })
+	p := newAccountPool()
+	for _, name := range names {
+		p.accounts = append(p.accounts, &tdlAccount{Name: name})
+	}
+	return p
+}
+
+func TestAccountPoolPick(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	tests := []struct {
+		name     string
+		strategy string
+		setup    func(p *accountPool)
+		userID   int64
+		want     []string // 依次选择的账号
+	}{
+		{name: "round robin", strategy: AccountRoundRobin, want: []string{"a", "b", "c", "a"}},
+		{
+			name:     "round robin skips cooldown",
+			strategy: AccountRoundRobin,
+			setup:    func(p *accountPool) { p.accounts[1].CooldownUntil = later },
+			want:     []string{"a", "c", "a"},
+		},
+		{
+			name:     "least flooded",
+			strategy: AccountLeastFlooded,
+			setup: func(p *accountPool) {
+				p.accounts[0].LastFlood = now.Add(-time.Minute)
+				p.accounts[2].LastFlood = now.Add(-2 * time.Hour)
+			},
+			// b 从未触发限流，之后 b 的 LastUsed 更新，但仍然是最久没有限流的账号
+			want: []string{"b", "b"},
+		},
+		{
+			name:     "least flooded ties by last use",
+			strategy: AccountLeastFlooded,
+			want:     []string{"a", "b", "c", "a"},
+		},
+		{
+			name:     "least flooded skips cooldown",
+			strategy: AccountLeastFlooded,
+			setup: func(p *accountPool) {
+				p.accounts[0].LastFlood = now.Add(-time.Minute)
+				p.accounts[1].CooldownUntil = later
+				p.accounts[2].LastFlood = now.Add(-2 * time.Hour)
+			},
+			want: []string{"c", "c"},
+		},
+		{name: "pinned", strategy: AccountPinned, userID: 1, want: []string{"b", "b"}},
+		{
+			name:     "pinned unavailable falls back",
+			strategy: AccountPinned,
+			userID:   1,
+			setup:    func(p *accountPool) { p.accounts[1].CooldownUntil = later },
+			want:     []string{"a", "c"},
+		},
+		{name: "pinned account missing", strategy: AccountPinned, userID: 2, want: []string{"a", "b"}},
+		{name: "not pinned", strategy: AccountPinned, userID: 3, want: []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			savedStrategy, savedUsers := AccountStrategy, UserAccounts
+			defer func() { AccountStrategy, UserAccounts = savedStrategy, savedUsers }()
+			AccountStrategy = tt.strategy
+			UserAccounts = map[int64]string{1: "b", 2: "gone"}
+
+			p := newTestAccountPool(t, "a", "b", "c")
+			if tt.setup != nil {
+				tt.setup(p)
+			}
+			var got []string
+			for i := range tt.want {
+				name, _ := p.Pick(tt.userID, now.Add(time.Duration(i)*time.Second))
+				got = append(got, name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("picked %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAccountPoolPickAllCoolingDown(t *testing.T) {
+	now := time.Now()
+	p := newTestAccountPool(t, "a", "b")
+	p.accounts[0].CooldownUntil = now.Add(2 * time.Hour)
+	p.accounts[1].CooldownUntil = now.Add(time.Hour)
+	name, ready := p.Pick(0, now)
+	if name != "" || !ready.Equal(p.accounts[1].CooldownUntil) {
+		t.Errorf("Pick = %q, %v, want no account ready at %v", name, ready, p.accounts[1].CooldownUntil)
+	}
+}
+
+func TestAccountPoolFlood(t *testing.T) {
+	p := newTestAccountPool(t, "a")
+	if err := p.Flood("a", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	a := p.accounts[0]
+	until := a.CooldownUntil
+	if a.available(time.Now()) || a.CooldownReason != cooldownFlood || a.Floods != 1 {
+		t.Fatalf("after Flood: %+v", a)
+	}
+	// 较短的等待不会缩短暂停时间
+	if err := p.Flood("a", time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if !a.CooldownUntil.Equal(until) || a.Floods != 2 {
+		t.Errorf("shorter flood changed cooldown: %+v", a)
+	}
+	if err := p.Flood("missing", time.Hour); err != nil {
+		t.Errorf("Flood(missing) = %v", err)
+	}
+}
+
+func TestAccountPoolFailure(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxFailures int
+		failures    int
+		auth        bool
+		reason      string // 空表示仍可用
+	}{
+		{name: "below threshold", maxFailures: 3, failures: 2},
+		{name: "threshold reached", maxFailures: 3, failures: 3, reason: cooldownFailures},
+		{name: "threshold disabled", maxFailures: 0, failures: 10},
+		{name: "auth failure", maxFailures: 3, failures: 1, auth: true, reason: cooldownAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := AccountMaxFailures
+			AccountMaxFailures = tt.maxFailures
+			defer func() { AccountMaxFailures = saved }()
+
+			p := newTestAccountPool(t, "a")
+			for i := 0; i < tt.failures; i++ {
+				if err := p.Failure("a", tt.auth); err != nil {
+					t.Fatal(err)
+				}
+			}
+			a := p.accounts[0]
+			if available := a.available(time.Now()); available != (tt.reason == "") || a.CooldownReason != tt.reason {
+				t.Errorf("available = %v, reason = %q, want reason %q", available, a.CooldownReason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestAccountPoolSuccessResetsFailures(t *testing.T) {
+	saved := AccountMaxFailures
+	AccountMaxFailures = 2
+	defer func() { AccountMaxFailures = saved }()
+
+	p := newTestAccountPool(t, "a")
+	p.Failure("a", false)
+	p.Success("a")
+	p.Failure("a", false)
+	if !p.accounts[0].available(time.Now()) {
+		t.Error("failures not reset by Success")
+	}
+}
+
+func TestAccountPoolRestore(t *testing.T) {
+	tests := []struct {
+		reason    string
+		available bool
+	}{
+		{cooldownAuth, true},
+		{cooldownFlood, false},
+		{cooldownFailures, false},
+	}
+	for _, tt := range tests {
+		p := newTestAccountPool(t, "a")
+		p.accounts[0].CooldownUntil = time.Now().Add(time.Hour)
+		p.accounts[0].CooldownReason = tt.reason
+		if err := p.Restore("a"); err != nil {
+			t.Fatal(err)
+		}
+		if got := p.accounts[0].available(time.Now()); got != tt.available {
+			t.Errorf("Restore with %s cooldown: available = %v, want %v", tt.reason, got, tt.available)
+		}
+	}
+}
+
+func TestAccountPoolRemove(t *testing.T) {
+	p := newTestAccountPool(t, "a", "b")
+	if err := p.Remove("missing"); !errors.Is(err, errAccountNotFound) {
+		t.Errorf("Remove(missing) = %v, want errAccountNotFound", err)
+	}
+	if err := p.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Remove("b"); !errors.Is(err, errAccountLast) {
+		t.Errorf("Remove(last) = %v, want errAccountLast", err)
+	}
+	if got := p.Names(); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("Names = %v, want [b]", got)
+	}
+}
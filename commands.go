@@ -0,0 +1,363 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// commandAccess 命令所需的角色
+type commandAccess int
+
+const (
+	CommandPublic commandAccess = iota // 所有人
+	CommandUser                        // 白名单用户 (未配置白名单时为所有人)
+	CommandAdmin                       // 管理员
+)
+
+// commandArg 命令参数定义
+type commandArg struct {
+	Name     string   // 参数名，显示名称取自消息目录 arg.<Name>
+	Required bool     // 是否必填
+	Choices  []string // 可选值 (不区分大小写)，为空表示任意值
+	Rest     bool     // 是否包含剩余的全部文本 (只能是最后一个参数)
+}
+
+// botCommand 已注册的命令。描述取自消息目录 cmd.desc.<Name>
+type botCommand struct {
+	Name    string
+	Access  commandAccess
+	Args    []commandArg
+	Handler commandHandler
+}
+
+// commandContext 一次命令调用
+type commandContext struct {
+	Message *tgbotapi.Message
+	Command *botCommand
+	Lang    string
+	Args    []string
+}
+
+// commandHandler 命令处理函数
+type commandHandler func(c *commandContext)
+
+// commandMiddleware 命令中间件，包装下一个处理函数
+type commandMiddleware func(next commandHandler) commandHandler
+
+// usage 返回命令的用法说明，如 /unsub <链接|ID>
+func (cmd *botCommand) usage(lang string) string {
+	parts := []string{"/" + cmd.Name}
+	for _, arg := range cmd.Args {
+		label := T(lang, "arg."+arg.Name)
+		if len(arg.Choices) > 0 {
+			label = strings.Join(arg.Choices, "|")
+		}
+		if arg.Required {
+			parts = append(parts, "<"+label+">")
+		} else {
+			parts = append(parts, "["+label+"]")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseArgs 按参数定义拆分并校验命令参数
+func (cmd *botCommand) parseArgs(text string) ([]string, bool) {
+	fields := strings.Fields(text)
+	var args []string
+	for i, arg := range cmd.Args {
+		if i >= len(fields) {
+			if arg.Required {
+				return nil, false
+			}
+			break
+		}
+		value := fields[i]
+		if arg.Rest {
+			value = strings.Join(fields[i:], " ")
+		}
+		if len(arg.Choices) > 0 && !containsFold(arg.Choices, value) {
+			return nil, false
+		}
+		args = append(args, value)
+	}
+	return args, true
+}
+
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ==================== 路由 ====================
+
+// commandRouter 命令注册表与分发
+type commandRouter struct {
+	commands   map[string]*botCommand
+	order      []*botCommand
+	middleware []commandMiddleware
+	notFound   *botCommand
+}
+
+func newCommandRouter(notFound commandHandler) *commandRouter {
+	return &commandRouter{
+		commands: make(map[string]*botCommand),
+		notFound: &botCommand{Access: CommandPublic, Handler: notFound},
+	}
+}
+
+// Register 注册命令，按注册顺序显示在命令菜单和帮助中
+func (r *commandRouter) Register(cmds ...*botCommand) {
+	for _, cmd := range cmds {
+		r.commands[cmd.Name] = cmd
+		r.order = append(r.order, cmd)
+	}
+}
+
+// Use 添加中间件，先添加的在外层
+func (r *commandRouter) Use(mw ...commandMiddleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// Commands 返回按注册顺序排列的命令
+func (r *commandRouter) Commands() []*botCommand {
+	return r.order
+}
+
+// Dispatch 经过中间件调用命令处理函数，未知命令交给 notFound
+func (r *commandRouter) Dispatch(c *commandContext) {
+	cmd, ok := r.commands[c.Message.Command()]
+	if !ok {
+		cmd = r.notFound
+	}
+	c.Command = cmd
+	h := cmd.Handler
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i](h)
+	}
+	h(c)
+}
+
+// newCommandRouter 注册所有命令与中间件
+func (b *Bot) newCommandRouter() *commandRouter {
+	langChoices := make([]string, 0, len(locales)+1)
+	for code := range locales {
+		langChoices = append(langChoices, code)
+	}
+	sort.Strings(langChoices)
+	langChoices = append(langChoices, "auto")
+	ref := []commandArg{{Name: "ref", Required: true, Rest: true}}
+
+	r := newCommandRouter(func(c *commandContext) { b.replyText(c.Message, T(c.Lang, "cmd.unknown")) })
+	r.Register(
+		&botCommand{Name: "start", Access: CommandPublic, Handler: b.handleStart},
+		&botCommand{Name: "help", Access: CommandPublic, Handler: b.handleHelp},
+		&botCommand{Name: "status", Access: CommandUser, Handler: b.handleStatus},
+		&botCommand{Name: "queue", Access: CommandUser, Handler: b.handleQueue},
+		&botCommand{Name: "cancel", Access: CommandUser, Handler: b.handleCancel},
+		&botCommand{Name: "subs", Access: CommandUser, Handler: b.handleSubs},
+		&botCommand{Name: "unsub", Access: CommandUser, Args: ref, Handler: b.handleUnsub},
+		&botCommand{Name: "subinfo", Access: CommandUser, Args: ref, Handler: b.handleSubInfo},
+		&botCommand{Name: "lang", Access: CommandUser, Args: []commandArg{{Name: "lang", Choices: langChoices}}, Handler: b.handleLang},
+		&botCommand{Name: "notify", Access: CommandUser, Args: []commandArg{
+			{Name: "setting", Choices: []string{"on", "off", "silent", "events", "email"}},
+			{Name: "value", Rest: true},
+		}, Handler: b.handleNotify},
+		&botCommand{Name: "pause", Access: CommandAdmin, Handler: b.handlePause},
+		&botCommand{Name: "resume", Access: CommandAdmin, Handler: b.handleResume},
+		&botCommand{Name: "unban", Access: CommandAdmin, Args: []commandArg{{Name: "user", Required: true}}, Handler: b.handleUnban},
+		&botCommand{Name: "accounts", Access: CommandAdmin, Args: []commandArg{
+			{Name: "action", Choices: []string{"add", "remove", "reset"}},
+			{Name: "account"},
+		}, Handler: b.handleAccounts},
+		&botCommand{Name: "tdl", Access: CommandAdmin, Args: []commandArg{
+			{Name: "action", Choices: []string{"upgrade", "rollback"}},
+			{Name: "version"},
+			{Name: "sha256"},
+		}, Handler: b.handleTDL},
+		&botCommand{Name: "maintenance", Access: CommandAdmin, Args: []commandArg{
+			{Name: "mode", Choices: []string{"on", "off"}},
+			{Name: "notice", Rest: true},
+		}, Handler: b.handleMaintenance},
+	)
+	r.Use(b.recoverMiddleware, b.loggingMiddleware, b.rateLimitMiddleware, b.authMiddleware, b.argsMiddleware)
+	return r
+}
+
+// handleCommand 处理一条命令消息
+func (b *Bot) handleCommand(message *tgbotapi.Message) {
+	b.commands.Dispatch(&commandContext{Message: message, Lang: b.userLang(message.From)})
+}
+
+// ==================== 中间件 ====================
+
+// recoverMiddleware 捕获命令处理中的 panic，避免整个 Bot 退出
+func (b *Bot) recoverMiddleware(next commandHandler) commandHandler {
+	return func(c *commandContext) {
+		defer func() {
+			if r := recover(); r != nil {
+				b.logger.Error("命令处理发生 panic", "command", c.Message.Command(), "user_id", c.Message.From.ID, "panic", r, "stack", string(debug.Stack()))
+				b.replyText(c.Message, T(c.Lang, "cmd.error"))
+			}
+		}()
+		next(c)
+	}
+}
+
+// loggingMiddleware 记录命令调用及耗时
+func (b *Bot) loggingMiddleware(next commandHandler) commandHandler {
+	return func(c *commandContext) {
+		start := time.Now()
+		next(c)
+		b.logger.Info("处理命令", "command", c.Message.Command(), "user_id", c.Message.From.ID, "chat_id", c.Message.Chat.ID, "duration", time.Since(start))
+	}
+}
+
+// rateLimitMiddleware 命令与普通消息共用入站限流 (b.inbound) 的令牌桶与封禁，管理员不受限制。
+// 命令不在更新循环中限流，只在这里计数一次
+func (b *Bot) rateLimitMiddleware(next commandHandler) commandHandler {
+	return func(c *commandContext) {
+		if !b.allowInbound(tgbotapi.Update{Message: c.Message}) {
+			return
+		}
+		next(c)
+	}
+}
+
+// authMiddleware 检查命令所需的角色
+func (b *Bot) authMiddleware(next commandHandler) commandHandler {
+	return func(c *commandContext) {
+		user := c.Message.From
+		switch c.Command.Access {
+		case CommandUser:
+			if !checkUserPermission(user.ID) && !isAdmin(user.ID) {
+				b.logger.Warn("未授权用户尝试使用命令", "user_id", user.ID, "username", user.UserName, "command", c.Command.Name)
+				b.replyText(c.Message, T(c.Lang, "common.no_permission"))
+				return
+			}
+		case CommandAdmin:
+			if !isAdmin(user.ID) {
+				b.logger.Warn("非管理员尝试使用管理命令", "user_id", user.ID, "command", c.Command.Name)
+				b.replyText(c.Message, T(c.Lang, "common.admin_only"))
+				return
+			}
+		}
+		next(c)
+	}
+}
+
+// argsMiddleware 按参数定义校验命令参数，不符合时回复用法说明
+func (b *Bot) argsMiddleware(next commandHandler) commandHandler {
+	return func(c *commandContext) {
+		args, ok := c.Command.parseArgs(c.Message.CommandArguments())
+		if !ok {
+			b.replyText(c.Message, T(c.Lang, "cmd.usage", "usage", c.Command.usage(c.Lang)))
+			return
+		}
+		c.Args = args
+		next(c)
+	}
+}
+
+// ==================== 命令菜单 ====================
+
+// visibleTo 命令是否对指定角色可见
+func (cmd *botCommand) visibleTo(access commandAccess) bool {
+	return cmd.Access <= access
+}
+
+// commandList 返回某个角色可见命令的菜单项
+func (r *commandRouter) commandList(access commandAccess, lang string) []tgbotapi.BotCommand {
+	var list []tgbotapi.BotCommand
+	for _, cmd := range r.order {
+		if cmd.visibleTo(access) {
+			list = append(list, tgbotapi.BotCommand{Command: cmd.Name, Description: T(lang, "cmd.desc."+cmd.Name)})
+		}
+	}
+	return list
+}
+
+// accessOf 返回用户的命令角色
+func accessOf(userID int64) commandAccess {
+	switch {
+	case isAdmin(userID):
+		return CommandAdmin
+	case checkUserPermission(userID):
+		return CommandUser
+	default:
+		return CommandPublic
+	}
+}
+
+// registerCommandMenus 通过 setMyCommands 发布命令菜单：默认范围显示所有人可用的命令，
+// 白名单用户与管理员的私聊各自显示其角色可用的命令；每个范围按语言分别设置
+func (b *Bot) registerCommandMenus() {
+	type menuScope struct {
+		scope  tgbotapi.BotCommandScope
+		access commandAccess
+	}
+	defaultAccess := CommandPublic
+	if AllowedUsers == nil {
+		defaultAccess = CommandUser
+	}
+	scopes := []menuScope{{tgbotapi.NewBotCommandScopeDefault(), defaultAccess}}
+	users := make(map[int64]bool)
+	for id, ok := range AllowedUsers {
+		users[id] = ok
+	}
+	for id, ok := range AdminUsers {
+		users[id] = users[id] || ok
+	}
+	for id, ok := range users {
+		if access := accessOf(id); ok && access > defaultAccess {
+			scopes = append(scopes, menuScope{tgbotapi.NewBotCommandScopeChat(id), access})
+		}
+	}
+
+	langs := make([]string, 0, len(locales))
+	for code := range locales {
+		langs = append(langs, code)
+	}
+	sort.Strings(langs)
+
+	failed := 0
+	for _, s := range scopes {
+		// 不带语言代码的菜单使用 DefaultLanguage，其余按客户端语言匹配
+		if _, err := b.api.Request(tgbotapi.NewSetMyCommandsWithScope(s.scope, b.commands.commandList(s.access, DefaultLanguage)...)); err != nil {
+			failed++
+			b.logger.Warn("设置命令菜单失败", "scope", s.scope.Type, "chat_id", s.scope.ChatID, "error", err)
+		}
+		for _, lang := range langs {
+			cfg := tgbotapi.NewSetMyCommandsWithScopeAndLanguage(s.scope, lang, b.commands.commandList(s.access, lang)...)
+			if _, err := b.api.Request(cfg); err != nil {
+				failed++
+				b.logger.Warn("设置命令菜单失败", "scope", s.scope.Type, "chat_id", s.scope.ChatID, "lang", lang, "error", err)
+			}
+		}
+	}
+	b.logger.Info("命令菜单已发布", "scopes", len(scopes), "languages", len(langs), "failed", failed)
+}
+
+// helpCommands 返回 /help 中显示的命令列表
+func (b *Bot) helpCommands(userID int64, lang string) string {
+	access := accessOf(userID)
+	var sb strings.Builder
+	for _, cmd := range b.commands.Commands() {
+		if !cmd.visibleTo(access) {
+			continue
+		}
+		sb.WriteString("   " + cmd.usage(lang) + " - " + T(lang, "cmd.desc."+cmd.Name) + "\n")
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
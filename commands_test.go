@@ -0,0 +1,107 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestParseArgs(t *testing.T) {
+	cmd := &botCommand{Name: "maintenance", Args: []commandArg{
+		{Name: "mode", Required: true, Choices: []string{"on", "off"}},
+		{Name: "notice", Rest: true},
+	}}
+	tests := []struct {
+		text string
+		want []string
+		ok   bool
+	}{
+		{"on", []string{"on"}, true},
+		{"OFF", []string{"OFF"}, true},
+		{"on  升级中，请稍候  ", []string{"on", "升级中，请稍候"}, true},
+		{"on a  b", []string{"on", "a b"}, true},
+		{"", nil, false},
+		{"maybe", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := cmd.parseArgs(tt.text)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseArgs(%q) = %q, %v, want %q, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCommandUsage(t *testing.T) {
+	cmd := &botCommand{Name: "accounts", Args: []commandArg{
+		{Name: "action", Choices: []string{"add", "remove"}},
+		{Name: "account", Required: true},
+	}}
+	want := "/accounts [add|remove] <" + T(LangEN, "arg.account") + ">"
+	if got := cmd.usage(LangEN); got != want {
+		t.Errorf("usage = %q, want %q", got, want)
+	}
+}
+
+func TestDispatchPassesParsedArgs(t *testing.T) {
+	b := &Bot{}
+	var got []string
+	r := newCommandRouter(func(c *commandContext) { t.Errorf("unexpected notFound for /%s", c.Message.Command()) })
+	r.Register(&botCommand{Name: "unsub", Args: []commandArg{{Name: "ref", Required: true, Rest: true}}, Handler: func(c *commandContext) {
+		got = c.Args
+	}})
+	r.Use(b.argsMiddleware)
+
+	text := "/unsub@tgbot  https://a.example/sub?x=1 "
+	msg := &tgbotapi.Message{
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/unsub@tgbot")}},
+	}
+	r.Dispatch(&commandContext{Message: msg, Lang: LangEN})
+	if want := []string{"https://a.example/sub?x=1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Args = %q, want %q", got, want)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	savedRate, savedBurst, savedBan, savedAdmins := InboundUserRate, InboundUserBurst, SpamBanThreshold, AdminUsers
+	defer func() {
+		InboundUserRate, InboundUserBurst, SpamBanThreshold, AdminUsers = savedRate, savedBurst, savedBan, savedAdmins
+	}()
+	InboundUserRate, InboundUserBurst, SpamBanThreshold = 0.001, 2, 0
+	AdminUsers = map[int64]bool{7: true}
+
+	tests := []struct {
+		name    string
+		userID  int64
+		handled int
+		replies int
+	}{
+		{name: "user throttled after burst", userID: 42, handled: 2, replies: 1},
+		{name: "admin not limited", userID: 7, handled: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			b.inbound = newInboundLimiter()
+			handled := 0
+			r := newCommandRouter(func(c *commandContext) { t.Errorf("unexpected notFound for /%s", c.Message.Command()) })
+			r.Register(&botCommand{Name: "status", Handler: func(c *commandContext) { handled++ }})
+			r.Use(b.rateLimitMiddleware)
+
+			for i := 0; i < 3; i++ {
+				msg := testMessage()
+				msg.From.ID, msg.Chat.ID = tt.userID, tt.userID
+				msg.Text = "/status"
+				msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len("/status")}}
+				r.Dispatch(&commandContext{Message: msg, Lang: LangEN})
+			}
+			if handled != tt.handled || len(fake.sent()) != tt.replies {
+				t.Errorf("handled = %d, replies = %q, want %d handled and %d replies", handled, fake.sent(), tt.handled, tt.replies)
+			}
+		})
+	}
+}
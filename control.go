@@ -0,0 +1,169 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// controlStateFile 暂停/维护状态的持久化文件名
+const controlStateFile = "control_state.json"
+
+// controlState 队列暂停与维护模式状态，重启后保持
+type controlState struct {
+	Paused             bool      `json:"paused"`
+	Maintenance        bool      `json:"maintenance"`
+	MaintenanceMessage string    `json:"maintenance_message,omitempty"`
+	UpdatedBy          int64     `json:"updated_by,omitempty"`
+	UpdatedAt          time.Time `json:"updated_at,omitempty"`
+}
+
+// botControl 管理员控制状态
+type botControl struct {
+	mu    sync.RWMutex
+	state controlState
+}
+
+func newBotControl() *botControl {
+	return &botControl{}
+}
+
+func (c *botControl) load() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := loadJSONFile(controlStateFile, &c.state)
+	return err
+}
+
+// update 修改状态并持久化
+func (c *botControl) update(operatorID int64, fn func(s *controlState)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fn(&c.state)
+	c.state.UpdatedBy = operatorID
+	c.state.UpdatedAt = time.Now()
+	return saveJSONFile(controlStateFile, c.state)
+}
+
+// State 返回当前状态副本
+func (c *botControl) State() controlState {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state
+}
+
+// HoldQueue 是否暂停启动新任务（暂停或维护中）
+func (c *botControl) HoldQueue() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state.Paused || c.state.Maintenance
+}
+
+// MaintenanceNotice 维护中时返回给用户的提示。未设置提示信息时使用 MaintenanceDefaultMessage，
+// 两者都为空则使用消息目录中的默认文本
+func (c *botControl) MaintenanceNotice(lang string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.state.Maintenance {
+		return "", false
+	}
+	msg := c.state.MaintenanceMessage
+	if msg == "" {
+		msg = MaintenanceDefaultMessage
+	}
+	if msg == "" {
+		msg = T(lang, "maintenance.default")
+	}
+	return "🛠 " + msg, true
+}
+
+// ==================== 管理员命令 ====================
+
+// handlePause 处理 /pause 命令：继续接收链接，但不再启动新任务
+func (b *Bot) handlePause(c *commandContext) {
+	if err := b.control.update(c.Message.From.ID, func(s *controlState) { s.Paused = true }); err != nil {
+		b.logger.Error("保存暂停状态失败", "error", err)
+	}
+	b.logger.Info("队列已暂停", "operator_id", c.Message.From.ID)
+	b.replyText(c.Message, T(c.Lang, "control.paused"))
+}
+
+// handleResume 处理 /resume 命令
+func (b *Bot) handleResume(c *commandContext) {
+	if err := b.control.update(c.Message.From.ID, func(s *controlState) { s.Paused = false }); err != nil {
+		b.logger.Error("保存暂停状态失败", "error", err)
+	}
+	b.logger.Info("队列已恢复", "operator_id", c.Message.From.ID)
+	lang := c.Lang
+	text := T(lang, "control.resumed", "count", b.taskManager.GetQueueSize())
+	if b.control.State().Maintenance {
+		text += "\n" + T(lang, "control.resumed_maintenance")
+	}
+	b.replyText(c.Message, text)
+	b.taskManager.notifyQueue()
+}
+
+// handleMaintenance 处理 /maintenance on|off [提示信息] 命令
+func (b *Bot) handleMaintenance(c *commandContext) {
+	lang := c.Lang
+	if len(c.Args) == 0 {
+		status := T(lang, "common.off")
+		if b.control.State().Maintenance {
+			status = T(lang, "common.on")
+		}
+		b.replyText(c.Message, T(lang, "maintenance.status", "state", status)+"\n"+T(lang, "maintenance.usage"))
+		return
+	}
+
+	switch strings.ToLower(c.Args[0]) {
+	case "on":
+		notice := ""
+		if len(c.Args) > 1 {
+			notice = strings.TrimSpace(c.Args[1])
+		}
+		if err := b.control.update(c.Message.From.ID, func(s *controlState) {
+			s.Maintenance = true
+			s.MaintenanceMessage = notice
+		}); err != nil {
+			b.logger.Error("保存维护状态失败", "error", err)
+		}
+		b.logger.Info("维护模式已开启", "operator_id", c.Message.From.ID, "notice", notice)
+		text, _ := b.control.MaintenanceNotice(lang)
+		b.replyText(c.Message, T(lang, "maintenance.enabled", "notice", text))
+	case "off":
+		if err := b.control.update(c.Message.From.ID, func(s *controlState) {
+			s.Maintenance = false
+			s.MaintenanceMessage = ""
+		}); err != nil {
+			b.logger.Error("保存维护状态失败", "error", err)
+		}
+		b.logger.Info("维护模式已关闭", "operator_id", c.Message.From.ID)
+		text := T(lang, "maintenance.disabled")
+		if b.control.State().Paused {
+			text += "\n" + T(lang, "maintenance.still_paused")
+		}
+		b.replyText(c.Message, text)
+		b.taskManager.notifyQueue()
+	default:
+		b.replyText(c.Message, T(lang, "maintenance.usage"))
+	}
+}
+
+// controlStatusText 返回 /status 中显示的队列控制状态
+func (b *Bot) controlStatusText(lang string) string {
+	state := b.control.State()
+	var parts []string
+	if state.Paused {
+		parts = append(parts, T(lang, "control.state_paused"))
+	}
+	if state.Maintenance {
+		parts = append(parts, T(lang, "control.state_maintenance"))
+	}
+	if len(parts) == 0 {
+		return T(lang, "control.state_running")
+	}
+	return strings.Join(parts, ", ") + " " + T(lang, "control.since", "time", state.UpdatedAt.Format("01-02 15:04"))
+}
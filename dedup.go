@@ -0,0 +1,205 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// forwardHistoryFile 已完成转发记录的持久化文件名
+const forwardHistoryFile = "forward_history.json"
+
+// forwardRecord 一次已完成的转发 (源消息 -> 转发目标)
+type forwardRecord struct {
+	Link   string    `json:"link"`
+	Target string    `json:"target"`
+	UserID int64     `json:"user_id"`
+	TaskID int       `json:"task_id"`
+	At     time.Time `json:"at"`
+}
+
+// forwardHistory 记录已完成转发的 (源消息, 转发目标)，用于跨消息、跨时间去重
+type forwardHistory struct {
+	mu      sync.Mutex
+	records map[string]forwardRecord // forwardKey -> 最近一次转发
+}
+
+func newForwardHistory() *forwardHistory {
+	return &forwardHistory{records: make(map[string]forwardRecord)}
+}
+
+func (h *forwardHistory) load() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := loadJSONFile(forwardHistoryFile, &h.records)
+	if h.records == nil {
+		h.records = make(map[string]forwardRecord)
+	}
+	return err
+}
+
+// forwardKey 生成去重键：忽略协议、大小写、末尾斜杠与不影响消息的查询参数 (如 ?single)。
+// comment 与 thread 指向评论区或话题中的其他消息，保留在键中
+func forwardKey(link, target string) string {
+	norm := strings.ToLower(strings.TrimSpace(link))
+	norm = strings.TrimPrefix(strings.TrimPrefix(norm, "https://"), "http://")
+	if i := strings.IndexByte(norm, '#'); i != -1 {
+		norm = norm[:i]
+	}
+	var query string
+	if i := strings.IndexByte(norm, '?'); i != -1 {
+		values, _ := url.ParseQuery(norm[i+1:])
+		norm = norm[:i]
+		kept := url.Values{}
+		for _, name := range []string{"comment", "thread"} {
+			if v := values.Get(name); v != "" {
+				kept.Set(name, v)
+			}
+		}
+		query = kept.Encode()
+	}
+	norm = strings.TrimSuffix(norm, "/")
+	if query != "" {
+		norm += "?" + query
+	}
+	return norm + "|" + target
+}
+
+// Lookup 查找 window 内转发到 target 的记录，window <= 0 时不去重
+func (h *forwardHistory) Lookup(link, target string, window time.Duration) (forwardRecord, bool) {
+	if window <= 0 {
+		return forwardRecord{}, false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rec, ok := h.records[forwardKey(link, target)]
+	if !ok || time.Since(rec.At) > window {
+		return forwardRecord{}, false
+	}
+	return rec, true
+}
+
+// Record 记录完成的转发，并清理超过所有去重时间窗口的旧记录
+func (h *forwardHistory) Record(links []string, target string, userID int64, taskID int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	for _, link := range links {
+		h.records[forwardKey(link, target)] = forwardRecord{Link: link, Target: target, UserID: userID, TaskID: taskID, At: now}
+	}
+	keep := maxDedupWindow()
+	for key, rec := range h.records {
+		if now.Sub(rec.At) > keep {
+			delete(h.records, key)
+		}
+	}
+	return saveJSONFile(forwardHistoryFile, h.records)
+}
+
+// dedupWindowFor 返回用户的去重时间窗口
+func dedupWindowFor(userID int64) time.Duration {
+	if w, ok := UserDedupWindows[userID]; ok {
+		return w
+	}
+	return ForwardDedupWindow
+}
+
+// maxDedupWindow 所有用户中最长的去重时间窗口，早于该时间的记录不再需要
+func maxDedupWindow() time.Duration {
+	max := ForwardDedupWindow
+	for _, w := range UserDedupWindows {
+		if w > max {
+			max = w
+		}
+	}
+	return max
+}
+
+// ==================== 重复转发确认 ====================
+
+// skipForwarded 过滤掉去重时间窗口内已转发过的链接，为其回复提示和 "仍然转发" 按钮，返回需要转发的链接。
+// hooked 表示链接已执行过 pre_enqueue 钩子
+func (b *Bot) skipForwarded(message *tgbotapi.Message, links []string, priority TaskPriority, hooked bool) []string {
+	window := dedupWindowFor(message.From.ID)
+	var fresh, dups []string
+	var lines []string
+	lang := b.userLang(message.From)
+	for _, link := range links {
+		rec, ok := b.history.Lookup(link, ForwardTarget, window)
+		if !ok {
+			fresh = append(fresh, link)
+			continue
+		}
+		dups = append(dups, link)
+		lines = append(lines, link+"\n"+T(lang, "dedup.line", "date", rec.At.Format("2006-01-02"), "id", rec.TaskID))
+	}
+	if len(dups) > 0 {
+		b.logger.Info("跳过已转发过的链接", "user_id", message.From.ID, "count", len(dups))
+		b.offerForwardOverride(message, &pendingForward{UserID: message.From.ID, Message: message, Links: dups, Priority: priority, Hooked: hooked}, lines)
+	}
+	return fresh
+}
+
+// albumForwarded 相册中是否有已转发过的消息，有则提示并返回 true，整个相册等待确认
+func (b *Bot) albumForwarded(message *tgbotapi.Message, links []string, priority TaskPriority, hooked bool) bool {
+	window := dedupWindowFor(message.From.ID)
+	lang := b.userLang(message.From)
+	for _, link := range links {
+		if rec, ok := b.history.Lookup(link, ForwardTarget, window); ok {
+			line := links[0] + " " + T(lang, "task.album", "count", len(links)) + "\n" + T(lang, "dedup.line", "date", rec.At.Format("2006-01-02"), "id", rec.TaskID)
+			b.offerForwardOverride(message, &pendingForward{UserID: message.From.ID, Message: message, Links: links, Album: true, Priority: priority, Hooked: hooked}, []string{line})
+			return true
+		}
+	}
+	return false
+}
+
+// offerForwardOverride 回复已转发过的链接列表与 "仍然转发" 按钮
+func (b *Bot) offerForwardOverride(message *tgbotapi.Message, o *pendingForward, lines []string) {
+	lang := b.userLang(message.From)
+	id := b.pendingForwards.Add(o)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "dedup.btn_force"), fmt.Sprintf("fwdagain_%d", id)),
+		),
+	)
+	msg := tgbotapi.NewMessage(message.Chat.ID, T(lang, "dedup.header")+"\n\n"+strings.Join(lines, "\n\n"))
+	msg.ReplyToMessageID = message.MessageID
+	msg.ReplyMarkup = keyboard
+	if _, err := b.send(msg); err != nil {
+		b.logger.Error("发送消息失败", "chat_id", message.Chat.ID, "error", err)
+	}
+}
+
+// handleForwardOverrideCallback 处理 "仍然转发" 按钮: fwdagain_<id>
+func (b *Bot) handleForwardOverrideCallback(query *tgbotapi.CallbackQuery) {
+	var id int
+	fmt.Sscanf(strings.TrimPrefix(query.Data, "fwdagain_"), "%d", &id)
+	o, ok := b.takePendingForward(query, id)
+	if !ok {
+		return
+	}
+	lang := b.userLang(query.From)
+	b.updateTaskMessage(query.Message.Chat.ID, query.Message.MessageID, query.Message.Text+"\n\n"+T(lang, "dedup.forced"), nil)
+
+	b.logger.Info("用户确认重新转发", "user_id", o.UserID, "count", len(o.Links))
+	b.enqueuePending(o)
+}
+
+// recordForwarded 任务成功完成后记录已转发的源消息
+func (b *Bot) recordForwarded(q *QueuedTask) {
+	links := q.Album
+	if len(links) == 0 {
+		links = []string{q.Link}
+	}
+	if err := b.history.Record(links, ForwardTarget, q.UserID, q.TaskID); err != nil {
+		b.taskLogger(q).Error("保存转发记录失败", "error", err)
+	}
+}
@@ -0,0 +1,165 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setDedupWindows 临时替换去重时间窗口配置
+func setDedupWindows(t *testing.T, def time.Duration, users map[int64]time.Duration) {
+	t.Helper()
+	savedDefault, savedUsers := ForwardDedupWindow, UserDedupWindows
+	t.Cleanup(func() { ForwardDedupWindow, UserDedupWindows = savedDefault, savedUsers })
+	ForwardDedupWindow, UserDedupWindows = def, users
+}
+
+func TestForwardHistoryLookup(t *testing.T) {
+	h := newForwardHistory()
+	now := time.Now()
+	h.records[forwardKey("https://t.me/chan/1", "me")] = forwardRecord{Link: "https://t.me/chan/1", TaskID: 1, At: now.Add(-time.Hour)}
+	h.records[forwardKey("https://t.me/chan/2", "me")] = forwardRecord{Link: "https://t.me/chan/2", TaskID: 2, At: now.Add(-3 * time.Hour)}
+
+	tests := []struct {
+		name   string
+		link   string
+		target string
+		window time.Duration
+		id     int
+	}{
+		{name: "within window", link: "https://t.me/chan/1", target: "me", window: 2 * time.Hour, id: 1},
+		{name: "expired", link: "https://t.me/chan/2", target: "me", window: 2 * time.Hour},
+		{name: "longer window", link: "https://t.me/chan/2", target: "me", window: 4 * time.Hour, id: 2},
+		{name: "window disabled", link: "https://t.me/chan/1", target: "me", window: 0},
+		{name: "negative window", link: "https://t.me/chan/1", target: "me", window: -time.Hour},
+		{name: "other target", link: "https://t.me/chan/1", target: "other", window: 2 * time.Hour},
+		{name: "same message in another form", link: "HTTP://T.me/Chan/1/?single", target: "me", window: 2 * time.Hour, id: 1},
+		{name: "comment is another message", link: "https://t.me/chan/1?comment=5", target: "me", window: 2 * time.Hour},
+		{name: "thread is another message", link: "https://t.me/chan/1?thread=9", target: "me", window: 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, ok := h.Lookup(tt.link, tt.target, tt.window)
+			if ok != (tt.id != 0) || rec.TaskID != tt.id {
+				t.Errorf("Lookup = %+v, %v, want task %d", rec, ok, tt.id)
+			}
+		})
+	}
+}
+
+func TestForwardKeyKeepsCommentAndThread(t *testing.T) {
+	same := [][2]string{
+		{"https://t.me/chan/1?comment=5&single", "https://t.me/chan/1/?comment=5"},
+		{"https://t.me/chan/1?thread=9&comment=5", "https://t.me/chan/1?comment=5&thread=9"},
+	}
+	for _, p := range same {
+		if forwardKey(p[0], "me") != forwardKey(p[1], "me") {
+			t.Errorf("forwardKey(%q) != forwardKey(%q)", p[0], p[1])
+		}
+	}
+	if forwardKey("https://t.me/chan/1?comment=5", "me") == forwardKey("https://t.me/chan/1?comment=6", "me") {
+		t.Error("different comments share a key")
+	}
+}
+
+func TestDedupWindowFor(t *testing.T) {
+	setDedupWindows(t, 24*time.Hour, map[int64]time.Duration{1: time.Hour, 2: 0})
+	tests := []struct {
+		userID int64
+		want   time.Duration
+	}{
+		{1, time.Hour},
+		{2, 0},
+		{3, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		if got := dedupWindowFor(tt.userID); got != tt.want {
+			t.Errorf("dedupWindowFor(%d) = %v, want %v", tt.userID, got, tt.want)
+		}
+	}
+}
+
+func TestForwardHistoryRecordPrunes(t *testing.T) {
+	savedDir := BotDataDir
+	BotDataDir = t.TempDir()
+	defer func() { BotDataDir = savedDir }()
+	// 最长的窗口来自用户配置
+	setDedupWindows(t, time.Hour, map[int64]time.Duration{1: 3 * time.Hour})
+
+	h := newForwardHistory()
+	now := time.Now()
+	h.records[forwardKey("https://t.me/chan/1", "me")] = forwardRecord{Link: "https://t.me/chan/1", At: now.Add(-2 * time.Hour)}
+	h.records[forwardKey("https://t.me/chan/2", "me")] = forwardRecord{Link: "https://t.me/chan/2", At: now.Add(-4 * time.Hour)}
+	if err := h.Record([]string{"https://t.me/chan/3"}, "me", 1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for key := range h.records {
+		got = append(got, key)
+	}
+	want := []string{"t.me/chan/1|me", "t.me/chan/3|me"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("records = %v, want %v", got, want)
+	}
+
+	// 重新加载后记录保持一致
+	loaded := newForwardHistory()
+	if err := loaded.load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.records) != 2 {
+		t.Errorf("loaded %d records, want 2", len(loaded.records))
+	}
+}
+
+func TestSkipForwarded(t *testing.T) {
+	b, fake := newTestBot(t)
+	setDedupWindows(t, time.Hour, map[int64]time.Duration{43: 0})
+	savedTarget := ForwardTarget
+	ForwardTarget = "me"
+	defer func() { ForwardTarget = savedTarget }()
+	if err := b.history.Record([]string{"https://t.me/chan/1"}, "me", 42, 9); err != nil {
+		t.Fatal(err)
+	}
+	links := []string{"https://t.me/chan/1", "https://t.me/chan/2"}
+
+	t.Run("duplicates held back", func(t *testing.T) {
+		fresh := b.skipForwarded(testMessage(), links, PriorityNormal, false)
+		if !reflect.DeepEqual(fresh, []string{"https://t.me/chan/2"}) {
+			t.Errorf("fresh = %v, want only chan/2", fresh)
+		}
+		sent := fake.sent()
+		if len(sent) != 1 || !strings.Contains(sent[0], "https://t.me/chan/1") || !strings.Contains(sent[0], "#9") {
+			t.Errorf("replies = %q, want one notice for chan/1 (task #9)", sent)
+		}
+		b.pendingForwards.mu.Lock()
+		defer b.pendingForwards.mu.Unlock()
+		if len(b.pendingForwards.pending) != 1 {
+			t.Fatalf("pending = %d, want 1", len(b.pendingForwards.pending))
+		}
+		for _, o := range b.pendingForwards.pending {
+			if !reflect.DeepEqual(o.Links, []string{"https://t.me/chan/1"}) {
+				t.Errorf("pending links = %v, want chan/1", o.Links)
+			}
+		}
+	})
+
+	t.Run("dedup disabled for user", func(t *testing.T) {
+		message := testMessage()
+		message.From.ID = 43
+		before := len(fake.sent())
+		if fresh := b.skipForwarded(message, links, PriorityNormal, false); !reflect.DeepEqual(fresh, links) {
+			t.Errorf("fresh = %v, want all links", fresh)
+		}
+		if len(fake.sent()) != before {
+			t.Error("notice sent while dedup is disabled")
+		}
+	})
+}
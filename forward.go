@@ -0,0 +1,275 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// isForwarded 消息是否是转发的
+func isForwarded(message *tgbotapi.Message) bool {
+	return message.ForwardDate != 0
+}
+
+// forwardSourceLink 根据转发来源 (ForwardFromChat / ForwardFromMessageID) 生成原消息链接。
+// 只有从频道或超级群组转发、且来源未隐藏的消息才有链接
+func forwardSourceLink(message *tgbotapi.Message) (string, bool) {
+	chat := message.ForwardFromChat
+	if chat == nil || message.ForwardFromMessageID == 0 {
+		return "", false
+	}
+	if chat.UserName != "" {
+		return fmt.Sprintf("https://t.me/%s/%d", chat.UserName, message.ForwardFromMessageID), true
+	}
+	// 私有频道/超级群组的 ID 形如 -100xxxxxxxxxx，链接中使用去掉 -100 的部分
+	id := strconv.FormatInt(chat.ID, 10)
+	if !strings.HasPrefix(id, "-100") {
+		return "", false
+	}
+	return fmt.Sprintf("https://t.me/c/%s/%d", strings.TrimPrefix(id, "-100"), message.ForwardFromMessageID), true
+}
+
+// handleForwardedMessage 处理可以定位来源的转发消息。相册中的消息先收集，
+// 在 AlbumCollectDelay 内没有新消息后合并为一个任务
+func (b *Bot) handleForwardedMessage(message *tgbotapi.Message, link string) {
+	if message.MediaGroupID == "" {
+		b.logger.Info("收到转发消息", "user_id", message.From.ID, "link", link)
+		if !b.routeForwarded(message, []string{link}) {
+			return
+		}
+		priority := b.forwardPriority(message)
+		b.enqueueLinks(message, b.skipForwarded(message, []string{link}, priority, false), priority)
+		return
+	}
+	b.albums.add(message, b.flushAlbum)
+}
+
+// flushAlbum 将收集到的相册消息按原消息顺序合并为一个任务，回复相册中的第一条消息
+func (b *Bot) flushAlbum(messages []*tgbotapi.Message) {
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ForwardFromMessageID < messages[j].ForwardFromMessageID
+	})
+	var links []string
+	for _, m := range messages {
+		if link, ok := forwardSourceLink(m); ok {
+			links = append(links, link)
+		}
+	}
+	if len(links) == 0 {
+		return
+	}
+
+	first := messages[0]
+	for _, m := range messages[1:] {
+		if m.MessageID < first.MessageID {
+			first = m
+		}
+	}
+	if b.shuttingDown.Load() {
+		b.replyText(first, T(b.userLang(first.From), "msg.restarting"))
+		return
+	}
+	b.logger.Info("收到转发相册", "user_id", first.From.ID, "media_group", first.MediaGroupID, "count", len(links))
+	if !b.routeForwarded(first, links) {
+		return
+	}
+	priority := b.forwardPriority(first)
+	if len(links) == 1 {
+		b.enqueueLinks(first, b.skipForwarded(first, links, priority, false), priority)
+		return
+	}
+	if b.albumForwarded(first, links, priority, false) {
+		return
+	}
+	b.enqueueAlbum(first, links, priority)
+}
+
+// forwardPriority 转发任务的优先级。转发消息的文本属于原消息，不解析 !high / !low 标记
+func (b *Bot) forwardPriority(message *tgbotapi.Message) TaskPriority {
+	priority, _ := taskPriorityFor(message.From.ID, "")
+	return priority
+}
+
+// source 传给 tdl 的来源，相册的多条链接以逗号分隔 (tdl forward --from 支持逗号分隔的多个值)
+func (q *QueuedTask) source() string {
+	if len(q.Album) > 0 {
+		return strings.Join(q.Album, ",")
+	}
+	return q.Link
+}
+
+// displayLink 状态消息中显示的链接，相册附带消息数量
+func (q *QueuedTask) displayLink() string {
+	if len(q.Album) > 1 {
+		return q.Link + " " + T(q.Lang, "task.album", "count", len(q.Album))
+	}
+	return q.Link
+}
+
+// ==================== 等待确认的转发 ====================
+
+// pendingForwardTTL 等待确认的转发的有效期
+const pendingForwardTTL = 30 * time.Minute
+
+// pendingForward 等待用户确认后才加入队列的链接 (已转发过的链接、需要预览确认的来源)
+type pendingForward struct {
+	ID        int
+	UserID    int64
+	Message   *tgbotapi.Message
+	Links     []string
+	Album     bool // Links 为同一个相册，确认后合并为一个任务
+	Hooked    bool // 已执行过 pre_enqueue 钩子 (钩子改写后的链接)，确认后不再执行
+	Priority  TaskPriority
+	CreatedAt time.Time
+}
+
+// pendingForwardStore 保存等待确认的转发
+type pendingForwardStore struct {
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]*pendingForward
+}
+
+func newPendingForwardStore() *pendingForwardStore {
+	return &pendingForwardStore{pending: make(map[int]*pendingForward)}
+}
+
+// Add 保存并返回其 ID，同时清理过期的记录
+func (s *pendingForwardStore) Add(o *pendingForward) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, old := range s.pending {
+		if time.Since(old.CreatedAt) > pendingForwardTTL {
+			delete(s.pending, id)
+		}
+	}
+	s.nextID++
+	o.ID = s.nextID
+	o.CreatedAt = time.Now()
+	s.pending[o.ID] = o
+	return o.ID
+}
+
+// Take 取出并删除
+func (s *pendingForwardStore) Take(id int) (*pendingForward, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.pending[id]
+	if !ok {
+		return nil, false
+	}
+	delete(s.pending, id)
+	if time.Since(o.CreatedAt) > pendingForwardTTL {
+		return nil, false
+	}
+	return o, true
+}
+
+// Peek 查看但不删除
+func (s *pendingForwardStore) Peek(id int) (*pendingForward, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.pending[id]
+	return o, ok
+}
+
+// takePendingForward 按钮回调中取出等待确认的转发，只有提交者本人可以确认。
+// 失败时已应答回调，调用方直接返回
+func (b *Bot) takePendingForward(query *tgbotapi.CallbackQuery, id int) (*pendingForward, bool) {
+	lang := b.userLang(query.From)
+	o, ok := b.pendingForwards.Peek(id)
+	if !ok || query.Message == nil {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "forward.expired"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return nil, false
+	}
+	if o.UserID != query.From.ID {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "forward.not_owner"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return nil, false
+	}
+	if b.shuttingDown.Load() {
+		b.api.Request(tgbotapi.NewCallback(query.ID, T(lang, "msg.restarting")))
+		return nil, false
+	}
+	if _, ok := b.pendingForwards.Take(id); !ok {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "forward.expired"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return nil, false
+	}
+	b.api.Request(tgbotapi.NewCallback(query.ID, ""))
+	return o, true
+}
+
+// enqueuePending 将确认后的转发加入队列
+func (b *Bot) enqueuePending(o *pendingForward) {
+	switch {
+	case o.Album && o.Hooked:
+		b.enqueueLinkTask(o.Message, o.Links[0], o.Links, o.Priority)
+	case o.Album:
+		b.enqueueAlbum(o.Message, o.Links, o.Priority)
+	case o.Hooked:
+		b.addLinks(o.Message, o.Links, o.Priority)
+	default:
+		b.enqueueLinks(o.Message, o.Links, o.Priority)
+	}
+}
+
+// ==================== 相册收集 ====================
+
+// pendingAlbum 正在收集的相册
+type pendingAlbum struct {
+	messages []*tgbotapi.Message
+	timer    *time.Timer
+}
+
+// albumCollector 按 (聊天, media_group_id) 收集相册中的消息。
+// Telegram 会把相册中的每条消息作为独立更新发送
+type albumCollector struct {
+	mu     sync.Mutex
+	albums map[string]*pendingAlbum
+}
+
+func newAlbumCollector() *albumCollector {
+	return &albumCollector{albums: make(map[string]*pendingAlbum)}
+}
+
+// add 加入一条相册消息。同一相册在 AlbumCollectDelay 内没有新消息后调用 flush
+func (c *albumCollector) add(message *tgbotapi.Message, flush func([]*tgbotapi.Message)) {
+	key := fmt.Sprintf("%d:%s", message.Chat.ID, message.MediaGroupID)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if album, ok := c.albums[key]; ok {
+		album.messages = append(album.messages, message)
+		// 计时器已触发时回调正在等待锁，会带上本条消息
+		if album.timer.Stop() {
+			album.timer.Reset(AlbumCollectDelay)
+		}
+		return
+	}
+	album := &pendingAlbum{messages: []*tgbotapi.Message{message}}
+	album.timer = time.AfterFunc(AlbumCollectDelay, func() {
+		c.mu.Lock()
+		if c.albums[key] != album {
+			c.mu.Unlock()
+			return
+		}
+		messages := album.messages
+		delete(c.albums, key)
+		c.mu.Unlock()
+		flush(messages)
+	})
+	c.albums[key] = album
+}
@@ -0,0 +1,291 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// 群组中允许提交任务的成员
+const (
+	GroupSubmitAll    = "all"    // 所有成员
+	GroupSubmitAdmins = "admins" // 群管理员
+	GroupSubmitUsers  = "users"  // 仅 Users 列表中的成员
+)
+
+// GroupConfig 单个群组的配置
+type GroupConfig struct {
+	Submitters string  // all / admins / users，为空时按 AllowedUsers 白名单判断
+	Users      []int64 // Submitters 为 users 时允许提交的成员
+}
+
+// isGroupChat 是否为群组或超级群组
+func isGroupChat(chat *tgbotapi.Chat) bool {
+	return chat != nil && (chat.IsGroup() || chat.IsSuperGroup())
+}
+
+// addressedToBot 群组中的消息是否是发给 Bot 的：命令 (不带 @ 或 @ 本 Bot)、提及 Bot 或回复 Bot 的消息
+func (b *Bot) addressedToBot(message *tgbotapi.Message) bool {
+	self := b.api.Self
+	if message.IsCommand() {
+		_, target, found := strings.Cut(message.CommandWithAt(), "@")
+		return !found || strings.EqualFold(target, self.UserName)
+	}
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && reply.From.ID == self.ID {
+		return true
+	}
+
+	text, entities := message.Text, message.Entities
+	if text == "" {
+		text, entities = message.Caption, message.CaptionEntities
+	}
+	if self.UserName != "" && strings.Contains(strings.ToLower(text), "@"+strings.ToLower(self.UserName)) {
+		return true
+	}
+	for _, e := range entities {
+		if e.Type == "text_mention" && e.User != nil && e.User.ID == self.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// requireSubmitPermission 检查消息发送者是否可以提交任务。
+// 已配置的群组按群组设置判断，其余情况按 AllowedUsers 白名单判断
+func (b *Bot) requireSubmitPermission(message *tgbotapi.Message) bool {
+	cfg, ok := GroupSettings[message.Chat.ID]
+	if !isGroupChat(message.Chat) || !ok || cfg.Submitters == "" || isAdmin(message.From.ID) {
+		return b.requirePermission(message)
+	}
+
+	userID := message.From.ID
+	allowed := false
+	switch cfg.Submitters {
+	case GroupSubmitAll:
+		allowed = true
+	case GroupSubmitAdmins:
+		allowed = b.isChatAdmin(message.Chat.ID, userID)
+	case GroupSubmitUsers:
+		for _, id := range cfg.Users {
+			if id == userID {
+				allowed = true
+				break
+			}
+		}
+	}
+	if !allowed {
+		b.logger.Warn("群成员无权提交任务", "chat_id", message.Chat.ID, "user_id", userID, "submitters", cfg.Submitters)
+		b.replyText(message, T(b.userLang(message.From), "group.no_permission"))
+	}
+	return allowed
+}
+
+// canCancelFor 判断 operatorID 是否可以取消 ownerID 在 chat 中提交的任务：
+// 任务所有者、Bot 管理员，以及任务所在群组的管理员
+func (b *Bot) canCancelFor(operatorID, ownerID int64, chat *tgbotapi.Chat) bool {
+	if operatorID == ownerID || isAdmin(operatorID) {
+		return true
+	}
+	return isGroupChat(chat) && b.isChatAdmin(chat.ID, operatorID)
+}
+
+// queryChat 返回按钮所在的聊天
+func queryChat(query *tgbotapi.CallbackQuery) *tgbotapi.Chat {
+	if query.Message == nil {
+		return nil
+	}
+	return query.Message.Chat
+}
+
+// ==================== 群管理员缓存 ====================
+
+// chatAdminTTL 群管理员身份的缓存时间
+const chatAdminTTL = 5 * time.Minute
+
+type chatAdminEntry struct {
+	admin   bool
+	checked time.Time
+}
+
+// chatAdminCache 缓存 getChatMember 的结果，避免每次点击按钮都请求 API
+type chatAdminCache struct {
+	mu      sync.Mutex
+	entries map[[2]int64]chatAdminEntry
+}
+
+func newChatAdminCache() *chatAdminCache {
+	return &chatAdminCache{entries: make(map[[2]int64]chatAdminEntry)}
+}
+
+// isChatAdmin 用户是否为群组的创建者或管理员
+func (b *Bot) isChatAdmin(chatID, userID int64) bool {
+	key := [2]int64{chatID, userID}
+	b.chatAdmins.mu.Lock()
+	entry, ok := b.chatAdmins.entries[key]
+	b.chatAdmins.mu.Unlock()
+	if ok && time.Since(entry.checked) < chatAdminTTL {
+		return entry.admin
+	}
+
+	member, err := b.api.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: chatID, UserID: userID},
+	})
+	if err != nil {
+		b.logger.Warn("查询群成员身份失败", "chat_id", chatID, "user_id", userID, "error", err)
+		return false
+	}
+	admin := member.IsCreator() || member.IsAdministrator()
+	b.chatAdmins.mu.Lock()
+	b.chatAdmins.entries[key] = chatAdminEntry{admin: admin, checked: time.Now()}
+	b.chatAdmins.mu.Unlock()
+	return admin
+}
+
+// ==================== 论坛话题 ====================
+
+// topicTTL 话题索引中记录的保留时间
+const topicTTL = 24 * time.Hour
+
+// topicMeta 更新中 telegram-bot-api 未解析的话题字段
+type topicMeta struct {
+	Message       *topicMessage `json:"message"`
+	CallbackQuery *struct {
+		Message *topicMessage `json:"message"`
+	} `json:"callback_query"`
+}
+
+type topicMessage struct {
+	MessageID       int  `json:"message_id"`
+	MessageThreadID int  `json:"message_thread_id"`
+	IsTopicMessage  bool `json:"is_topic_message"`
+	Chat            struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+type topicEntry struct {
+	threadID int
+	seen     time.Time
+}
+
+// topicIndex 记录论坛群组中消息所在的话题 (chat, message) -> message_thread_id
+type topicIndex struct {
+	mu      sync.Mutex
+	threads map[[2]int64]topicEntry
+}
+
+func newTopicIndex() *topicIndex {
+	return &topicIndex{threads: make(map[[2]int64]topicEntry)}
+}
+
+func (t *topicIndex) record(m *topicMessage) {
+	if m == nil || !m.IsTopicMessage || m.MessageThreadID == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	t.threads[[2]int64{m.Chat.ID, int64(m.MessageID)}] = topicEntry{threadID: m.MessageThreadID, seen: now}
+	if len(t.threads) > 5000 {
+		for key, e := range t.threads {
+			if now.Sub(e.seen) > topicTTL {
+				delete(t.threads, key)
+			}
+		}
+	}
+}
+
+// Thread 返回消息所在的话题 ID，不在话题中时返回 0
+func (t *topicIndex) Thread(chatID int64, messageID int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.threads[[2]int64{chatID, int64(messageID)}].threadID
+}
+
+// pollUpdates 长轮询获取更新。与 GetUpdatesChan 相同，但会额外解析论坛话题字段 (message_thread_id)，
+// 使回复能发送到原消息所在的话题。停机开始后退出
+func (b *Bot) pollUpdates(config tgbotapi.UpdateConfig) <-chan tgbotapi.Update {
+	ch := make(chan tgbotapi.Update, b.api.Buffer)
+	go func() {
+		for {
+			select {
+			case <-b.stopping:
+				return
+			default:
+			}
+
+			resp, err := b.api.Request(config)
+			if err != nil {
+				b.logger.Warn("获取更新失败，3 秒后重试", "error", err)
+				time.Sleep(3 * time.Second)
+				continue
+			}
+			var updates []tgbotapi.Update
+			if err := json.Unmarshal(resp.Result, &updates); err != nil {
+				b.logger.Error("解析更新失败", "error", err)
+				time.Sleep(3 * time.Second)
+				continue
+			}
+			var metas []topicMeta
+			if err := json.Unmarshal(resp.Result, &metas); err != nil || len(metas) != len(updates) {
+				metas = nil
+			}
+
+			for i, update := range updates {
+				if update.UpdateID < config.Offset {
+					continue
+				}
+				config.Offset = update.UpdateID + 1
+				if metas != nil {
+					b.topics.record(metas[i].Message)
+					if metas[i].CallbackQuery != nil {
+						b.topics.record(metas[i].CallbackQuery.Message)
+					}
+				}
+				select {
+				case ch <- update:
+				case <-b.stopping:
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// send 发送消息。回复论坛话题中的消息时附带 message_thread_id，确保发送到同一话题
+func (b *Bot) send(msg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	thread := 0
+	if msg.ReplyToMessageID != 0 {
+		thread = b.topics.Thread(msg.ChatID, msg.ReplyToMessageID)
+	}
+	if thread == 0 {
+		return b.api.Send(msg)
+	}
+
+	// telegram-bot-api v5.5.1 不支持 message_thread_id，直接构造请求参数
+	params := make(tgbotapi.Params)
+	params.AddNonZero64("chat_id", msg.ChatID)
+	params["text"] = msg.Text
+	params.AddNonEmpty("parse_mode", msg.ParseMode)
+	params.AddBool("disable_web_page_preview", msg.DisableWebPagePreview)
+	params.AddNonZero("reply_to_message_id", msg.ReplyToMessageID)
+	params.AddBool("allow_sending_without_reply", true)
+	params.AddNonZero("message_thread_id", thread)
+	if err := params.AddInterface("reply_markup", msg.ReplyMarkup); err != nil {
+		return tgbotapi.Message{}, err
+	}
+	resp, err := b.api.MakeRequest("sendMessage", params)
+	if err != nil {
+		return tgbotapi.Message{}, err
+	}
+	var sent tgbotapi.Message
+	err = json.Unmarshal(resp.Result, &sent)
+	return sent, err
+}
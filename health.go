@@ -0,0 +1,260 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// 会话检查结果
+const (
+	sessionOK           = "ok"
+	sessionUnauthorized = "unauthorized" // 未登录或会话失效
+	sessionUnreachable  = "unreachable"  // 转发目标无法访问
+	sessionError        = "error"        // 检查本身失败 (超时、网络等)，不改变账号状态
+)
+
+// sessionCheck 一个账号最近一次检查的结果
+type sessionCheck struct {
+	State     string
+	CheckedAt time.Time
+}
+
+// sessionMonitor 定期检查每个账号的会话与转发目标，所有账号都未通过检查时暂停启动新任务
+type sessionMonitor struct {
+	mu       sync.Mutex
+	checks   map[string]sessionCheck // 账号 -> 最近一次结果
+	checking sync.Mutex              // 同一时间只进行一轮检查
+}
+
+func newSessionMonitor() *sessionMonitor {
+	return &sessionMonitor{checks: make(map[string]sessionCheck)}
+}
+
+// set 记录检查结果，返回该账号之前的状态
+func (m *sessionMonitor) set(name, state string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.checks[name].State
+	m.checks[name] = sessionCheck{State: state, CheckedAt: time.Now()}
+	return prev
+}
+
+// prune 删除已不在账号池中的账号
+func (m *sessionMonitor) prune(names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	for name := range m.checks {
+		if !keep[name] {
+			delete(m.checks, name)
+		}
+	}
+}
+
+// HoldReason 没有任何账号通过检查时返回暂停原因 (unauthorized / unreachable)。
+// 尚未检查或只有检查失败 (sessionError) 时不暂停
+func (m *sessionMonitor) HoldReason() (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reason := ""
+	for _, c := range m.checks {
+		switch c.State {
+		case sessionOK:
+			return "", false
+		case sessionUnauthorized:
+			reason = sessionUnauthorized
+		case sessionUnreachable:
+			if reason == "" {
+				reason = sessionUnreachable
+			}
+		}
+	}
+	return reason, reason != ""
+}
+
+// Passed 账号最近一次检查是否通过
+func (m *sessionMonitor) Passed(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.checks[name].State == sessionOK
+}
+
+// Hold 是否因会话检查未通过而暂停启动新任务
+func (m *sessionMonitor) Hold() bool {
+	_, held := m.HoldReason()
+	return held
+}
+
+// Status /status 中显示的检查结果
+func (m *sessionMonitor) Status(lang string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.checks) == 0 {
+		return T(lang, "health.unchecked")
+	}
+	var failed []string
+	var last time.Time
+	for name, c := range m.checks {
+		if c.CheckedAt.After(last) {
+			last = c.CheckedAt
+		}
+		if c.State != sessionOK {
+			failed = append(failed, name+": "+T(lang, "health.state."+c.State))
+		}
+	}
+	at := last.Format("01-02 15:04")
+	if len(failed) == 0 {
+		return T(lang, "health.ok", "time", at)
+	}
+	sort.Strings(failed)
+	return T(lang, "health.failed", "accounts", strings.Join(failed, "; "), "time", at)
+}
+
+// ==================== 定期检查 ====================
+
+// runSessionMonitor 启动后立即检查一次，之后每 SessionCheckInterval 检查一次
+func (b *Bot) runSessionMonitor() {
+	if SessionCheckInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(SessionCheckInterval)
+	defer ticker.Stop()
+	for {
+		b.checkSessions()
+		select {
+		case <-b.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkSessions 依次检查账号池中的每个账号
+func (b *Bot) checkSessions() {
+	if !b.health.checking.TryLock() {
+		return
+	}
+	defer b.health.checking.Unlock()
+
+	// tdl 的会话数据不能被多个进程同时打开，整轮检查都持有 tdl；
+	// 有任务或预览正在执行时跳过本轮，任务结果已反映账号状态
+	if !b.tdlMu.TryLock() {
+		b.logger.Debug("tdl 正在使用，跳过会话检查")
+		return
+	}
+	defer b.tdlMu.Unlock()
+	names := b.accounts.Names()
+	b.health.prune(names)
+	wasHeld := b.health.Hold()
+	for _, name := range names {
+		if b.shuttingDown.Load() {
+			return
+		}
+		state, output := b.checkSession(name)
+		prev := b.health.set(name, state)
+		switch state {
+		case sessionError:
+			b.logger.Warn("会话检查失败", "account", name, "output", truncateString(output, 500))
+			continue
+		case sessionOK:
+			if err := b.accounts.Restore(name); err != nil {
+				b.logger.Error("保存账号状态失败", "account", name, "error", err)
+			}
+			if prev != "" && prev != sessionOK && prev != sessionError {
+				b.logger.Info("会话检查已恢复正常", "account", name)
+				b.notifySessionAdmins("health.alert_restored", name)
+			}
+			continue
+		case sessionUnauthorized:
+			if err := b.accounts.Failure(name, true); err != nil {
+				b.logger.Error("保存账号状态失败", "account", name, "error", err)
+			}
+		}
+		b.logger.Warn("会话检查未通过", "account", name, "state", state, "target", ForwardTarget)
+		if prev != state {
+			b.notifySessionAdmins("health.alert_"+state, name)
+		}
+	}
+
+	if held := b.health.Hold(); held != wasHeld {
+		b.logger.Warn("会话检查状态变化", "hold_queue", held)
+		if !held {
+			b.taskManager.notifyQueue()
+		}
+	}
+}
+
+// checkSession 通过 tdl 读取转发目标的最新一条消息，检查账号是否已登录、目标是否可以访问
+func (b *Bot) checkSession(account string) (string, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), SessionCheckTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "bash", TDLScriptPath, "--check", ForwardTarget)
+	cmd.Env = append(os.Environ(), "TDL_NAMESPACE="+account)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd.Process.Pid) }
+	out, err := cmd.CombinedOutput()
+	output := string(out)
+	switch {
+	case ctx.Err() != nil:
+		return sessionError, "检查超时"
+	case err == nil:
+		return sessionOK, output
+	case sessionUnauthorizedOutput(output):
+		return sessionUnauthorized, output
+	case sourceInaccessible(output):
+		return sessionUnreachable, output
+	}
+	return sessionError, output
+}
+
+// sessionUnauthorizedOutput tdl 输出是否表示未登录或会话失效
+func sessionUnauthorizedOutput(output string) bool {
+	lower := strings.ToLower(output)
+	for _, s := range []string{"not authorized", "unauthorized", "please login first"} {
+		if strings.Contains(lower, s) {
+			return true
+		}
+	}
+	return accountAuthError(output)
+}
+
+// notifySessionAdmins 会话检查状态变化时通知管理员
+func (b *Bot) notifySessionAdmins(key, name string) {
+	for adminID, ok := range AdminUsers {
+		if !ok {
+			continue
+		}
+		text := T(b.langOf(adminID), key, "name", name, "target", ForwardTarget)
+		if _, err := b.api.Send(tgbotapi.NewMessage(adminID, text)); err != nil {
+			b.logger.Warn("通知管理员失败", "admin_id", adminID, "error", err)
+		}
+	}
+}
+
+// ==================== 队列集成 ====================
+
+// queueHeld 是否暂停启动新任务 (暂停、维护中、升级 tdl 或会话检查未通过)
+func (b *Bot) queueHeld() bool {
+	return b.control.HoldQueue() || b.tdl.upgrading.Load() || b.health.Hold()
+}
+
+// queueStatusText 新任务的排队状态文本，会话检查未通过时说明原因
+func (b *Bot) queueStatusText(lang string, pos int, p TaskPriority) string {
+	if reason, held := b.health.HoldReason(); held {
+		return T(lang, "health.held", "reason", T(lang, "health.state."+reason), "pos", pos)
+	}
+	return queuePositionText(lang, b.control.HoldQueue() || b.tdl.upgrading.Load(), pos, p)
+}
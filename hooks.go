@@ -0,0 +1,367 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// 生命周期钩子的阶段
+const (
+	HookPreEnqueue  = "pre_enqueue"  // 加入队列前，可以否决或改写任务
+	HookPreRun      = "pre_run"      // 开始执行前 (等待钩子结束后才启动 tdl)
+	HookPostSuccess = "post_success" // 执行成功后
+	HookPostFailure = "post_failure" // 执行失败或超时后
+)
+
+// HookConfig 一个生命周期钩子，Command 与 URL 二选一
+type HookConfig struct {
+	Stage   string
+	Command []string      // 外部命令及参数，任务 JSON 写入标准输入，任务信息同时通过环境变量传递
+	URL     string        // 以 POST 发送任务 JSON
+	Timeout time.Duration // 为 0 时使用 HookDefaultTimeout
+}
+
+// name 日志中显示的钩子名称
+func (h HookConfig) name() string {
+	if h.URL != "" {
+		return h.URL
+	}
+	return strings.Join(h.Command, " ")
+}
+
+// hookPayload 传给钩子的任务 JSON。pre_enqueue 阶段任务尚未分配 ID
+type hookPayload struct {
+	Stage    string   `json:"stage"`
+	TaskID   int      `json:"task_id,omitempty"`
+	UserID   int64    `json:"user_id"`
+	ChatID   int64    `json:"chat_id"`
+	Link     string   `json:"link"`
+	Album    []string `json:"album,omitempty"`
+	Priority string   `json:"priority"`
+	Account  string   `json:"account,omitempty"`
+	ExitCode *int     `json:"exit_code,omitempty"` // 仅 post_success / post_failure
+	Status   string   `json:"status,omitempty"`    // 任务结束时的状态文本
+}
+
+// env 传给外部命令的环境变量
+func (p hookPayload) env() []string {
+	env := []string{
+		"HOOK_STAGE=" + p.Stage,
+		"TASK_ID=" + strconv.Itoa(p.TaskID),
+		"USER_ID=" + strconv.FormatInt(p.UserID, 10),
+		"CHAT_ID=" + strconv.FormatInt(p.ChatID, 10),
+		"LINK=" + p.Link,
+		"ALBUM=" + strings.Join(p.Album, ","),
+		"PRIORITY=" + p.Priority,
+		"ACCOUNT=" + p.Account,
+		"STATUS=" + p.Status,
+	}
+	if p.ExitCode != nil {
+		env = append(env, "EXIT_CODE="+strconv.Itoa(*p.ExitCode))
+	}
+	return env
+}
+
+// hookResult pre_enqueue 钩子的返回：标准输出或 HTTP 响应体中的 JSON，为空表示不修改
+type hookResult struct {
+	Veto   bool     `json:"veto"`
+	Reason string   `json:"reason"`
+	Link   string   `json:"link"`  // 改写链接 (改为单条消息任务)
+	Album  []string `json:"album"` // 改写为相册任务，第一条作为显示的链接
+}
+
+// hookRejected 外部命令以非零状态退出，在 pre_enqueue 阶段表示否决
+type hookRejected struct {
+	reason string
+}
+
+func (e *hookRejected) Error() string {
+	return "钩子否决: " + e.reason
+}
+
+// hooksFor 返回指定阶段的钩子，按配置顺序
+func hooksFor(stage string) []HookConfig {
+	var hooks []HookConfig
+	for _, h := range LifecycleHooks {
+		if h.Stage == stage {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+// runHook 执行一个钩子并返回其输出 (标准输出或 HTTP 响应体)
+func runHook(h HookConfig, payload hookPayload) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = HookDefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if h.URL != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Tgbot-Hook", payload.Stage)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		out, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode/100 != 2 {
+			return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+		}
+		return out, nil
+	}
+
+	if len(h.Command) == 0 {
+		return nil, errors.New("钩子未配置 Command 或 URL")
+	}
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), payload.env()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd.Process.Pid) }
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("钩子超时 (%s)", timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		reason := strings.TrimSpace(stderr.String())
+		if reason == "" {
+			reason = strings.TrimSpace(string(out))
+		}
+		return nil, &hookRejected{reason: truncateString(reason, 200)}
+	}
+	return out, err
+}
+
+// exitCodeOf 命令的退出码，被信号终止时为 -1
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+// ==================== Bot 集成 ====================
+
+// preEnqueueHooks 依次执行 pre_enqueue 钩子。钩子可以否决任务 (回复用户后返回 false) 或改写链接；
+// 钩子本身执行失败 (超时、网络错误等) 时记录日志并放行。batch 为 true 时链接属于批量提交，
+// 每条链接是汇总消息中的一行，不能改写为相册，钩子返回相册时拒绝该链接
+func (b *Bot) preEnqueueHooks(message *tgbotapi.Message, link string, album []string, priority TaskPriority, batch bool) (string, []string, bool) {
+	for _, h := range hooksFor(HookPreEnqueue) {
+		payload := hookPayload{
+			Stage:    HookPreEnqueue,
+			UserID:   message.From.ID,
+			ChatID:   message.Chat.ID,
+			Link:     link,
+			Album:    album,
+			Priority: priority.Key(),
+		}
+		out, err := runHook(h, payload)
+		var rejected *hookRejected
+		if errors.As(err, &rejected) {
+			b.vetoTask(message, link, h, rejected.reason)
+			return "", nil, false
+		}
+		if err != nil {
+			b.logger.Warn("pre_enqueue 钩子执行失败，已放行", "hook", h.name(), "link", link, "error", err)
+			continue
+		}
+		if len(bytes.TrimSpace(out)) == 0 {
+			continue
+		}
+		var result hookResult
+		if err := json.Unmarshal(out, &result); err != nil {
+			b.logger.Warn("pre_enqueue 钩子返回无法解析，已忽略", "hook", h.name(), "error", err)
+			continue
+		}
+		if result.Veto {
+			b.vetoTask(message, link, h, result.Reason)
+			return "", nil, false
+		}
+		switch {
+		case len(result.Album) > 0 && batch:
+			b.logger.Warn("批量提交时钩子不能改写为相册", "hook", h.name(), "link", link, "album", len(result.Album))
+			b.vetoTask(message, link, h, T(b.userLang(message.From), "hooks.album_in_batch"))
+			return "", nil, false
+		case len(result.Album) > 0:
+			b.logger.Info("钩子改写了任务", "hook", h.name(), "link", link, "album", len(result.Album))
+			link, album = result.Album[0], result.Album
+		case result.Link != "" && result.Link != link:
+			b.logger.Info("钩子改写了任务", "hook", h.name(), "link", link, "new_link", result.Link)
+			link, album = result.Link, nil
+		}
+	}
+	return link, album, true
+}
+
+// hookLinks 对每条链接执行 pre_enqueue 钩子后加入队列。钩子改写后的链接必须仍是 t.me 链接，
+// 并重新经过去重与来源预览 (之后不再执行钩子)；未改写的链接已在钩子之前检查过，直接加入队列
+func (b *Bot) hookLinks(message *tgbotapi.Message, links []string, priority TaskPriority) {
+	var kept, rewritten []string
+	for _, link := range links {
+		newLink, album, ok := b.preEnqueueHooks(message, link, nil, priority, len(links) > 1)
+		switch {
+		case !ok:
+		case album != nil:
+			// 只有单条链接可以改写为相册
+			b.addHookedAlbum(message, album, priority)
+		case newLink == link:
+			kept = append(kept, link)
+		case b.checkRewrittenLinks(message, link, []string{newLink}):
+			kept = append(kept, newLink)
+			rewritten = append(rewritten, newLink)
+		}
+	}
+	if len(rewritten) > 0 {
+		passed := make(map[string]bool)
+		fresh := b.skipForwarded(message, rewritten, priority, true)
+		for _, link := range b.previewSources(message, fresh, priority, true) {
+			passed[link] = true
+		}
+		var out []string
+		for _, link := range kept {
+			if !containsString(rewritten, link) || passed[link] {
+				out = append(out, link)
+			}
+		}
+		kept = out
+	}
+	b.addLinks(message, kept, priority)
+}
+
+// hookAlbum 对相册执行 pre_enqueue 钩子后作为一个任务加入队列，钩子也可以把相册改写为单条链接
+func (b *Bot) hookAlbum(message *tgbotapi.Message, album []string, priority TaskPriority) {
+	link, newAlbum, ok := b.preEnqueueHooks(message, album[0], album, priority, false)
+	switch {
+	case !ok:
+	case newAlbum == nil:
+		if !b.checkRewrittenLinks(message, album[0], []string{link}) {
+			return
+		}
+		fresh := b.skipForwarded(message, []string{link}, priority, true)
+		b.addLinks(message, b.previewSources(message, fresh, priority, true), priority)
+	case equalStrings(newAlbum, album):
+		b.enqueueLinkTask(message, album[0], album, priority)
+	default:
+		b.addHookedAlbum(message, newAlbum, priority)
+	}
+}
+
+// addHookedAlbum 将钩子改写出的相册去重后作为一个任务加入队列
+func (b *Bot) addHookedAlbum(message *tgbotapi.Message, album []string, priority TaskPriority) {
+	if !b.checkRewrittenLinks(message, album[0], album) || b.albumForwarded(message, album, priority, true) {
+		return
+	}
+	b.enqueueLinkTask(message, album[0], album, priority)
+}
+
+// checkRewrittenLinks 钩子改写后的链接是否都是 t.me 链接，否则拒绝原链接并通知用户
+func (b *Bot) checkRewrittenLinks(message *tgbotapi.Message, original string, links []string) bool {
+	for _, link := range links {
+		if !isTelegramLink(link) {
+			b.logger.Warn("钩子改写后的链接不是 Telegram 链接，已拒绝", "user_id", message.From.ID, "link", original, "new_link", link)
+			lang := b.userLang(message.From)
+			b.replyText(message, T(lang, "hooks.vetoed", "link", original, "reason", T(lang, "hooks.bad_rewrite", "link", link)))
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// vetoTask 钩子否决任务时通知用户
+func (b *Bot) vetoTask(message *tgbotapi.Message, link string, h HookConfig, reason string) {
+	b.logger.Info("钩子否决了任务", "hook", h.name(), "user_id", message.From.ID, "link", link, "reason", reason)
+	lang := b.userLang(message.From)
+	if reason == "" {
+		reason = T(lang, "hooks.no_reason")
+	}
+	b.replyText(message, T(lang, "hooks.vetoed", "link", link, "reason", reason))
+}
+
+// taskHooks 执行 pre_run / post_success / post_failure 钩子，失败只记录日志。
+// exitCode 为 nil 表示任务尚未执行
+func (b *Bot) taskHooks(stage string, q *QueuedTask, account string, exitCode *int, status string) {
+	hooks := hooksFor(stage)
+	if len(hooks) == 0 {
+		return
+	}
+	payload := hookPayload{
+		Stage:    stage,
+		TaskID:   q.TaskID,
+		UserID:   q.UserID,
+		Link:     q.Link,
+		Album:    q.Album,
+		Priority: q.Priority.Key(),
+		Account:  account,
+		ExitCode: exitCode,
+		Status:   status,
+	}
+	if q.Message != nil {
+		payload.ChatID = q.Message.Chat.ID
+	}
+	tlog := b.taskLogger(q)
+	for _, h := range hooks {
+		if _, err := runHook(h, payload); err != nil {
+			tlog.Warn("钩子执行失败", "stage", stage, "hook", h.name(), "error", err)
+			continue
+		}
+		tlog.Debug("钩子执行完成", "stage", stage, "hook", h.name())
+	}
+}
@@ -0,0 +1,201 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeTelegram 模拟 Bot API，记录发送的消息文本
+type fakeTelegram struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (f *fakeTelegram) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.texts...)
+}
+
+// newTestBot 创建连接到假 Bot API 的 Bot，数据目录为临时目录
+func newTestBot(t *testing.T) (*Bot, *fakeTelegram) {
+	t.Helper()
+	fake := &fakeTelegram{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		result := `true`
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/getMe"):
+			result = `{"id": 1, "is_bot": true, "first_name": "bot", "username": "tbot"}`
+		case strings.HasSuffix(r.URL.Path, "/sendMessage"):
+			fake.mu.Lock()
+			fake.texts = append(fake.texts, r.Form.Get("text"))
+			id := len(fake.texts)
+			fake.mu.Unlock()
+			result = fmt.Sprintf(`{"message_id": %d, "chat": {"id": %s}, "text": %q}`, 1000+id, r.Form.Get("chat_id"), r.Form.Get("text"))
+		}
+		fmt.Fprintf(w, `{"ok": true, "result": %s}`, result)
+	}))
+	t.Cleanup(srv.Close)
+
+	savedDir := BotDataDir
+	BotDataDir = t.TempDir()
+	t.Cleanup(func() { BotDataDir = savedDir })
+
+	api, err := tgbotapi.NewBotAPIWithAPIEndpoint("TEST", srv.URL+"/bot%s/%s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &Bot{
+		api:             api,
+		taskManager:     NewTaskManager(),
+		logger:          slog.New(slog.NewTextHandler(io.Discard, nil)),
+		control:         newBotControl(),
+		userLangs:       newUserLangStore(),
+		notifySettings:  newNotifySettingsStore(),
+		topics:          newTopicIndex(),
+		history:         newForwardHistory(),
+		pendingForwards: newPendingForwardStore(),
+		health:          newSessionMonitor(),
+		tdl:             newTDLTool(),
+	}
+	return b, fake
+}
+
+// testMessage 用户 42 在私聊中发送的消息
+func testMessage() *tgbotapi.Message {
+	return &tgbotapi.Message{MessageID: 7, From: &tgbotapi.User{ID: 42, LanguageCode: "en"}, Chat: &tgbotapi.Chat{ID: 42, Type: "private"}}
+}
+
+// setPreEnqueueHook 配置一个返回固定 JSON 的 pre_enqueue HTTP 钩子
+func setPreEnqueueHook(t *testing.T, respond func(p hookPayload) interface{}) {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p hookPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		json.NewEncoder(w).Encode(respond(p))
+	}))
+	t.Cleanup(srv.Close)
+	saved := LifecycleHooks
+	LifecycleHooks = []HookConfig{{Stage: HookPreEnqueue, URL: srv.URL}}
+	t.Cleanup(func() { LifecycleHooks = saved })
+}
+
+func queuedLinks(b *Bot) []string {
+	b.taskManager.mu.RLock()
+	defer b.taskManager.mu.RUnlock()
+	var links []string
+	for _, q := range b.taskManager.pending {
+		links = append(links, q.Link)
+	}
+	return links
+}
+
+func TestPreEnqueueHooksAlbumInBatch(t *testing.T) {
+	b, fake := newTestBot(t)
+	album := []string{"https://t.me/chan/1", "https://t.me/chan/2"}
+	setPreEnqueueHook(t, func(hookPayload) interface{} { return hookResult{Album: album} })
+
+	if _, _, ok := b.preEnqueueHooks(testMessage(), "https://t.me/chan/1", nil, PriorityNormal, true); ok {
+		t.Error("album rewrite accepted in a batch")
+	}
+	if sent := fake.sent(); len(sent) != 1 || !strings.Contains(sent[0], T(LangEN, "hooks.album_in_batch")) {
+		t.Errorf("replies = %q, want the album_in_batch veto", sent)
+	}
+
+	link, got, ok := b.preEnqueueHooks(testMessage(), "https://t.me/chan/1", nil, PriorityNormal, false)
+	if !ok || link != album[0] || !equalStrings(got, album) {
+		t.Errorf("single link rewrite = %q, %q, %v, want album", link, got, ok)
+	}
+}
+
+func TestHookLinksRewrite(t *testing.T) {
+	savedPreview := ForwardPreviewEnabled
+	ForwardPreviewEnabled = false
+	defer func() { ForwardPreviewEnabled = savedPreview }()
+
+	rewrites := map[string]string{
+		"https://t.me/chan/1": "https://example.com/file.zip",
+		"https://t.me/chan/2": "https://t.me/mirror/2",
+		"https://t.me/chan/3": "https://t.me/mirror/3",
+	}
+	setPreEnqueueHook(t, func(p hookPayload) interface{} {
+		if to, ok := rewrites[p.Link]; ok {
+			return hookResult{Link: to}
+		}
+		return nil
+	})
+
+	b, fake := newTestBot(t)
+	if err := b.history.Record([]string{"https://t.me/mirror/3"}, ForwardTarget, 42, 1); err != nil {
+		t.Fatal(err)
+	}
+	b.hookLinks(testMessage(), []string{"https://t.me/chan/1", "https://t.me/chan/2", "https://t.me/chan/3", "https://t.me/chan/4"}, PriorityNormal)
+
+	// chan/1 改写为非 t.me 链接被拒绝；chan/3 改写后的链接已转发过，等待确认
+	want := []string{"https://t.me/mirror/2", "https://t.me/chan/4"}
+	if got := queuedLinks(b); !equalStrings(got, want) {
+		t.Errorf("queued = %q, want %q", got, want)
+	}
+	sent := strings.Join(fake.sent(), "\n---\n")
+	if !strings.Contains(sent, "https://example.com/file.zip") {
+		t.Errorf("no rejection for the non-Telegram rewrite in %q", sent)
+	}
+	if !strings.Contains(sent, T(LangEN, "dedup.header")) || !strings.Contains(sent, "https://t.me/mirror/3") {
+		t.Errorf("no dedup prompt for the rewritten link in %q", sent)
+	}
+	if n := len(b.pendingForwards.pending); n != 1 {
+		t.Fatalf("pending forwards = %d, want 1", n)
+	}
+	for _, o := range b.pendingForwards.pending {
+		if !o.Hooked {
+			t.Error("dedup prompt for a rewritten link would run the hooks again")
+		}
+	}
+}
+
+func TestEnqueueLinksRunsHooksInBackground(t *testing.T) {
+	release := make(chan struct{})
+	setPreEnqueueHook(t, func(hookPayload) interface{} {
+		<-release
+		return nil
+	})
+	b, _ := newTestBot(t)
+
+	done := make(chan struct{})
+	go func() {
+		b.enqueueLinks(testMessage(), []string{"https://t.me/chan/5"}, PriorityNormal)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		close(release)
+		t.Fatal("enqueueLinks blocked on the pre_enqueue hook")
+	}
+	if got := queuedLinks(b); len(got) != 0 {
+		t.Errorf("queued before the hook returned: %q", got)
+	}
+
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(queuedLinks(b)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := queuedLinks(b); !equalStrings(got, []string{"https://t.me/chan/5"}) {
+		t.Errorf("queued = %q after the hook returned", got)
+	}
+}
@@ -0,0 +1,256 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// 支持的语言
+const (
+	LangZH = "zh"
+	LangEN = "en"
+)
+
+// locales 消息目录: 语言 -> 键 -> 文本。文本中的 {name} 为占位符
+var locales = map[string]map[string]string{
+	LangZH: messagesZH,
+	LangEN: messagesEN,
+}
+
+// languageNames /lang 中显示的语言名称
+var languageNames = map[string]string{
+	LangZH: "中文",
+	LangEN: "English",
+}
+
+var placeholderPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// T 返回指定语言的消息。args 为占位符名称与取值交替排列，如 T(lang, "task.queued", "id", 3)。
+// 缺少翻译时依次回退到 DefaultLanguage、中文，最后返回键本身。
+func T(lang, key string, args ...interface{}) string {
+	text, ok := locales[lang][key]
+	if !ok {
+		text, ok = locales[DefaultLanguage][key]
+	}
+	if !ok {
+		text, ok = locales[LangZH][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return text
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("{%v}", args[i]), fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+// localizedError 需要告诉用户的错误：key 为消息目录中的键，args 为占位符名称与值，cause 为底层原因。
+// Error() 使用中文，只用于日志；回复用户时通过 errorText 按用户的语言生成说明
+type localizedError struct {
+	key   string
+	args  []interface{}
+	cause error
+}
+
+// newError 创建可以翻译给用户的错误
+func newError(key string, args ...interface{}) *localizedError {
+	return &localizedError{key: key, args: args}
+}
+
+// wrapError 创建带底层原因的可翻译错误
+func wrapError(cause error, key string, args ...interface{}) *localizedError {
+	return &localizedError{key: key, args: args, cause: cause}
+}
+
+func (e *localizedError) Error() string {
+	text := T(LangZH, e.key, e.args...)
+	if e.cause != nil {
+		text += ": " + e.cause.Error()
+	}
+	return text
+}
+
+func (e *localizedError) Unwrap() error {
+	return e.cause
+}
+
+// errorText 按用户的语言说明错误，底层原因中可翻译的部分依次附在后面。
+// 其他错误 (文件、网络等) 的内容只写入日志，向用户显示 err.internal
+func errorText(lang string, err error) string {
+	var le *localizedError
+	if !errors.As(err, &le) {
+		return T(lang, "err.internal")
+	}
+	text := T(lang, le.key, le.args...)
+	var cause *localizedError
+	if le.cause != nil && errors.As(le.cause, &cause) {
+		text += ": " + errorText(lang, cause)
+	}
+	return text
+}
+
+// checkLocales 检查消息目录的完整性：每个键在所有语言中都存在，且占位符一致。
+// 返回发现的问题列表，启动时记录到日志。
+func checkLocales() []string {
+	keys := make(map[string]bool)
+	for _, catalog := range locales {
+		for key := range catalog {
+			keys[key] = true
+		}
+	}
+
+	var problems []string
+	for lang, catalog := range locales {
+		for key := range keys {
+			text, ok := catalog[key]
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: 缺少 %s", lang, key))
+				continue
+			}
+			want := placeholderSet(locales[LangZH][key])
+			if got := placeholderSet(text); want != got {
+				problems = append(problems, fmt.Sprintf("%s: %s 的占位符不一致 (%s / %s)", lang, key, got, want))
+			}
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+// placeholderSet 返回文本中占位符的规范化表示
+func placeholderSet(text string) string {
+	found := placeholderPattern.FindAllString(text, -1)
+	sort.Strings(found)
+	out := found[:0]
+	for i, p := range found {
+		if i == 0 || p != found[i-1] {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, ",")
+}
+
+// matchLanguage 将 Telegram 的 language_code (如 zh-hans、en-US) 映射为支持的语言
+func matchLanguage(code string) (string, bool) {
+	code = strings.ToLower(code)
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	_, ok := locales[code]
+	return code, ok
+}
+
+// ==================== 用户语言设置 ====================
+
+// userLangsFile 用户语言设置文件名
+const userLangsFile = "user_langs.json"
+
+// userLangStore 持久化的用户语言设置 (/lang)
+type userLangStore struct {
+	mu    sync.RWMutex
+	langs map[int64]string
+}
+
+func newUserLangStore() *userLangStore {
+	return &userLangStore{langs: make(map[int64]string)}
+}
+
+func (s *userLangStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := loadJSONFile(userLangsFile, &s.langs)
+	if s.langs == nil {
+		s.langs = make(map[int64]string)
+	}
+	return err
+}
+
+// Get 返回用户手动设置的语言
+func (s *userLangStore) Get(userID int64) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lang, ok := s.langs[userID]
+	return lang, ok
+}
+
+// Set 设置用户语言，lang 为空表示恢复自动识别
+func (s *userLangStore) Set(userID int64, lang string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lang == "" {
+		delete(s.langs, userID)
+	} else {
+		s.langs[userID] = lang
+	}
+	return saveJSONFile(userLangsFile, s.langs)
+}
+
+// userLang 返回用户的界面语言：/lang 设置优先，其次 Telegram 客户端语言，最后为 DefaultLanguage
+func (b *Bot) userLang(user *tgbotapi.User) string {
+	if user == nil {
+		return DefaultLanguage
+	}
+	if lang, ok := b.userLangs.Get(user.ID); ok {
+		return lang
+	}
+	if lang, ok := matchLanguage(user.LanguageCode); ok {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// langOf 返回用户 ID 对应的界面语言（无法获取客户端语言时使用）
+func (b *Bot) langOf(userID int64) string {
+	if lang, ok := b.userLangs.Get(userID); ok {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// handleLang 处理 /lang [zh|en|auto] 命令
+func (b *Bot) handleLang(c *commandContext) {
+	if len(c.Args) == 0 {
+		lang := c.Lang
+		codes := make([]string, 0, len(locales))
+		for code := range locales {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		b.replyText(c.Message, T(lang, "lang.current", "lang", languageNames[lang], "options", strings.Join(codes, "|")))
+		return
+	}
+
+	arg := strings.ToLower(c.Args[0])
+	if arg == "auto" {
+		if err := b.userLangs.Set(c.Message.From.ID, ""); err != nil {
+			b.logger.Warn("保存语言设置失败", "error", err)
+		}
+		lang := b.userLang(c.Message.From)
+		b.replyText(c.Message, T(lang, "lang.auto", "lang", languageNames[lang]))
+		return
+	}
+
+	lang, ok := matchLanguage(arg)
+	if !ok {
+		b.replyText(c.Message, T(c.Lang, "lang.unsupported", "lang", arg))
+		return
+	}
+	if err := b.userLangs.Set(c.Message.From.ID, lang); err != nil {
+		b.logger.Warn("保存语言设置失败", "error", err)
+	}
+	b.logger.Info("用户设置了界面语言", "user_id", c.Message.From.ID, "lang", lang)
+	b.replyText(c.Message, T(lang, "lang.set", "lang", languageNames[lang]))
+}
@@ -0,0 +1,399 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+// messagesEN 英文消息目录
+var messagesEN = map[string]string{
+	// 通用
+	"common.no_permission": "❌ You are not allowed to use this bot",
+	"common.admin_only":    "❌ This command is for administrators only",
+	"common.on":            "on",
+	"common.off":           "off",
+	"cmd.unknown":          "❓ Unknown command, see /help",
+	"cmd.usage":            "Usage: {usage}",
+	"cmd.error":            "❌ The command failed, please try again later",
+
+	// 命令菜单与参数
+	"cmd.desc.start":       "Get started",
+	"cmd.desc.help":        "Show help",
+	"cmd.desc.status":      "Check status",
+	"cmd.desc.queue":       "Show the task queue",
+	"cmd.desc.cancel":      "Stop your running task",
+	"cmd.desc.subs":        "My subscriptions",
+	"cmd.desc.unsub":       "Delete a subscription",
+	"cmd.desc.subinfo":     "Subscription details",
+	"cmd.desc.lang":        "Change interface language",
+	"cmd.desc.pause":       "Pause the queue",
+	"cmd.desc.resume":      "Resume the queue",
+	"cmd.desc.maintenance": "Turn maintenance mode on/off",
+	"cmd.desc.unban":       "Lift a user ban",
+	"cmd.desc.accounts":    "Manage the TDL account pool",
+	"cmd.desc.tdl":         "Show or upgrade the tdl version",
+	"cmd.desc.notify":      "Task notification settings",
+	"arg.user":             "user ID",
+	"arg.ref":              "link|ID",
+	"arg.notice":           "notice",
+	"arg.account":          "account",
+	"arg.version":          "version",
+	"arg.sha256":           "SHA-256",
+	"arg.setting":          "setting",
+	"arg.value":            "value",
+
+	// /start /help
+	"start.welcome": "👋 Hi {name}!\n\n" +
+		"🤖 This is a multi-purpose bot\n\n" +
+		"📋 Features:\n" +
+		"• TDL forwarding - send a Telegram link (https://t.me/xxx)\n" +
+		"• Subscriptions - send a subscription link (http/https)\n\n" +
+		"💡 Just send a link, the bot detects its type automatically",
+	"help.text": "📖 Help\n\n" +
+		"1️⃣ Send a Telegram link to forward it\n" +
+		"   Format: https://t.me/channel/123\n\n" +
+		"2️⃣ Send a subscription link to add it\n" +
+		"   Format: any http/https link (not t.me)\n\n" +
+		"3️⃣ Commands:\n" +
+		"{commands}\n\n" +
+		"❓ Contact an administrator if you run into problems",
+
+	// /lang
+	"lang.current":     "🌐 Current language: {lang}\nUsage: /lang {options}|auto",
+	"lang.auto":        "🌐 Automatic detection restored, current language: {lang}",
+	"lang.unsupported": "⚠️ Unsupported language: {lang}",
+	"lang.set":         "🌐 Interface language set to: {lang}",
+
+	// /status
+	"status.script_missing": "❌ not found",
+	"status.script_found":   "✅ found",
+	"status.idle":           "idle",
+	"status.busy":           "busy",
+	"status.processing":     "\n⚡ Processing: task #{id} (user {user})",
+	"status.text": "✅ Bot is running\n" +
+		"📁 TDL script: {script} ({script_state})\n" +
+		"🌐 Subscription API: {backends} ({outbox} pending)\n" +
+		"👤 Your user ID: {user}\n" +
+		"📊 Queue mode: sequential (one at a time)\n" +
+		"⏯ Queue control: {control}\n" +
+		"🔄 State: {state}\n" +
+		"📋 Waiting: {queue} tasks{processing}\n\n" +
+		"👥 Accounts ({strategy}):\n{accounts}\n" +
+		"🩺 Session check: {health}\n" +
+		"🧰 tdl: {tdl}",
+	"breaker.closed":  "ok",
+	"breaker.open":    "circuit open",
+	"breaker.probing": "probing",
+
+	// 消息处理
+	"msg.restarting":  "⏸ The service is restarting, please send the link again later",
+	"msg.invalid":     "⚠️ Please send one of the following links:\n{handlers}",
+	"links.unmatched": "⚠️ Cannot handle {count} link(s):\n{links}\n\nYou can send the following links:\n{handlers}",
+	"links.none":      "(no link types are available to you, please contact an admin)",
+
+	"links.handler.telegram":     "Telegram link (https://t.me/...)",
+	"links.handler.subscription": "Subscription link (http/https)",
+	"file.too_large":             "❌ The file is too large (over {size} KB)",
+	"file.download_failed":       "❌ Failed to download the file, please try again later",
+	"queue.full":                 "⚠️ The task queue is full, please try again later",
+
+	// 优先级
+	"priority.low":           "low",
+	"priority.normal":        "normal",
+	"priority.high":          "high",
+	"priority.label":         " ({name} priority)",
+	"priority.no_permission": "⚠️ You may not raise the priority, the task was queued with the default priority",
+
+	// 任务状态
+	"task.ref":               "Task #{id}",
+	"task.queued":            "⏳ Task #{id} - queued",
+	"task.position":          "📋 Queue position: {pos}{priority}",
+	"task.position_paused":   "⏸ The queue is paused, queue position: {pos}",
+	"task.starting_soon":     "⚡ Starting shortly",
+	"task.processing":        "⏳ Request received, processing...",
+	"task.processing_id":     "⏳ Task #{id} - request received, processing...",
+	"task.start_failed":      "❌ Task #{id} failed to start",
+	"task.login_console":     "🔐 Task #{id} - login required\n\n📺 Open the server console and scan the QR code with Telegram\n\n⏰ The task continues automatically after login",
+	"task.login_link":        "🔐 Task #{id} - login required\n\n📱 Open the following link in Telegram to log in:\n{link}\n\n⏰ The task continues automatically after login",
+	"task.terminated":        "❌ Task #{id} was stopped by the user",
+	"task.timeout":           "❌ Task #{id} timed out ({reason})",
+	"task.failed":            "⚠️ Task #{id} failed",
+	"task.done":              "✅ Task #{id} completed",
+	"task.done_default":      "done",
+	"task.cancelled_summary": "❌ Task #{id} was cancelled from the summary",
+	"task.cancelled_queue":   "❌ Task #{id} was removed from the queue",
+	"task.cancelled_admin":   "❌ Task #{id} was cancelled by an administrator",
+	"task.pinned":            "⬆️ Moved to the front by an administrator, starting shortly",
+	"task.priority_changed":  "🎚 An administrator changed the priority to {name}",
+	"task.shutdown_paused":   "⏸ Service restarting, task paused and saved",
+	"task.restored":          "🔄 Service restored, task queued again",
+	"timeout.stall":          "no progress",
+	"timeout.deadline":       "maximum duration exceeded",
+	"timeout.login":          "login wait exceeded",
+	"btn.cancel_task":        "🛑 Stop task",
+	"btn.cancel_all":         "🛑 Stop all tasks",
+
+	// 按钮回调
+	"cb.invalid_task":          "⚠️ Invalid task reference",
+	"cb.no_permission_summary": "❌ You may not stop the tasks in this summary",
+	"cb.no_permission_task":    "❌ You may not stop this task",
+	"cb.task_gone":             "⚠️ The task has finished or does not exist",
+
+	// /queue /cancel
+	"queue.title":                "📋 Task queue ({count} waiting)",
+	"queue.running":              "⚡ Running: [#{id}] {link}",
+	"queue.owner_inline":         "(user {user})",
+	"queue.running_other":        "⚡ Running another user's task",
+	"queue.pinned":               "📌 Pinned",
+	"queue.priority":             "🎚 {name} priority",
+	"queue.aged":                 "(waiting long, sorted as {name} priority)",
+	"queue.owner":                "👤 User {user}",
+	"queue.btn_cancel":           "❌ Cancel #{id}",
+	"queue.btn_top":              "⬆️ To front",
+	"queue.btn_priority":         "🎚 Priority",
+	"queue.btn_cancel_mine":      "🗑 Cancel all my tasks",
+	"queue.btn_refresh":          "🔄 Refresh",
+	"queue.empty":                "📭 The queue is empty",
+	"queue.empty_mine":           "📭 You have no queued tasks",
+	"queue.truncated":            "… showing the first {count} tasks only",
+	"queue.cancelled_mine":       "Cancelled {count} queued tasks",
+	"queue.cancelled":            "Cancelled task #{id}",
+	"queue.no_permission_cancel": "❌ You may not cancel this task",
+	"queue.task_started":         "⚠️ The task has already started or does not exist",
+	"queue.unknown_action":       "⚠️ Unknown action",
+	"queue.admin_only_order":     "❌ Only administrators can reorder the queue",
+	"queue.admin_only_priority":  "❌ Only administrators can change task priority",
+	"queue.moved_top":            "Task #{id} moved to the front",
+	"queue.priority_set":         "Task #{id} priority: {name}",
+	"cancel.none":                "⚠️ You have no running task\nUse /queue to manage queued tasks",
+	"cancel.done":                "🛑 Task #{id} stopped",
+
+	// 限流与封禁
+	"ratelimit.notice":       "⏳ You are sending messages too fast, please slow down",
+	"ratelimit.banned":       "🚫 You have been temporarily banned until {until} for sending too many messages",
+	"ratelimit.admin_banned": "🚫 User {user} ({name}) was temporarily banned until {until} for flooding\nUse /unban {user} to lift the ban",
+	"ratelimit.unbanned":     "✅ User {user} has been unbanned",
+	"ratelimit.not_banned":   "⚠️ User {user} is not banned",
+
+	// 转发消息
+	"task.album":        "(album, {count} items)",
+	"forward.no_source": "⚠️ Cannot find the source of this forwarded message (the sender is hidden, or it is not from a channel/supergroup)\nPlease send the message link instead",
+	"forward.expired":   "⚠️ This confirmation has expired, please send the links again",
+	"forward.not_owner": "❌ Only the sender can confirm",
+
+	// 转发前预览
+	"preview.checking":       "🔍 Inspecting source...\n{link}",
+	"preview.waiting":        "⏳ Waiting for the current task to finish before inspecting the source...\n{link}",
+	"preview.summary":        "🔍 Source preview\n{link}\n📨 Messages: {count}\n🗂 Media: {media}\n💾 Total size: about {size}\n\nStart forwarding?",
+	"preview.failed":         "⚠️ Could not preview the source: {error}\n{link}\n\nStart forwarding anyway?",
+	"preview.inaccessible":   "❌ The user account cannot access this source (private, missing or not joined), cancelled\n{link}",
+	"preview.btn_start":      "▶️ Start",
+	"preview.btn_cancel":     "❌ Cancel",
+	"preview.started":        "▶️ Queued",
+	"preview.at_least":       "at least {count}",
+	"preview.cancelled":      "❌ Cancelled\n{link}",
+	"preview.media.photo":    "{count} photos",
+	"preview.media.video":    "{count} videos",
+	"preview.media.audio":    "{count} audio",
+	"preview.media.document": "{count} files",
+	"preview.media.text":     "{count} text",
+	"preview.media.none":     "none",
+
+	// 重复转发
+	"dedup.header":    "⚠️ These links were forwarded before and have been skipped:",
+	"dedup.line":      "🔁 Already forwarded ({date}, task #{id})",
+	"dedup.btn_force": "🔁 Forward anyway",
+	"dedup.forced":    "✅ Queued again",
+
+	// 群组
+	"group.no_permission": "❌ You are not allowed to submit tasks in this group",
+
+	// 账号池
+	"accounts.list":            "👥 Account pool (strategy: {strategy})\n\n{accounts}\n\nUsage: /accounts add|remove|reset name",
+	"accounts.line":            "• {name}: {state} ({tasks} tasks, {floods} flood waits)",
+	"accounts.available":       "✅ available",
+	"accounts.cooldown":        "⏳ paused until {until} ({reason})",
+	"accounts.reason.flood":    "flood wait",
+	"accounts.reason.failures": "repeated failures",
+	"accounts.reason.auth":     "session expired or banned",
+	"accounts.reason.":         "unknown reason",
+	"accounts.waiting":         "⏳ All accounts are paused, starting after {until}",
+	"accounts.in_use":          "⚠️ Account {name} is running the current task, remove it later",
+	"accounts.failed":          "❌ Failed: {error}",
+	"accounts.done_add":        "✅ Account {name} added, log it in on the server with tdl login -n {name}",
+	"accounts.done_remove":     "✅ Account {name} removed",
+	"accounts.done_reset":      "✅ Account {name} restored",
+	// 任务通知
+	"notify.event.queued":         "📥 Task #{id} queued",
+	"notify.event.started":        "▶️ Task #{id} started",
+	"notify.event.login_required": "🔐 Task #{id} needs a login, please contact an admin",
+	"notify.event.completed":      "✅ Task #{id} completed",
+	"notify.event.failed":         "❌ Task #{id} failed",
+	"notify.event.cancelled":      "🛑 Task #{id} cancelled",
+	"notify.admin_user":           "👤 User: {user}",
+	"notify.email_subject":        "[tgbot] {event}",
+	"notify.settings": "🔔 Task notifications\n\n" +
+		"Telegram message: {telegram}\n" +
+		"Silent: {silent}\n" +
+		"Email: {email}\n" +
+		"Events: {events}\n\n" +
+		"Usage:\n" +
+		"/notify on|off - turn Telegram message notifications on/off\n" +
+		"/notify silent on|off - send silently\n" +
+		"/notify events completed,failed - choose events (all for every event: {all})\n" +
+		"/notify email address|off - email notifications",
+	"notify.saved":          "✅ Notification settings saved",
+	"notify.bad_events":     "⚠️ Unknown event, choose from: {events} or all",
+	"notify.bad_email":      "⚠️ Invalid email address",
+	"notify.email_disabled": "⚠️ No mail server is configured, email notifications are unavailable",
+
+	// Lifecycle hooks
+	"hooks.vetoed":         "🚫 Task not queued: {reason}\n{link}",
+	"hooks.no_reason":      "rejected by a hook",
+	"hooks.album_in_batch": "a hook returned an album, links sent in a batch cannot be rewritten into an album",
+	"hooks.bad_rewrite":    "a hook rewrote the link to a non-Telegram link: {link}",
+
+	// tdl 版本
+	"tdl.status":            "🧰 tdl version: {version}\nPrevious version: {previous}\nPinned version: {pinned}\n\nUsage: /tdl upgrade v1.2.3 [SHA-256] | /tdl rollback",
+	"tdl.working":           "⏳ Switching the tdl version, new tasks are on hold meanwhile...",
+	"tdl.in_progress":       "⏳ A tdl version switch is already in progress",
+	"tdl.done":              "✅ tdl switched to {version}",
+	"tdl.checksum_required": "❌ TDLChecksums has no checksum for {version}, pass the SHA-256 of the release archive: /tdl upgrade {version} <SHA-256>",
+	"tdl.bad_checksum":      "❌ SHA-256 must be 64 hexadecimal characters",
+	"tdl.failed":            "❌ Failed to switch the tdl version: {error}",
+	"tdl.unmanaged":         "⚠️ tdl version management is off (TDLManaged = false), tdl.sh updates tdl by itself",
+	"tdl.state_script":      "updated by tdl.sh",
+	"tdl.state_upgrading":   "⏳ switching version",
+	"tdl.state_missing":     "❌ not installed",
+
+	// 会话检查
+	"health.unchecked":          "not checked yet",
+	"health.ok":                 "✅ ok ({time})",
+	"health.failed":             "❌ {accounts} ({time})",
+	"health.state.unauthorized": "not logged in or session expired",
+	"health.state.unreachable":  "forward target unreachable",
+	"health.state.error":        "check failed",
+	"health.held":               "⏸ Forwarding is on hold ({reason}), it starts automatically once an admin fixes it, queue position: {pos}",
+	"health.alert_unauthorized": "🔐 Session check: account {name} is not logged in or its session expired\nRun tdl login -n {name} on the server to log in again",
+	"health.alert_unreachable":  "🚫 Session check: account {name} cannot access the forward target {target}\nMake sure the account is still a member of the target channel/group",
+	"health.alert_restored":     "✅ Session check: account {name} is healthy again",
+	"accounts.admin_alert":      "⚠️ Account {name} has been paused: {reason}\nAfter logging in again, use /accounts reset {name} to restore it",
+
+	// /pause /resume /maintenance
+	"control.paused":              "⏸ Queue paused: new links are still queued but will not start\nThe current task runs to completion, use /resume to continue",
+	"control.resumed":             "▶️ Queue resumed, waiting tasks: {count}",
+	"control.resumed_maintenance": "⚠️ Maintenance mode is still on, tasks start after /maintenance off",
+	"control.state_paused":        "⏸ paused",
+	"control.state_maintenance":   "🛠 maintenance",
+	"control.state_running":       "▶️ running",
+	"control.since":               "(since {time})",
+	"maintenance.default":         "The bot is under maintenance and not accepting new tasks, please try again later",
+	"maintenance.status":          "🛠 Maintenance mode: {state}",
+	"maintenance.usage":           "Usage: /maintenance on|off [notice]",
+	"maintenance.enabled":         "🛠 Maintenance mode on, no new tasks will start. Regular users will see:\n{notice}",
+	"maintenance.disabled":        "✅ Maintenance mode off",
+	"maintenance.still_paused":    "⚠️ The queue is still paused, use /resume to continue",
+
+	// 订阅提交
+	"subformat.uri_list":     "URI list",
+	"sub.checking":           "🔍 Checking subscription content...",
+	"sub.invalid":            "❌ Invalid subscription content: {error}",
+	"sub.preview":            "🔍 Found {count} nodes ({format})\n{url}\n\nSubmit this subscription?",
+	"sub.btn_confirm":        "✅ Submit",
+	"sub.btn_cancel":         "❌ Cancel",
+	"sub.btn_submit_valid":   "✅ Submit {count} valid subscriptions",
+	"sub.preview_expired":    "⚠️ This preview has expired, please send the link again",
+	"sub.not_owner":          "❌ You may not act on this subscription",
+	"sub.cancelled":          "🚫 Submission cancelled",
+	"sub.adding":             "⏳ Adding subscription...",
+	"sub.batch_checking":     "🔍 Checking...",
+	"sub.batch_nodes":        "🔍 {count} nodes ({format})",
+	"sub.batch_none_valid":   "❌ No valid subscriptions to submit",
+	"sub.batch_submitting":   "⏳ Submitting...",
+	"sub.batch_suffix":       "({count} nodes, {format})",
+	"sub.timeout":            "❌ Request timed out, please try again later",
+	"sub.unreachable":        "❌ Cannot reach the server",
+	"sub.queued_unavailable": "⏳ Subscription API temporarily unavailable, queued for later submission",
+	"sub.queued_unreachable": "⏳ Cannot reach the server, queued for later submission",
+	"sub.retry_exhausted":    "❌ Still failing after several retries ({error})",
+	"sub.deferred":           "(deferred)",
+
+	// /subs /unsub /subinfo
+	"subs.list_failed":        "❌ Failed to fetch the subscription list, please try again later",
+	"subs.empty":              "📭 You have not added any subscriptions yet",
+	"subs.title":              "📚 My subscriptions ({count} total, page {page}/{pages})",
+	"subs.local_only":         "⚠️ Cannot reach the subscription API, showing local records",
+	"subs.missing_in_api":     "(no longer in the API)",
+	"subs.hint":               "💡 /subinfo <link|ID> for details, /unsub <link|ID> to delete",
+	"subs.btn_prev":           "◀️ Previous",
+	"subs.btn_next":           "Next ▶️",
+	"subs.invalid_page":       "⚠️ Invalid page",
+	"subs.not_owner":          "❌ You can only view your own subscriptions",
+	"subs.not_found":          "⚠️ Subscription not found, or it was not added by you",
+	"unsub.failed":            "❌ Failed to delete subscription: {error}",
+	"unsub.done":              "🗑 Subscription deleted\n{url}",
+	"unsub.not_in_api":        "🗑 The subscription is no longer in the API, the local record was removed\n{url}",
+	"unsub.unsupported":       "❌ The subscription backend has no delete endpoint, the subscription cannot be deleted (local record kept)",
+	"subs.list_unsupported":   "⚠️ The subscription backend has no list endpoint, showing local records that may be stale",
+	"subinfo.api_unsupported": "❓ the subscription backend has no list endpoint",
+	"subinfo.api_exists":      "✅ present",
+	"subinfo.api_unreachable": "❓ cannot reach the subscription API",
+	"subinfo.api_missing":     "❌ no longer exists",
+	"subinfo.title":           "🔎 Subscription details",
+	"subinfo.link":            "🔗 Link: {url}",
+	"subinfo.id":              "🆔 ID: {id}",
+	"subinfo.name":            "🏷 Name: {name}",
+	"subinfo.nodes":           "📦 Nodes: {count}",
+	"subinfo.backend":         "🗄 Backend: {name}",
+	"subinfo.added_at":        "🕒 Added: {time}",
+	"subinfo.api_state":       "🌐 API status: {state}",
+
+	// 错误说明
+	"err.internal":               "internal error",
+	"err.sub.invalid_url":        "invalid subscription link",
+	"err.sub.private":            "the subscription link points to a private or local address, which is not allowed",
+	"err.sub.timeout":            "timed out fetching the subscription",
+	"err.sub.unreachable":        "could not fetch the subscription",
+	"err.sub.too_many_redirects": "too many redirects",
+	"err.sub.bad_redirect":       "unsupported redirect scheme: {scheme}",
+	"err.sub.http_status":        "fetching the subscription failed (status {status})",
+	"err.sub.read_failed":        "could not read the subscription",
+	"err.sub.too_large":          "the subscription is too large (over {size} KB)",
+	"err.sub.empty":              "the subscription is empty",
+	"err.sub.unknown_format":     "unrecognized subscription format",
+	"err.sub.no_nodes":           "no nodes found ({format})",
+	"err.sub.bad_json":           "invalid JSON subscription",
+	"err.sub.api_status":         "the subscription API returned an error (status {status})",
+	"err.sub.delete_status":      "deleting the subscription failed (status {status})",
+	"err.sub.backend_message":    "the subscription backend said: {message}",
+	"err.preview.bad_link":       "could not parse the link",
+	"err.preview.timeout":        "inspection timed out ({timeout})",
+	"err.preview.export_failed":  "tdl export failed",
+	"err.preview.bad_export":     "could not parse the export",
+	"err.accounts.all_paused":    "all accounts are paused until about {time}",
+	"err.accounts.exists":        "the account already exists",
+	"err.accounts.not_found":     "no such account",
+	"err.accounts.invalid":       "account names may only contain letters, digits, _ and -, up to 32 characters",
+	"err.accounts.last":          "the last account cannot be removed",
+	"err.tdl.busy":               "tdl is in use by a task, preview or session check, try again later",
+	"err.tdl.bad_version":        "the version must look like v1.2.3",
+	"err.tdl.checksum_required":  "a SHA-256 checksum is required, either in the command or in TDLChecksums",
+	"err.tdl.unsupported_arch":   "unsupported CPU architecture: {arch}",
+	"err.tdl.no_previous":        "there is no previous version to roll back to",
+	"err.tdl.smoke_failed":       "smoke test failed",
+	"err.tdl.smoke_not_switched": "smoke test failed, did not switch to {version}",
+	"err.tdl.smoke_rolled_back":  "smoke test failed, rolled back to {version}",
+	"err.tdl.smoke_kept":         "smoke test failed, keeping {version}",
+	"err.tdl.undo_failed":        "smoke test failed and the switch could not be undone",
+	"err.tdl.rollback_failed":    "smoke test failed and the rollback failed",
+	"err.tdl.version_failed":     "tdl version failed",
+	"err.tdl.session_failed":     "session check failed for account {name} ({state})",
+	"err.tdl.symlink_failed":     "could not create the symlink",
+	"err.tdl.switch_failed":      "could not switch the tdl version",
+	"err.tdl.unlink_failed":      "could not remove the tdl symlink",
+	"err.tdl.offline_no_cache":   "{version} is not in the cache and offline mode is on",
+	"err.tdl.offline_checksum":   "offline mode needs a checksum for {version}/{asset} in TDLChecksums",
+	"err.tdl.checksum_missing":   "{asset} is not listed in the checksum file",
+	"err.tdl.checksum_mismatch":  "{asset} checksum mismatch: expected {want}, got {got}",
+	"err.tdl.download_failed":    "downloading {name} failed",
+	"err.tdl.extract_failed":     "could not extract the archive",
+	"err.tdl.not_in_archive":     "the archive does not contain tdl",
+}
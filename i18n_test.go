@@ -0,0 +1,242 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestLocalesHaveSameKeys 每个键在所有语言中都必须存在
+func TestLocalesHaveSameKeys(t *testing.T) {
+	for lang, catalog := range locales {
+		for other, otherCatalog := range locales {
+			if lang == other {
+				continue
+			}
+			var missing []string
+			for key := range otherCatalog {
+				if _, ok := catalog[key]; !ok {
+					missing = append(missing, key)
+				}
+			}
+			sort.Strings(missing)
+			for _, key := range missing {
+				t.Errorf("%s: missing %q (present in %s)", lang, key, other)
+			}
+		}
+	}
+}
+
+// TestLocalePlaceholdersMatch 同一个键在所有语言中的占位符集合必须一致
+func TestLocalePlaceholdersMatch(t *testing.T) {
+	langs := make([]string, 0, len(locales))
+	for lang := range locales {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	base := locales[langs[0]]
+
+	keys := make([]string, 0, len(base))
+	for key := range base {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		want := placeholderSet(base[key])
+		for _, lang := range langs[1:] {
+			text, ok := locales[lang][key]
+			if !ok {
+				continue // 由 TestLocalesHaveSameKeys 报告
+			}
+			if got := placeholderSet(text); got != want {
+				t.Errorf("%s: placeholders of %q = {%s}, %s has {%s}", lang, key, got, langs[0], want)
+			}
+		}
+	}
+}
+
+// TestCheckLocalesReportsProblems 启动检查能发现缺少的键与不一致的占位符
+func TestCheckLocalesReportsProblems(t *testing.T) {
+	saved := locales
+	defer func() { locales = saved }()
+	locales = map[string]map[string]string{
+		LangZH: {"a": "{id} 完成", "b": "无"},
+		LangEN: {"a": "{name} done"},
+	}
+
+	problems := checkLocales()
+	want := []string{
+		"en: a 的占位符不一致 ({name} / {id})",
+		"en: 缺少 b",
+	}
+	if strings.Join(problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("checkLocales() = %q, want %q", problems, want)
+	}
+}
+
+// TestCatalogKeysUsedInCode 代码中以字面量传给 T、newError、wrapError 的键必须存在于消息目录中
+func TestCatalogKeysUsedInCode(t *testing.T) {
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyArg := map[string]int{"T": 1, "newError": 0, "wrapError": 1}
+	fset := token.NewFileSet()
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			fn, ok := call.Fun.(*ast.Ident)
+			if !ok {
+				return true
+			}
+			i, ok := keyArg[fn.Name]
+			if !ok || len(call.Args) <= i {
+				return true
+			}
+			lit, ok := call.Args[i].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			key, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				return true
+			}
+			for lang, catalog := range locales {
+				if _, ok := catalog[key]; !ok {
+					t.Errorf("%s: key %q used in code is missing in %s", fset.Position(lit.Pos()), key, lang)
+				}
+			}
+			return true
+		})
+	}
+}
+
+// TestCommandCatalogKeys 每个已注册的命令都有描述，每个参数都有显示名称
+func TestCommandCatalogKeys(t *testing.T) {
+	b := &Bot{}
+	for _, cmd := range b.newCommandRouter().order {
+		keys := []string{"cmd.desc." + cmd.Name}
+		for _, arg := range cmd.Args {
+			if len(arg.Choices) == 0 {
+				keys = append(keys, "arg."+arg.Name)
+			}
+		}
+		for lang, catalog := range locales {
+			for _, key := range keys {
+				if _, ok := catalog[key]; !ok {
+					t.Errorf("/%s: %q missing in %s", cmd.Name, key, lang)
+				}
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	saved, savedDefault := locales, DefaultLanguage
+	defer func() { locales, DefaultLanguage = saved, savedDefault }()
+	DefaultLanguage = LangEN
+	locales = map[string]map[string]string{
+		LangZH: {"greet": "你好 {name}", "zh_only": "仅中文"},
+		LangEN: {"greet": "hello {name}, {name}", "en_only": "english"},
+	}
+
+	tests := []struct {
+		lang, key string
+		args      []interface{}
+		want      string
+	}{
+		{LangZH, "greet", []interface{}{"name", "a"}, "你好 a"},
+		{LangEN, "greet", []interface{}{"name", 7}, "hello 7, 7"},
+		{"fr", "greet", []interface{}{"name", "a"}, "hello a, a"},
+		{LangZH, "en_only", nil, "english"},
+		{LangEN, "zh_only", nil, "仅中文"},
+		{LangEN, "missing.key", nil, "missing.key"},
+		{LangEN, "greet", []interface{}{"other", "x"}, "hello {name}, {name}"},
+	}
+	for _, tt := range tests {
+		if got := T(tt.lang, tt.key, tt.args...); got != tt.want {
+			t.Errorf("T(%s, %s, %v) = %q, want %q", tt.lang, tt.key, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestErrorText(t *testing.T) {
+	redirect := &url.Error{Op: "Get", URL: "https://a.example", Err: newError("err.sub.too_many_redirects")}
+	tests := []struct {
+		name string
+		lang string
+		err  error
+		want string
+	}{
+		{"english", LangEN, newError("err.sub.no_nodes", "format", "Clash"), "no nodes found (Clash)"},
+		{"chinese", LangZH, newError("err.sub.no_nodes", "format", "Clash"), "未检测到任何节点 (Clash)"},
+		{"localized cause", LangEN, wrapError(redirect, "err.sub.unreachable"), "could not fetch the subscription: too many redirects"},
+		{"plain cause hidden", LangEN, wrapError(errors.New("dial tcp: refused"), "err.sub.unreachable"), "could not fetch the subscription"},
+		{"joined causes", LangEN, wrapError(errors.Join(errors.New("exit status 1"), newError("err.tdl.unlink_failed")), "err.tdl.undo_failed"), "smoke test failed and the switch could not be undone: could not remove the tdl symlink"},
+		{"wrapped by fmt", LangEN, fmt.Errorf("upgrade: %w", errTDLBusy), T(LangEN, "err.tdl.busy")},
+		{"plain error", LangEN, errors.New("open /tmp/x: permission denied"), "internal error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorText(tt.lang, tt.err); got != tt.want {
+				t.Errorf("errorText = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocalizedError(t *testing.T) {
+	cause := errors.New("exit status 1")
+	err := wrapError(cause, "err.tdl.checksum_mismatch", "asset", "a.tar.gz", "want", "00", "got", "ff")
+	if got, want := err.Error(), "a.tar.gz 校验失败: 期望 00，实际 ff: exit status 1"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, cause) {
+		t.Error("errors.Is(err, cause) = false")
+	}
+	if !errors.Is(fmt.Errorf("remove: %w", errAccountLast), errAccountLast) {
+		t.Error("sentinel not matched through wrapping")
+	}
+}
+
+func TestMatchLanguage(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"zh-hans", LangZH, true},
+		{"zh_TW", LangZH, true},
+		{"en-US", LangEN, true},
+		{"EN", LangEN, true},
+		{"fr", "fr", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := matchLanguage(tt.code)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("matchLanguage(%q) = %q, %v, want %q, %v", tt.code, got, ok, tt.want, tt.ok)
+		}
+	}
+}
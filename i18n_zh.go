@@ -0,0 +1,399 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+// messagesZH 中文消息目录（默认语言，其他语言以此为准校验键与占位符）
+var messagesZH = map[string]string{
+	// 通用
+	"common.no_permission": "❌ 您没有权限使用此 Bot",
+	"common.admin_only":    "❌ 该命令仅限管理员使用",
+	"common.on":            "开启",
+	"common.off":           "关闭",
+	"cmd.unknown":          "❓ 未知命令，使用 /help 查看帮助",
+	"cmd.usage":            "用法: {usage}",
+	"cmd.error":            "❌ 命令执行出错，请稍后重试",
+
+	// 命令菜单与参数
+	"cmd.desc.start":       "开始使用",
+	"cmd.desc.help":        "查看帮助",
+	"cmd.desc.status":      "检查状态",
+	"cmd.desc.queue":       "查看任务队列",
+	"cmd.desc.cancel":      "终止正在执行的任务",
+	"cmd.desc.subs":        "我的订阅",
+	"cmd.desc.unsub":       "删除订阅",
+	"cmd.desc.subinfo":     "订阅详情",
+	"cmd.desc.lang":        "切换界面语言",
+	"cmd.desc.pause":       "暂停队列",
+	"cmd.desc.resume":      "恢复队列",
+	"cmd.desc.maintenance": "开启/关闭维护模式",
+	"cmd.desc.unban":       "解除用户封禁",
+	"cmd.desc.accounts":    "管理 TDL 账号池",
+	"cmd.desc.tdl":         "查看/升级 tdl 版本",
+	"cmd.desc.notify":      "任务通知设置",
+	"arg.user":             "用户ID",
+	"arg.ref":              "链接|ID",
+	"arg.notice":           "提示信息",
+	"arg.account":          "账号名",
+	"arg.version":          "版本",
+	"arg.sha256":           "SHA-256",
+	"arg.setting":          "设置项",
+	"arg.value":            "值",
+
+	// /start /help
+	"start.welcome": "👋 你好 {name}!\n\n" +
+		"🤖 这是一个多功能机器人\n\n" +
+		"📋 支持功能:\n" +
+		"• TDL 转发 - 发送 Telegram 链接 (https://t.me/xxx)\n" +
+		"• 订阅管理 - 发送订阅链接 (http/https 格式)\n\n" +
+		"💡 直接发送链接即可，Bot 会自动识别类型",
+	"help.text": "📖 使用帮助\n\n" +
+		"1️⃣ 发送 Telegram 链接进行转发\n" +
+		"   格式: https://t.me/channel/123\n\n" +
+		"2️⃣ 发送订阅链接进行添加\n" +
+		"   格式: 任意 http/https 链接 (非 t.me)\n\n" +
+		"3️⃣ 支持的命令:\n" +
+		"{commands}\n\n" +
+		"❓ 遇到问题请联系管理员",
+
+	// /lang
+	"lang.current":     "🌐 当前语言: {lang}\n用法: /lang {options}|auto",
+	"lang.auto":        "🌐 已恢复自动识别，当前语言: {lang}",
+	"lang.unsupported": "⚠️ 不支持的语言: {lang}",
+	"lang.set":         "🌐 界面语言已设置为: {lang}",
+
+	// /status
+	"status.script_missing": "❌ 未找到",
+	"status.script_found":   "✅ 存在",
+	"status.idle":           "空闲",
+	"status.busy":           "处理中",
+	"status.processing":     "\n⚡ 正在处理: 任务 #{id} (用户 {user})",
+	"status.text": "✅ Bot 运行正常\n" +
+		"📁 TDL 脚本: {script} ({script_state})\n" +
+		"🌐 订阅 API: {backends} (待提交 {outbox} 个)\n" +
+		"👤 当前用户: {user}\n" +
+		"📊 队列模式: 排队执行 (一次一个)\n" +
+		"⏯ 队列控制: {control}\n" +
+		"🔄 当前状态: {state}\n" +
+		"📋 等待队列: {queue} 个任务{processing}\n\n" +
+		"👥 账号池 ({strategy}):\n{accounts}\n" +
+		"🩺 会话检查: {health}\n" +
+		"🧰 tdl: {tdl}",
+	"breaker.closed":  "正常",
+	"breaker.open":    "熔断中",
+	"breaker.probing": "探测中",
+
+	// 消息处理
+	"msg.restarting":  "⏸ 服务正在重启，请稍后再发送链接",
+	"msg.invalid":     "⚠️ 请发送以下类型的链接:\n{handlers}",
+	"links.unmatched": "⚠️ 无法处理 {count} 条链接:\n{links}\n\n可以发送以下类型的链接:\n{handlers}",
+	"links.none":      "(您没有可用的链接类型，请联系管理员)",
+
+	"links.handler.telegram":     "Telegram 链接 (https://t.me/...)",
+	"links.handler.subscription": "订阅链接 (http/https 格式)",
+	"file.too_large":             "❌ 文件过大 (超过 {size} KB)",
+	"file.download_failed":       "❌ 下载文件失败，请稍后重试",
+	"queue.full":                 "⚠️ 任务队列已满，请稍后再试",
+
+	// 优先级
+	"priority.low":           "低",
+	"priority.normal":        "普通",
+	"priority.high":          "高",
+	"priority.label":         " ({name}优先级)",
+	"priority.no_permission": "⚠️ 您没有提升优先级的权限，已按默认优先级排队",
+
+	// 任务状态
+	"task.ref":               "任务 #{id}",
+	"task.queued":            "⏳ 任务 #{id} - 已加入队列",
+	"task.position":          "📋 当前排队位置: 第 {pos} 位{priority}",
+	"task.position_paused":   "⏸ 队列已暂停，当前排队位置: 第 {pos} 位",
+	"task.starting_soon":     "⚡ 即将开始处理",
+	"task.processing":        "⏳ 已接收请求，处理中...",
+	"task.processing_id":     "⏳ 任务 #{id} - 已接收请求，处理中...",
+	"task.start_failed":      "❌ 任务 #{id} 启动失败",
+	"task.login_console":     "🔐 任务 #{id} - 需要登录\n\n📺 请到服务器控制台查看二维码并使用 Telegram 扫描登录\n\n⏰ 登录后任务将自动继续",
+	"task.login_link":        "🔐 任务 #{id} - 需要登录\n\n📱 请点击以下链接在 Telegram 中完成登录:\n{link}\n\n⏰ 登录后任务将自动继续",
+	"task.terminated":        "❌ 任务 #{id} 已被用户终止",
+	"task.timeout":           "❌ 任务 #{id} 执行超时 ({reason})",
+	"task.failed":            "⚠️ 任务 #{id} 执行失败",
+	"task.done":              "✅ 任务 #{id} 处理完成",
+	"task.done_default":      "已完成",
+	"task.cancelled_summary": "❌ 任务 #{id} 已从汇总取消",
+	"task.cancelled_queue":   "❌ 任务 #{id} 已从队列中取消",
+	"task.cancelled_admin":   "❌ 任务 #{id} 已被管理员取消",
+	"task.pinned":            "⬆️ 已被管理员移至队首，即将开始处理",
+	"task.priority_changed":  "🎚 管理员已将优先级调整为{name}",
+	"task.shutdown_paused":   "⏸ 服务重启，任务已暂停/已保存",
+	"task.restored":          "🔄 服务已恢复，任务重新排队",
+	"timeout.stall":          "无进度超时",
+	"timeout.deadline":       "总时长超时",
+	"timeout.login":          "等待登录超时",
+	"btn.cancel_task":        "🛑 终止任务",
+	"btn.cancel_all":         "🛑 终止全部任务",
+
+	// 按钮回调
+	"cb.invalid_task":          "⚠️ 无效的任务标识",
+	"cb.no_permission_summary": "❌ 您无权终止此任务汇总",
+	"cb.no_permission_task":    "❌ 您无权终止此任务",
+	"cb.task_gone":             "⚠️ 任务已完成或不存在",
+
+	// /queue /cancel
+	"queue.title":                "📋 任务队列 (等待 {count} 个)",
+	"queue.running":              "⚡ 正在执行: [#{id}] {link}",
+	"queue.owner_inline":         "(用户 {user})",
+	"queue.running_other":        "⚡ 正在执行其他用户的任务",
+	"queue.pinned":               "📌 已置顶",
+	"queue.priority":             "🎚 {name}优先级",
+	"queue.aged":                 "(等待已久，按{name}优先级排序)",
+	"queue.owner":                "👤 用户 {user}",
+	"queue.btn_cancel":           "❌ 取消 #{id}",
+	"queue.btn_top":              "⬆️ 置顶",
+	"queue.btn_priority":         "🎚 优先级",
+	"queue.btn_cancel_mine":      "🗑 取消我的全部任务",
+	"queue.btn_refresh":          "🔄 刷新",
+	"queue.empty":                "📭 队列为空",
+	"queue.empty_mine":           "📭 您没有排队中的任务",
+	"queue.truncated":            "… 仅显示前 {count} 个任务",
+	"queue.cancelled_mine":       "已取消 {count} 个排队任务",
+	"queue.cancelled":            "已取消任务 #{id}",
+	"queue.no_permission_cancel": "❌ 您无权取消此任务",
+	"queue.task_started":         "⚠️ 任务已开始执行或不存在",
+	"queue.unknown_action":       "⚠️ 未知操作",
+	"queue.admin_only_order":     "❌ 只有管理员可以调整队列顺序",
+	"queue.admin_only_priority":  "❌ 只有管理员可以调整任务优先级",
+	"queue.moved_top":            "任务 #{id} 已移至队首",
+	"queue.priority_set":         "任务 #{id} 优先级: {name}",
+	"cancel.none":                "⚠️ 您没有正在执行的任务\n使用 /queue 管理排队中的任务",
+	"cancel.done":                "🛑 任务 #{id} 已终止",
+
+	// 限流与封禁
+	"ratelimit.notice":       "⏳ 消息发送过于频繁，请稍后再试",
+	"ratelimit.banned":       "🚫 由于频繁发送消息，您已被临时封禁至 {until}",
+	"ratelimit.admin_banned": "🚫 用户 {user} ({name}) 因频繁发送消息被临时封禁至 {until}\n使用 /unban {user} 解除",
+	"ratelimit.unbanned":     "✅ 已解除用户 {user} 的封禁",
+	"ratelimit.not_banned":   "⚠️ 用户 {user} 未被封禁",
+
+	// 转发消息
+	"task.album":        "(相册 {count} 条)",
+	"forward.no_source": "⚠️ 无法获取这条转发消息的来源 (来源已隐藏，或不是来自频道/超级群组)\n请发送消息链接",
+	"forward.expired":   "⚠️ 该确认已过期，请重新发送链接",
+	"forward.not_owner": "❌ 只有发送者可以确认",
+
+	// 转发前预览
+	"preview.checking":       "🔍 正在检查来源...\n{link}",
+	"preview.waiting":        "⏳ 等待当前任务完成后检查来源...\n{link}",
+	"preview.summary":        "🔍 来源预览\n{link}\n📨 消息: {count} 条\n🗂 媒体: {media}\n💾 总大小: 约 {size}\n\n确认开始转发？",
+	"preview.failed":         "⚠️ 无法获取来源预览: {error}\n{link}\n\n仍要开始转发？",
+	"preview.inaccessible":   "❌ 用户账号无法访问该来源 (私有、不存在或未加入)，已取消\n{link}",
+	"preview.btn_start":      "▶️ 开始",
+	"preview.btn_cancel":     "❌ 取消",
+	"preview.started":        "▶️ 已加入队列",
+	"preview.at_least":       "至少 {count}",
+	"preview.cancelled":      "❌ 已取消\n{link}",
+	"preview.media.photo":    "图片 {count}",
+	"preview.media.video":    "视频 {count}",
+	"preview.media.audio":    "音频 {count}",
+	"preview.media.document": "文件 {count}",
+	"preview.media.text":     "文本 {count}",
+	"preview.media.none":     "无",
+
+	// 重复转发
+	"dedup.header":    "⚠️ 以下链接已转发过，已跳过：",
+	"dedup.line":      "🔁 已转发过 ({date}, 任务 #{id})",
+	"dedup.btn_force": "🔁 仍然转发",
+	"dedup.forced":    "✅ 已重新加入队列",
+
+	// 群组
+	"group.no_permission": "❌ 您没有在本群提交任务的权限",
+
+	// 账号池
+	"accounts.list":            "👥 账号池 (策略: {strategy})\n\n{accounts}\n\n用法: /accounts add|remove|reset 账号名",
+	"accounts.line":            "• {name}: {state} (任务 {tasks}, 限流 {floods} 次)",
+	"accounts.available":       "✅ 可用",
+	"accounts.cooldown":        "⏳ 暂停至 {until} ({reason})",
+	"accounts.reason.flood":    "限流",
+	"accounts.reason.failures": "连续失败",
+	"accounts.reason.auth":     "会话失效或被封禁",
+	"accounts.reason.":         "未知原因",
+	"accounts.waiting":         "⏳ 所有账号暂停中，预计 {until} 恢复后开始",
+	"accounts.in_use":          "⚠️ 账号 {name} 正在执行当前任务，请稍后再删除",
+	"accounts.failed":          "❌ 操作失败: {error}",
+	"accounts.done_add":        "✅ 已添加账号 {name}，请在服务器上执行 tdl login -n {name} 登录",
+	"accounts.done_remove":     "✅ 已删除账号 {name}",
+	"accounts.done_reset":      "✅ 已恢复账号 {name}",
+	// 任务通知
+	"notify.event.queued":         "📥 任务 #{id} 已加入队列",
+	"notify.event.started":        "▶️ 任务 #{id} 开始执行",
+	"notify.event.login_required": "🔐 任务 #{id} 需要登录，请联系管理员",
+	"notify.event.completed":      "✅ 任务 #{id} 已完成",
+	"notify.event.failed":         "❌ 任务 #{id} 失败",
+	"notify.event.cancelled":      "🛑 任务 #{id} 已取消",
+	"notify.admin_user":           "👤 用户: {user}",
+	"notify.email_subject":        "[tgbot] {event}",
+	"notify.settings": "🔔 任务通知设置\n\n" +
+		"Telegram 新消息: {telegram}\n" +
+		"静默发送: {silent}\n" +
+		"邮件: {email}\n" +
+		"事件: {events}\n\n" +
+		"用法:\n" +
+		"/notify on|off - 开启/关闭 Telegram 新消息通知\n" +
+		"/notify silent on|off - 静默发送\n" +
+		"/notify events completed,failed - 选择事件 (all 为全部: {all})\n" +
+		"/notify email 地址|off - 邮件通知",
+	"notify.saved":          "✅ 通知设置已保存",
+	"notify.bad_events":     "⚠️ 未知事件，可选: {events} 或 all",
+	"notify.bad_email":      "⚠️ 邮件地址格式不正确",
+	"notify.email_disabled": "⚠️ 未配置邮件服务器，无法发送邮件通知",
+
+	// 生命周期钩子
+	"hooks.vetoed":         "🚫 任务未加入队列: {reason}\n{link}",
+	"hooks.no_reason":      "被钩子拒绝",
+	"hooks.album_in_batch": "钩子返回了相册，批量提交的链接不能改写为相册",
+	"hooks.bad_rewrite":    "钩子改写后的链接不是 Telegram 链接: {link}",
+
+	// tdl 版本
+	"tdl.status":            "🧰 tdl 当前版本: {version}\n上一个版本: {previous}\n固定版本: {pinned}\n\n用法: /tdl upgrade v1.2.3 [SHA-256] | /tdl rollback",
+	"tdl.working":           "⏳ 正在切换 tdl 版本，期间暂停启动新任务...",
+	"tdl.in_progress":       "⏳ 正在切换 tdl 版本，请稍候",
+	"tdl.done":              "✅ tdl 已切换到 {version}",
+	"tdl.checksum_required": "❌ TDLChecksums 中没有 {version} 的校验值，请在命令中提供发布压缩包的 SHA-256: /tdl upgrade {version} <SHA-256>",
+	"tdl.bad_checksum":      "❌ SHA-256 应为 64 位十六进制字符",
+	"tdl.failed":            "❌ 切换 tdl 版本失败: {error}",
+	"tdl.unmanaged":         "⚠️ 未启用 tdl 版本管理 (TDLManaged = false)，版本由 tdl.sh 自行更新",
+	"tdl.state_script":      "由 tdl.sh 自动更新",
+	"tdl.state_upgrading":   "⏳ 正在切换版本",
+	"tdl.state_missing":     "❌ 未安装",
+
+	// 会话检查
+	"health.unchecked":          "尚未检查",
+	"health.ok":                 "✅ 正常 ({time})",
+	"health.failed":             "❌ {accounts} ({time})",
+	"health.state.unauthorized": "未登录或会话失效",
+	"health.state.unreachable":  "转发目标无法访问",
+	"health.state.error":        "检查失败",
+	"health.held":               "⏸ 转发暂停 ({reason})，管理员恢复后自动开始，当前排队位置: 第 {pos} 位",
+	"health.alert_unauthorized": "🔐 会话检查: 账号 {name} 未登录或会话已失效\n请在服务器上执行 tdl login -n {name} 重新登录",
+	"health.alert_unreachable":  "🚫 会话检查: 账号 {name} 无法访问转发目标 {target}\n请确认账号仍在目标频道/群组中",
+	"health.alert_restored":     "✅ 会话检查: 账号 {name} 已恢复正常",
+	"accounts.admin_alert":      "⚠️ 账号 {name} 已暂停使用: {reason}\n重新登录后使用 /accounts reset {name} 恢复",
+
+	// /pause /resume /maintenance
+	"control.paused":              "⏸ 队列已暂停：新链接仍会排队，但不会开始执行\n当前任务会继续运行至结束，使用 /resume 恢复",
+	"control.resumed":             "▶️ 队列已恢复，等待中的任务: {count} 个",
+	"control.resumed_maintenance": "⚠️ 维护模式仍开启，任务将在 /maintenance off 后开始执行",
+	"control.state_paused":        "⏸ 已暂停",
+	"control.state_maintenance":   "🛠 维护中",
+	"control.state_running":       "▶️ 运行中",
+	"control.since":               "(自 {time})",
+	"maintenance.default":         "Bot 正在维护中，暂不接受新任务，请稍后再试",
+	"maintenance.status":          "🛠 维护模式: {state}",
+	"maintenance.usage":           "用法: /maintenance on|off [提示信息]",
+	"maintenance.enabled":         "🛠 维护模式已开启，不再启动新任务，普通用户将收到提示:\n{notice}",
+	"maintenance.disabled":        "✅ 维护模式已关闭",
+	"maintenance.still_paused":    "⚠️ 队列仍处于暂停状态，使用 /resume 恢复",
+
+	// 订阅提交
+	"subformat.uri_list":     "URI 列表",
+	"sub.checking":           "🔍 正在检测订阅内容...",
+	"sub.invalid":            "❌ 订阅内容无效: {error}",
+	"sub.preview":            "🔍 检测到 {count} 个节点 ({format})\n{url}\n\n是否提交该订阅?",
+	"sub.btn_confirm":        "✅ 确认提交",
+	"sub.btn_cancel":         "❌ 取消",
+	"sub.btn_submit_valid":   "✅ 提交 {count} 个有效订阅",
+	"sub.preview_expired":    "⚠️ 该预览已过期，请重新发送链接",
+	"sub.not_owner":          "❌ 您无权操作此订阅",
+	"sub.cancelled":          "🚫 已取消提交",
+	"sub.adding":             "⏳ 正在添加订阅...",
+	"sub.batch_checking":     "🔍 检测中...",
+	"sub.batch_nodes":        "🔍 {count} 个节点 ({format})",
+	"sub.batch_none_valid":   "❌ 没有可提交的有效订阅",
+	"sub.batch_submitting":   "⏳ 正在提交...",
+	"sub.batch_suffix":       "({count} 个节点, {format})",
+	"sub.timeout":            "❌ 请求超时，请稍后重试",
+	"sub.unreachable":        "❌ 无法连接到服务器",
+	"sub.queued_unavailable": "⏳ 订阅 API 暂时不可用，已加入待提交队列",
+	"sub.queued_unreachable": "⏳ 无法连接到服务器，已加入待提交队列",
+	"sub.retry_exhausted":    "❌ 多次重试后仍无法提交 ({error})",
+	"sub.deferred":           "(延迟提交)",
+
+	// /subs /unsub /subinfo
+	"subs.list_failed":        "❌ 获取订阅列表失败，请稍后重试",
+	"subs.empty":              "📭 您还没有添加过订阅",
+	"subs.title":              "📚 我的订阅 (共 {count} 个, 第 {page}/{pages} 页)",
+	"subs.local_only":         "⚠️ 无法连接订阅 API，以下为本地记录",
+	"subs.missing_in_api":     "(API 中已不存在)",
+	"subs.hint":               "💡 /subinfo <链接|ID> 查看详情, /unsub <链接|ID> 删除",
+	"subs.btn_prev":           "◀️ 上一页",
+	"subs.btn_next":           "下一页 ▶️",
+	"subs.invalid_page":       "⚠️ 无效的页码",
+	"subs.not_owner":          "❌ 只能查看自己的订阅",
+	"subs.not_found":          "⚠️ 未找到该订阅，或该订阅不是由您添加的",
+	"unsub.failed":            "❌ 删除订阅失败: {error}",
+	"unsub.done":              "🗑 订阅已删除\n{url}",
+	"unsub.not_in_api":        "🗑 订阅 API 中已没有该订阅，已删除本地记录\n{url}",
+	"unsub.unsupported":       "❌ 订阅后端未提供删除接口，无法删除该订阅 (本地记录已保留)",
+	"subs.list_unsupported":   "⚠️ 订阅后端未提供列表接口，以下为本地记录，无法确认是否仍存在",
+	"subinfo.api_unsupported": "❓ 订阅后端未提供列表接口",
+	"subinfo.api_exists":      "✅ 存在",
+	"subinfo.api_unreachable": "❓ 无法连接订阅 API",
+	"subinfo.api_missing":     "❌ 已不存在",
+	"subinfo.title":           "🔎 订阅详情",
+	"subinfo.link":            "🔗 链接: {url}",
+	"subinfo.id":              "🆔 ID: {id}",
+	"subinfo.name":            "🏷 名称: {name}",
+	"subinfo.nodes":           "📦 节点数: {count}",
+	"subinfo.backend":         "🗄 后端: {name}",
+	"subinfo.added_at":        "🕒 添加时间: {time}",
+	"subinfo.api_state":       "🌐 API 状态: {state}",
+
+	// 错误说明
+	"err.internal":               "内部错误",
+	"err.sub.invalid_url":        "无效的订阅链接",
+	"err.sub.private":            "订阅链接指向内网或本机地址，不允许访问",
+	"err.sub.timeout":            "获取订阅超时",
+	"err.sub.unreachable":        "无法获取订阅内容",
+	"err.sub.too_many_redirects": "重定向次数过多",
+	"err.sub.bad_redirect":       "不支持的重定向协议: {scheme}",
+	"err.sub.http_status":        "获取订阅失败 (状态码: {status})",
+	"err.sub.read_failed":        "读取订阅内容失败",
+	"err.sub.too_large":          "订阅内容过大 (超过 {size} KB)",
+	"err.sub.empty":              "订阅内容为空",
+	"err.sub.unknown_format":     "无法识别的订阅格式",
+	"err.sub.no_nodes":           "未检测到任何节点 ({format})",
+	"err.sub.bad_json":           "无效的 JSON 订阅内容",
+	"err.sub.api_status":         "订阅 API 返回错误 (状态码: {status})",
+	"err.sub.delete_status":      "删除订阅失败 (状态码: {status})",
+	"err.sub.backend_message":    "订阅后端返回: {message}",
+	"err.preview.bad_link":       "无法解析链接",
+	"err.preview.timeout":        "检查超时 ({timeout})",
+	"err.preview.export_failed":  "tdl 导出失败",
+	"err.preview.bad_export":     "解析导出结果失败",
+	"err.accounts.all_paused":    "所有账号暂停中，预计 {time} 恢复",
+	"err.accounts.exists":        "账号已存在",
+	"err.accounts.not_found":     "账号不存在",
+	"err.accounts.invalid":       "账号名只能包含字母、数字、_ 和 -，最长 32 个字符",
+	"err.accounts.last":          "不能删除最后一个账号",
+	"err.tdl.busy":               "有任务、预览或会话检查正在使用 tdl，请稍后再试",
+	"err.tdl.bad_version":        "版本号格式应为 v1.2.3",
+	"err.tdl.checksum_required":  "需要提供 SHA-256 校验值，或在 TDLChecksums 中配置",
+	"err.tdl.unsupported_arch":   "不支持此 CPU 架构: {arch}",
+	"err.tdl.no_previous":        "没有可回滚的版本",
+	"err.tdl.smoke_failed":       "冒烟测试失败",
+	"err.tdl.smoke_not_switched": "冒烟测试失败，未切换到 {version}",
+	"err.tdl.smoke_rolled_back":  "冒烟测试失败，已回滚到 {version}",
+	"err.tdl.smoke_kept":         "冒烟测试失败，保持 {version}",
+	"err.tdl.undo_failed":        "冒烟测试失败，撤销切换失败",
+	"err.tdl.rollback_failed":    "冒烟测试失败，回滚失败",
+	"err.tdl.version_failed":     "tdl version 执行失败",
+	"err.tdl.session_failed":     "账号 {name} 会话检查未通过 ({state})",
+	"err.tdl.symlink_failed":     "创建符号链接失败",
+	"err.tdl.switch_failed":      "切换 tdl 版本失败",
+	"err.tdl.unlink_failed":      "移除 tdl 符号链接失败",
+	"err.tdl.offline_no_cache":   "离线模式下缓存中没有 {version}",
+	"err.tdl.offline_checksum":   "离线模式需要在 TDLChecksums 中配置 {version}/{asset} 的校验值",
+	"err.tdl.checksum_missing":   "校验文件中没有 {asset}",
+	"err.tdl.checksum_mismatch":  "{asset} 校验失败: 期望 {want}，实际 {got}",
+	"err.tdl.download_failed":    "下载 {name} 失败",
+	"err.tdl.extract_failed":     "解压失败",
+	"err.tdl.not_in_archive":     "压缩包中没有 tdl",
+}
@@ -0,0 +1,260 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// LinkHandler 处理消息中的一类链接。新的链接类型 (直链文件、其他服务、自定义订阅格式等)
+// 实现该接口并加入 linkHandlers 即可，不需要修改 handleMessage
+type LinkHandler interface {
+	// Name 处理器名称，用于 LinkHandlerOrder 和 UserLinkHandlers
+	Name() string
+	// Match 是否由该处理器处理这条链接
+	Match(link string) bool
+	// Describe 向用户说明可以发送的链接类型，显示在无法识别链接时的提示中
+	Describe(lang string) string
+	// Handle 处理一条消息中分配给该处理器的全部链接 (按消息中的顺序)
+	Handle(b *Bot, message *tgbotapi.Message, links []string)
+}
+
+// linkHandlers 已注册的链接处理器，默认按注册顺序匹配
+var linkHandlers = []LinkHandler{
+	telegramLinkHandler{},
+	subscriptionLinkHandler{},
+}
+
+// linkRouter 按顺序与用户配置为链接选择处理器
+type linkRouter struct {
+	handlers []LinkHandler             // 已排序
+	users    map[int64]map[string]bool // 用户 -> 允许使用的处理器，未配置的用户可以使用全部处理器
+}
+
+// newLinkRouter 根据 LinkHandlerOrder 排序处理器，未列出的处理器按注册顺序排在最后
+func newLinkRouter(handlers []LinkHandler, order []string, users map[int64][]string) (*linkRouter, error) {
+	byName := make(map[string]int, len(handlers))
+	for i, h := range handlers {
+		if _, dup := byName[h.Name()]; dup {
+			return nil, fmt.Errorf("链接处理器名称重复: %s", h.Name())
+		}
+		byName[h.Name()] = i
+	}
+
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("LinkHandlerOrder 引用了不存在的链接处理器: %s", name)
+		}
+		rank[name] = i
+	}
+	rankOf := func(h LinkHandler) int {
+		if i, ok := rank[h.Name()]; ok {
+			return i
+		}
+		return len(order) + byName[h.Name()]
+	}
+
+	r := &linkRouter{
+		handlers: append([]LinkHandler(nil), handlers...),
+		users:    make(map[int64]map[string]bool, len(users)),
+	}
+	sort.SliceStable(r.handlers, func(i, j int) bool { return rankOf(r.handlers[i]) < rankOf(r.handlers[j]) })
+
+	for userID, names := range users {
+		enabled := make(map[string]bool, len(names))
+		for _, name := range names {
+			if _, ok := byName[name]; !ok {
+				return nil, fmt.Errorf("UserLinkHandlers 中用户 %d 引用了不存在的链接处理器: %s", userID, name)
+			}
+			enabled[name] = true
+		}
+		r.users[userID] = enabled
+	}
+	return r, nil
+}
+
+// For 返回用户可以使用的处理器，按匹配顺序
+func (r *linkRouter) For(userID int64) []LinkHandler {
+	enabled, ok := r.users[userID]
+	if !ok {
+		return r.handlers
+	}
+	var handlers []LinkHandler
+	for _, h := range r.handlers {
+		if enabled[h.Name()] {
+			handlers = append(handlers, h)
+		}
+	}
+	return handlers
+}
+
+// linkRoute 分配给同一个处理器的链接
+type linkRoute struct {
+	handler LinkHandler
+	links   []string
+}
+
+// Route 把每条链接分配给用户可用的第一个匹配的处理器，返回各处理器的链接 (按处理器顺序) 与未匹配的链接
+func (r *linkRouter) Route(userID int64, links []string) ([]linkRoute, []string) {
+	handlers := r.For(userID)
+	routes := make([]linkRoute, len(handlers))
+	for i, h := range handlers {
+		routes[i].handler = h
+	}
+	var unmatched []string
+	for _, link := range links {
+		matched := false
+		for i, h := range handlers {
+			if h.Match(link) {
+				routes[i].links = append(routes[i].links, link)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, link)
+		}
+	}
+
+	var used []linkRoute
+	for _, route := range routes {
+		if len(route.links) > 0 {
+			used = append(used, route)
+		}
+	}
+	return used, unmatched
+}
+
+// Describe 用户可以发送的链接类型，每行一个
+func (r *linkRouter) Describe(userID int64, lang string) string {
+	handlers := r.For(userID)
+	if len(handlers) == 0 {
+		return T(lang, "links.none")
+	}
+	lines := make([]string, len(handlers))
+	for i, h := range handlers {
+		lines[i] = "• " + h.Describe(lang)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ==================== 内置处理器 ====================
+
+// isTelegramLink 链接是否指向 t.me
+func isTelegramLink(link string) bool {
+	u, err := url.Parse(link)
+	return err == nil && strings.EqualFold(u.Hostname(), "t.me")
+}
+
+// telegramLinkHandler t.me 链接：通过 tdl 转发到目标频道
+type telegramLinkHandler struct{}
+
+func (telegramLinkHandler) Name() string { return "telegram" }
+
+func (telegramLinkHandler) Match(link string) bool { return isTelegramLink(link) }
+
+func (telegramLinkHandler) Describe(lang string) string { return T(lang, "links.handler.telegram") }
+
+func (telegramLinkHandler) Handle(b *Bot, message *tgbotapi.Message, links []string) {
+	user := message.From
+	lang := b.userLang(user)
+	links = dedupeTelegramLinks(links)
+	b.logger.Debug("检测到 Telegram 链接", "user_id", user.ID, "count", len(links))
+
+	// 确定任务优先级（用户默认值或消息末尾的 !high / !low 标记）
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	priority, denied := taskPriorityFor(user.ID, text)
+	if denied {
+		b.replyText(message, T(lang, "priority.no_permission"))
+	}
+
+	// 已转发过的链接单独提示，可点击"仍然转发"；频道等较大的来源先预览，确认后再加入队列
+	links = b.skipForwarded(message, links, priority, false)
+	b.enqueueLinks(message, b.previewSources(message, links, priority, false), priority)
+}
+
+// subscriptionLinkHandler 其他 http(s) 链接：检测后提交到订阅后端
+type subscriptionLinkHandler struct{}
+
+func (subscriptionLinkHandler) Name() string { return "subscription" }
+
+func (subscriptionLinkHandler) Match(link string) bool {
+	lower := strings.ToLower(link)
+	return (strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")) && !isTelegramLink(link)
+}
+
+func (subscriptionLinkHandler) Describe(lang string) string {
+	return T(lang, "links.handler.subscription")
+}
+
+func (subscriptionLinkHandler) Handle(b *Bot, message *tgbotapi.Message, links []string) {
+	user := message.From
+	links = dedupeSubscriptionLinks(links)
+	if len(links) == 1 {
+		b.logger.Info("检测到订阅链接", "user_id", user.ID, "sub_url", links[0])
+
+		// 先检测订阅内容（需要下载订阅，异步执行避免阻塞消息处理），用户确认后再提交
+		go b.previewSubscription(message, links[0])
+		return
+	}
+	b.logger.Info("检测到多个订阅链接", "user_id", user.ID, "count", len(links))
+	go b.handleSubscriptionBatch(message, links)
+}
+
+// ==================== 消息分发 ====================
+
+// routeForwarded 转发消息的来源链接同样按处理器顺序与用户配置分发。
+// 由 telegram 处理器处理时返回 true，由调用方按转发消息处理 (合并相册、不解析优先级标记)；
+// 否则与消息中的链接一样交给对应的处理器，或回复可用的链接类型
+func (b *Bot) routeForwarded(message *tgbotapi.Message, links []string) bool {
+	routes, _ := b.linkRouter.Route(message.From.ID, links)
+	if len(routes) > 0 {
+		if _, ok := routes[0].handler.(telegramLinkHandler); ok {
+			return true
+		}
+	}
+	b.dispatchLinks(message, links)
+	return false
+}
+
+// dispatchLinks 把消息中的链接交给处理器，无法识别的链接回复可用的链接类型。
+// 默认只由第一个匹配到链接的处理器处理整条消息，LinkDispatchAll 为 true 时每个处理器分别处理各自的链接
+func (b *Bot) dispatchLinks(message *tgbotapi.Message, links []string) {
+	user := message.From
+	routes, unmatched := b.linkRouter.Route(user.ID, links)
+	if !LinkDispatchAll && len(routes) > 0 {
+		if len(routes) > 1 || len(unmatched) > 0 {
+			b.logger.Debug("忽略其他处理器的链接", "user_id", user.ID, "handler", routes[0].handler.Name(), "ignored", len(links)-len(routes[0].links))
+		}
+		routes, unmatched = routes[:1], nil
+	}
+	for _, route := range routes {
+		b.logger.Debug("链接交给处理器", "user_id", user.ID, "handler", route.handler.Name(), "count", len(route.links))
+		route.handler.Handle(b, message, route.links)
+	}
+	if len(unmatched) == 0 {
+		return
+	}
+
+	b.logger.Info("存在无法处理的链接", "user_id", user.ID, "count", len(unmatched))
+	lang := b.userLang(user)
+	shown := unmatched
+	if len(shown) > 5 {
+		shown = shown[:5]
+	}
+	b.replyText(message, T(lang, "links.unmatched",
+		"links", strings.Join(shown, "\n"),
+		"count", len(unmatched),
+		"handlers", b.linkRouter.Describe(user.ID, lang),
+	))
+}
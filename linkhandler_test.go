@@ -0,0 +1,210 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeLinkHandler 按前缀匹配链接并记录收到的链接
+type fakeLinkHandler struct {
+	name   string
+	prefix string
+	got    *[][]string
+}
+
+func (h fakeLinkHandler) Name() string { return h.name }
+
+func (h fakeLinkHandler) Match(link string) bool { return strings.HasPrefix(link, h.prefix) }
+
+func (h fakeLinkHandler) Describe(lang string) string { return h.name }
+
+func (h fakeLinkHandler) Handle(b *Bot, message *tgbotapi.Message, links []string) {
+	if h.got != nil {
+		*h.got = append(*h.got, append([]string{h.name}, links...))
+	}
+}
+
+func handlerNames(handlers []LinkHandler) []string {
+	names := make([]string, len(handlers))
+	for i, h := range handlers {
+		names[i] = h.Name()
+	}
+	return names
+}
+
+func TestNewLinkRouterOrder(t *testing.T) {
+	handlers := []LinkHandler{
+		fakeLinkHandler{name: "a"},
+		fakeLinkHandler{name: "b"},
+		fakeLinkHandler{name: "c"},
+	}
+	tests := []struct {
+		name  string
+		order []string
+		want  []string
+	}{
+		{name: "registration order", want: []string{"a", "b", "c"}},
+		{name: "full order", order: []string{"c", "a", "b"}, want: []string{"c", "a", "b"}},
+		{name: "unlisted last", order: []string{"c"}, want: []string{"c", "a", "b"}},
+		{name: "unlisted keep registration order", order: []string{"b"}, want: []string{"b", "a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newLinkRouter(handlers, tt.order, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := handlerNames(r.handlers); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("order = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLinkRouterErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		handlers []LinkHandler
+		order    []string
+		users    map[int64][]string
+	}{
+		{name: "duplicate name", handlers: []LinkHandler{fakeLinkHandler{name: "a"}, fakeLinkHandler{name: "a"}}},
+		{name: "unknown order", handlers: []LinkHandler{fakeLinkHandler{name: "a"}}, order: []string{"x"}},
+		{name: "unknown user handler", handlers: []LinkHandler{fakeLinkHandler{name: "a"}}, users: map[int64][]string{1: {"x"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newLinkRouter(tt.handlers, tt.order, tt.users); err == nil {
+				t.Error("newLinkRouter succeeded, want error")
+			}
+		})
+	}
+}
+
+func TestLinkRouterRoute(t *testing.T) {
+	handlers := []LinkHandler{
+		fakeLinkHandler{name: "tme", prefix: "https://t.me/"},
+		fakeLinkHandler{name: "http", prefix: "https://"},
+	}
+	r, err := newLinkRouter(handlers, nil, map[int64][]string{2: {"http"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	links := []string{"https://a.example/sub", "https://t.me/c/1", "ftp://x", "https://t.me/c/2"}
+
+	tests := []struct {
+		name      string
+		userID    int64
+		routes    [][]string // 处理器名称 + 链接
+		unmatched []string
+	}{
+		{
+			name:      "all handlers",
+			userID:    1,
+			routes:    [][]string{{"tme", "https://t.me/c/1", "https://t.me/c/2"}, {"http", "https://a.example/sub"}},
+			unmatched: []string{"ftp://x"},
+		},
+		{
+			name:      "user limited to http",
+			userID:    2,
+			routes:    [][]string{{"http", "https://a.example/sub", "https://t.me/c/1", "https://t.me/c/2"}},
+			unmatched: []string{"ftp://x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, unmatched := r.Route(tt.userID, links)
+			var got [][]string
+			for _, route := range routes {
+				got = append(got, append([]string{route.handler.Name()}, route.links...))
+			}
+			if !reflect.DeepEqual(got, tt.routes) || !reflect.DeepEqual(unmatched, tt.unmatched) {
+				t.Errorf("Route = %v, %v, want %v, %v", got, unmatched, tt.routes, tt.unmatched)
+			}
+		})
+	}
+}
+
+func TestDispatchLinks(t *testing.T) {
+	links := []string{"https://a.example/sub", "https://t.me/c/1", "ftp://x"}
+	tests := []struct {
+		name    string
+		all     bool
+		links   []string
+		handled [][]string
+		replies int
+	}{
+		{name: "first handler wins", links: links, handled: [][]string{{"tme", "https://t.me/c/1"}}},
+		{name: "dispatch all", all: true, links: links, handled: [][]string{{"tme", "https://t.me/c/1"}, {"http", "https://a.example/sub"}}, replies: 1},
+		{name: "nothing matched", links: []string{"ftp://x"}, replies: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := LinkDispatchAll
+			LinkDispatchAll = tt.all
+			defer func() { LinkDispatchAll = saved }()
+
+			b, fake := newTestBot(t)
+			var handled [][]string
+			r, err := newLinkRouter([]LinkHandler{
+				fakeLinkHandler{name: "tme", prefix: "https://t.me/", got: &handled},
+				fakeLinkHandler{name: "http", prefix: "https://", got: &handled},
+			}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.linkRouter = r
+
+			b.dispatchLinks(testMessage(), tt.links)
+			if !reflect.DeepEqual(handled, tt.handled) {
+				t.Errorf("handled = %v, want %v", handled, tt.handled)
+			}
+			if got := len(fake.sent()); got != tt.replies {
+				t.Errorf("replies = %d, want %d", got, tt.replies)
+			}
+		})
+	}
+}
+
+func TestForwardedMessageUsesLinkRouter(t *testing.T) {
+	tests := []struct {
+		name   string
+		users  map[int64][]string
+		queued int
+	}{
+		{name: "telegram enabled", queued: 1},
+		{name: "telegram disabled", users: map[int64][]string{42: {"subscription"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			r, err := newLinkRouter(linkHandlers, nil, tt.users)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.linkRouter = r
+
+			message := testMessage()
+			message.ForwardFromChat = &tgbotapi.Chat{ID: -100123, Type: "channel"}
+			message.ForwardFromMessageID = 5
+			link, ok := forwardSourceLink(message)
+			if !ok {
+				t.Fatal("forwardSourceLink failed")
+			}
+			b.handleForwardedMessage(message, link)
+
+			if got := len(queuedLinks(b)); got != tt.queued {
+				t.Errorf("queued = %d, want %d", got, tt.queued)
+			}
+			if tt.queued == 0 && len(fake.sent()) != 1 {
+				t.Errorf("replies = %q, want the available link types", fake.sent())
+			}
+		})
+	}
+}
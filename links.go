@@ -0,0 +1,167 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// linkPattern 匹配 http(s) 链接以及不带协议的 t.me 链接
+var linkPattern = regexp.MustCompile(`(?i)(?:https?://)?t\.me/[^\s]+|https?://[^\s]+`)
+
+// extractMessageLinks 从消息的所有来源中提取链接：正文或图片/视频说明、
+// 实体中隐藏的 text_link，以及随消息发送的 .txt/.csv 文档。
+// 不带协议的 t.me 链接补全为 https，完全相同的链接只保留一条，由各处理器按自己的规则进一步去重
+func (b *Bot) extractMessageLinks(message *tgbotapi.Message) ([]string, error) {
+	var sources []string
+	sources = append(sources, message.Text, message.Caption)
+	for _, entities := range [][]tgbotapi.MessageEntity{message.Entities, message.CaptionEntities} {
+		for _, e := range entities {
+			if e.Type == "text_link" && e.URL != "" {
+				sources = append(sources, e.URL)
+			}
+		}
+	}
+	if isTextDocument(message.Document) {
+		docText, err := b.readTextDocument(message.Document)
+		if err != nil {
+			return nil, err
+		}
+		if isCSVDocument(message.Document) {
+			docText = csvFields(docText)
+		}
+		sources = append(sources, docText)
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+	for _, text := range sources {
+		for _, link := range linkPattern.FindAllString(text, -1) {
+			if !strings.HasPrefix(strings.ToLower(link), "http") {
+				link = "https://" + link
+			}
+			if seen[link] {
+				continue
+			}
+			seen[link] = true
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+// dedupeTelegramLinks 补全协议并去重 t.me 链接，保持原有顺序
+func dedupeTelegramLinks(matches []string) []string {
+	var links []string
+	seen := make(map[string]bool)
+	for _, raw := range matches {
+		link := strings.TrimSpace(raw)
+		if !strings.HasPrefix(strings.ToLower(link), "http") {
+			link = "https://" + link
+		}
+		// 规范化用于去重（小写，去尾斜杠）
+		norm := strings.ToLower(strings.TrimSuffix(link, "/"))
+		if seen[norm] {
+			continue
+		}
+		seen[norm] = true
+		links = append(links, link)
+	}
+	return links
+}
+
+// dedupeSubscriptionLinks 去重订阅链接，保持原有顺序
+func dedupeSubscriptionLinks(links []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, link := range links {
+		norm := normalizeSubURL(link)
+		if seen[norm] {
+			continue
+		}
+		seen[norm] = true
+		out = append(out, link)
+	}
+	return out
+}
+
+// isTextDocument 判断文档是否为可解析的文本文件 (.txt / .csv)
+func isTextDocument(doc *tgbotapi.Document) bool {
+	if doc == nil {
+		return false
+	}
+	ext := filepath.Ext(doc.FileName)
+	return strings.EqualFold(ext, ".txt") || strings.HasPrefix(doc.MimeType, "text/plain") || isCSVDocument(doc)
+}
+
+// isCSVDocument 判断文档是否为 CSV 文件
+func isCSVDocument(doc *tgbotapi.Document) bool {
+	return strings.EqualFold(filepath.Ext(doc.FileName), ".csv") || strings.HasPrefix(doc.MimeType, "text/csv")
+}
+
+// csvFields 将 CSV 内容的所有字段按行展开，避免链接与相邻的逗号、引号连在一起。
+// 无法解析时返回原文
+func csvFields(text string) string {
+	r := csv.NewReader(strings.NewReader(text))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return text
+	}
+	var sb strings.Builder
+	for _, record := range records {
+		for _, field := range record {
+			sb.WriteString(field)
+			sb.WriteByte('\n')
+		}
+	}
+	return sb.String()
+}
+
+// errTextDocumentTooLarge 文本文档超过 TextDocumentMaxBytes
+var errTextDocumentTooLarge = errors.New("文件过大")
+
+// readTextDocument 通过 getFile 下载较小的文本文档并返回内容。
+// 返回的错误可能包含带 Bot Token 的文件地址，不能直接展示给用户
+func (b *Bot) readTextDocument(doc *tgbotapi.Document) (string, error) {
+	if int64(doc.FileSize) > TextDocumentMaxBytes {
+		return "", errTextDocumentTooLarge
+	}
+	fileURL, err := b.api.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		return "", fmt.Errorf("获取文件失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), SubValidateTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("下载文件失败: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("下载文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, TextDocumentMaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("读取文件失败: %w", err)
+	}
+	if int64(len(data)) > TextDocumentMaxBytes {
+		return "", errTextDocumentTooLarge
+	}
+	return string(data), nil
+}
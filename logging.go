@@ -0,0 +1,315 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newLogger 根据日志配置创建 slog 日志器，返回的 io.Closer 用于关闭日志文件
+func newLogger() (*slog.Logger, io.Closer, error) {
+	var out io.Writer = os.Stdout
+	var closer io.Closer = io.NopCloser(nil)
+
+	if LogFile != "" {
+		rf, err := newRotatingFile(LogFile, int64(LogFileMaxSizeMB)*1024*1024, LogFileMaxBackups)
+		if err != nil {
+			return nil, nil, fmt.Errorf("打开日志文件失败: %w", err)
+		}
+		out = io.MultiWriter(os.Stdout, rf)
+		closer = rf
+	}
+
+	opts := &slog.HandlerOptions{
+		Level:     parseLogLevel(LogLevel),
+		AddSource: true,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(LogFormat, "json") {
+		handler = slog.NewJSONHandler(out, opts)
+	} else {
+		handler = slog.NewTextHandler(out, opts)
+	}
+	handler = &redactHandler{next: handler}
+
+	logger := slog.New(handler)
+
+	// 让标准库 log 与 telegram-bot-api 的内部日志也经过同一个处理器（含脱敏）
+	slog.SetDefault(logger)
+	tgbotapi.SetLogger(slog.NewLogLogger(handler, slog.LevelWarn))
+
+	return logger, closer, nil
+}
+
+// parseLogLevel 解析日志级别字符串，无法识别时回退到 info
+func parseLogLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// tdlLineLevel 根据 TDL 输出内容决定日志级别
+func tdlLineLevel(line string) slog.Level {
+	lower := strings.ToLower(line)
+	switch {
+	case strings.Contains(line, "[STATUS]"):
+		return slog.LevelInfo
+	case strings.Contains(line, "[错误]") || strings.Contains(lower, "error") ||
+		strings.Contains(lower, "fail") || strings.Contains(line, "失败"):
+		return slog.LevelWarn
+	case strings.Contains(line, "[警告]") || strings.Contains(lower, "warn"):
+		return slog.LevelWarn
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// ==================== 日志脱敏 ====================
+
+var (
+	secretsMu sync.RWMutex
+	secrets   []string
+)
+
+// registerSecret 登记需要在日志中隐藏的敏感字符串（过短的值会被忽略以免误伤普通文本）
+func registerSecret(s string) {
+	s = strings.TrimSpace(s)
+	if len(s) < 6 {
+		return
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, existing := range secrets {
+		if existing == s {
+			return
+		}
+	}
+	secrets = append(secrets, s)
+}
+
+var (
+	// Bot Token 形如 123456789:AAxxxx
+	botTokenPattern = regexp.MustCompile(`\d{5,}:[A-Za-z0-9_-]{30,}`)
+	urlPattern      = regexp.MustCompile(`https?://[^\s"'<>]+`)
+	// 路径中形如令牌的长片段
+	opaqueSegmentPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{20,}$`)
+)
+
+const redactedMark = "***"
+
+// redact 对字符串中的 Bot Token、API Key 以及 URL 中的凭据进行脱敏
+func redact(s string) string {
+	if s == "" {
+		return s
+	}
+
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redactedMark)
+	}
+	secretsMu.RUnlock()
+
+	s = botTokenPattern.ReplaceAllString(s, redactedMark)
+	return urlPattern.ReplaceAllStringFunc(s, redactURL)
+}
+
+// redactURL 隐藏 URL 中的用户名密码、查询参数值以及疑似令牌的路径片段
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.User != nil {
+		u.User = url.User(redactedMark)
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			q[key] = []string{redactedMark}
+		}
+		u.RawQuery = q.Encode()
+	}
+	segments := strings.Split(u.Path, "/")
+	for i, seg := range segments {
+		if opaqueSegmentPattern.MatchString(seg) {
+			segments[i] = redactedMark
+		}
+	}
+	u.Path = strings.Join(segments, "/")
+	u.RawPath = ""
+
+	out := u.String()
+	// url.Values.Encode 会转义 *，这里恢复以便阅读
+	return strings.ReplaceAll(out, "%2A%2A%2A", redactedMark)
+}
+
+// redactHandler 在记录写出前对消息和属性值进行脱敏
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.next.Handle(ctx, nr)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name)}
+}
+
+// redactAttr 对单个属性脱敏，只处理字符串、错误和 Stringer 类型的值
+func redactAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		redacted := make([]any, len(group))
+		for i, ga := range group {
+			redacted[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, redacted...)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, redact(x.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, redact(x.String()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+// ==================== 日志文件轮转 ====================
+
+// rotatingFile 是按大小轮转的日志文件写入器: app.log -> app.log.1 -> app.log.2 ...
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File // 轮转失败后可能为 nil，下次写入时重新打开
+	size       int64
+	closed     bool
+}
+
+func newRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	rf := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.file = f
+	rf.size = info.Size()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.closed {
+		return 0, os.ErrClosed
+	}
+	if rf.maxSize > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			log.New(os.Stderr, "", log.LstdFlags).Printf("日志轮转失败: %v", err)
+		}
+	}
+	// 轮转失败时当前文件已关闭，重新以追加方式打开 (备份未移走时即原文件)，避免丢日志
+	if rf.file == nil {
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate 关闭当前文件并依次后移备份，超出数量的备份被删除
+func (rf *rotatingFile) rotate() error {
+	err := rf.file.Close()
+	rf.file = nil
+	if err != nil {
+		return err
+	}
+	if rf.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", rf.path, rf.maxBackups))
+		for i := rf.maxBackups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rf.path, i), fmt.Sprintf("%s.%d", rf.path, i+1))
+		}
+		if err := os.Rename(rf.path, rf.path+".1"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		os.Remove(rf.path)
+	}
+	return rf.open()
+}
+
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	rf.closed = true
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
@@ -0,0 +1,345 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// notifySettingsFile 用户通知设置的持久化文件名
+const notifySettingsFile = "notify_settings.json"
+
+// 任务生命周期事件
+const (
+	EventQueued        = "queued"
+	EventStarted       = "started"
+	EventLoginRequired = "login_required"
+	EventCompleted     = "completed"
+	EventFailed        = "failed"
+	EventCancelled     = "cancelled"
+)
+
+// notifyEvents 全部事件，按发生顺序
+var notifyEvents = []string{EventQueued, EventStarted, EventLoginRequired, EventCompleted, EventFailed, EventCancelled}
+
+// WebhookConfig 接收任务事件的 HTTP 回调
+type WebhookConfig struct {
+	URL    string
+	Secret string   // 非空时以 HMAC-SHA256 签名请求体，放在 X-Tgbot-Signature 头中
+	Events []string // 为空表示全部事件
+}
+
+// taskEvent 发送给各个通知渠道的任务事件，也是 Webhook 的 JSON 请求体
+type taskEvent struct {
+	Event   string    `json:"event"`
+	TaskID  int       `json:"task_id"`
+	UserID  int64     `json:"user_id"`
+	ChatID  int64     `json:"chat_id"`
+	Link    string    `json:"link"`
+	Album   []string  `json:"album,omitempty"`
+	Account string    `json:"account,omitempty"`
+	Status  string    `json:"status,omitempty"` // 任务结束时的状态文本
+	Time    time.Time `json:"time"`
+
+	task *QueuedTask
+}
+
+// notifyPrefs 用户的通知设置 (/notify)，默认不通知
+type notifyPrefs struct {
+	Telegram bool     `json:"telegram"`         // 发送新消息 (编辑状态消息不会触发提醒)
+	Silent   bool     `json:"silent,omitempty"` // 新消息静默发送
+	Email    string   `json:"email,omitempty"`  // 邮件通知地址，为空表示不发送
+	Events   []string `json:"events,omitempty"` // 为空时使用 NotifyDefaultEvents
+}
+
+// events 用户订阅的事件
+func (p notifyPrefs) events() []string {
+	if len(p.Events) == 0 {
+		return NotifyDefaultEvents
+	}
+	return p.Events
+}
+
+// notifySettingsStore 持久化的用户通知设置
+type notifySettingsStore struct {
+	mu    sync.RWMutex
+	prefs map[int64]notifyPrefs
+}
+
+func newNotifySettingsStore() *notifySettingsStore {
+	return &notifySettingsStore{prefs: make(map[int64]notifyPrefs)}
+}
+
+func (s *notifySettingsStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := loadJSONFile(notifySettingsFile, &s.prefs)
+	if s.prefs == nil {
+		s.prefs = make(map[int64]notifyPrefs)
+	}
+	return err
+}
+
+// Get 返回用户的通知设置
+func (s *notifySettingsStore) Get(userID int64) notifyPrefs {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.prefs[userID]
+}
+
+// Update 修改用户的通知设置并持久化
+func (s *notifySettingsStore) Update(userID int64, fn func(p *notifyPrefs)) (notifyPrefs, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := s.prefs[userID]
+	fn(&p)
+	s.prefs[userID] = p
+	return p, saveJSONFile(notifySettingsFile, s.prefs)
+}
+
+// ==================== 事件分发 ====================
+
+// notifyTask 向所有通知渠道异步发送任务事件，不阻塞任务处理
+func (b *Bot) notifyTask(event string, q *QueuedTask, account, status string) {
+	e := taskEvent{
+		Event:   event,
+		TaskID:  q.TaskID,
+		UserID:  q.UserID,
+		Link:    q.Link,
+		Album:   q.Album,
+		Account: account,
+		Status:  status,
+		Time:    time.Now(),
+		task:    q,
+	}
+	if q.Message != nil {
+		e.ChatID = q.Message.Chat.ID
+	}
+	prefs := b.notifySettings.Get(q.UserID)
+	subscribed := containsFold(prefs.events(), event)
+
+	if prefs.Telegram && subscribed {
+		go b.notifyTelegram(e, prefs.Silent)
+	}
+	if prefs.Email != "" && subscribed && SMTPHost != "" {
+		go b.notifyEmail(e, prefs.Email)
+	}
+	if NotifyAdminChatID != 0 && containsFold(NotifyAdminEvents, event) {
+		go b.notifyAdminChat(e)
+	}
+	for _, hook := range NotifyWebhooks {
+		if len(hook.Events) == 0 || containsFold(hook.Events, event) {
+			go b.notifyWebhook(hook, e)
+		}
+	}
+}
+
+// eventText 通知文本
+func eventText(lang string, e taskEvent) string {
+	text := T(lang, "notify.event."+e.Event, "id", e.TaskID) + "\n" + e.task.displayLink()
+	if e.Status != "" {
+		text += "\n" + e.Status
+	}
+	return text
+}
+
+// notifyTelegram 在提交任务的聊天中发送一条新消息，回复原消息
+func (b *Bot) notifyTelegram(e taskEvent, silent bool) {
+	msg := tgbotapi.NewMessage(e.ChatID, eventText(e.task.Lang, e))
+	if e.task.Message != nil {
+		msg.ReplyToMessageID = e.task.Message.MessageID
+	}
+	msg.DisableNotification = silent
+	msg.DisableWebPagePreview = true
+	if _, err := b.send(msg); err != nil {
+		b.logger.Warn("发送任务通知失败", "user_id", e.UserID, "task_id", e.TaskID, "event", e.Event, "error", err)
+	}
+}
+
+// notifyAdminChat 在管理员聊天中发送事件，附带提交者
+func (b *Bot) notifyAdminChat(e taskEvent) {
+	lang := b.langOf(NotifyAdminChatID)
+	text := eventText(lang, e) + "\n" + T(lang, "notify.admin_user", "user", e.UserID)
+	msg := tgbotapi.NewMessage(NotifyAdminChatID, text)
+	msg.DisableWebPagePreview = true
+	if _, err := b.api.Send(msg); err != nil {
+		b.logger.Warn("发送管理员通知失败", "chat_id", NotifyAdminChatID, "event", e.Event, "error", err)
+	}
+}
+
+// notifyWebhook 以 JSON 格式 POST 事件，配置了 Secret 时附带签名
+func (b *Bot) notifyWebhook(hook WebhookConfig, e taskEvent) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), NotifyWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		b.logger.Warn("Webhook 地址无效", "url", hook.URL, "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Tgbot-Event", e.Event)
+	if hook.Secret != "" {
+		req.Header.Set("X-Tgbot-Signature", "sha256="+signPayload(hook.Secret, body))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		b.logger.Warn("发送 Webhook 失败", "url", hook.URL, "event", e.Event, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b.logger.Warn("Webhook 返回错误", "url", hook.URL, "event", e.Event, "status", resp.StatusCode)
+	}
+}
+
+// signPayload 请求体的 HMAC-SHA256 签名 (十六进制)
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// notifyEmail 通过 SMTP 发送邮件通知
+func (b *Bot) notifyEmail(e taskEvent, to string) {
+	lang := e.task.Lang
+	subject := T(lang, "notify.email_subject", "event", T(lang, "notify.event."+e.Event, "id", e.TaskID))
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n",
+		SMTPFrom, to, mime.BEncoding.Encode("UTF-8", subject))
+	msg.WriteString(strings.ReplaceAll(eventText(lang, e), "\n", "\r\n"))
+
+	var auth smtp.Auth
+	if SMTPUsername != "" {
+		auth = smtp.PlainAuth("", SMTPUsername, SMTPPassword, SMTPHost)
+	}
+	addr := SMTPHost + ":" + strconv.Itoa(SMTPPort)
+	if err := smtp.SendMail(addr, auth, SMTPFrom, []string{to}, msg.Bytes()); err != nil {
+		b.logger.Warn("发送邮件通知失败", "user_id", e.UserID, "task_id", e.TaskID, "event", e.Event, "error", err)
+	}
+}
+
+// ==================== /notify 命令 ====================
+
+// handleNotify 处理 /notify [on|off|silent|events|email] [值] 命令
+func (b *Bot) handleNotify(c *commandContext) {
+	lang := c.Lang
+	userID := c.Message.From.ID
+	if len(c.Args) == 0 {
+		b.replyText(c.Message, b.notifySettingsText(lang, b.notifySettings.Get(userID)))
+		return
+	}
+
+	setting := strings.ToLower(c.Args[0])
+	value := ""
+	if len(c.Args) > 1 {
+		value = strings.TrimSpace(c.Args[1])
+	}
+	var update func(p *notifyPrefs)
+	switch setting {
+	case "on", "off":
+		update = func(p *notifyPrefs) { p.Telegram = setting == "on" }
+	case "silent":
+		on, ok := parseOnOff(value)
+		if !ok {
+			b.replyText(c.Message, T(lang, "cmd.usage", "usage", c.Command.usage(lang)))
+			return
+		}
+		update = func(p *notifyPrefs) { p.Silent = on }
+	case "events":
+		events, ok := parseNotifyEvents(value)
+		if !ok {
+			b.replyText(c.Message, T(lang, "notify.bad_events", "events", strings.Join(notifyEvents, ",")))
+			return
+		}
+		update = func(p *notifyPrefs) { p.Events = events }
+	case "email":
+		if value == "" {
+			b.replyText(c.Message, T(lang, "cmd.usage", "usage", c.Command.usage(lang)))
+			return
+		}
+		if strings.EqualFold(value, "off") {
+			value = ""
+		} else if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+			b.replyText(c.Message, T(lang, "notify.bad_email"))
+			return
+		} else if SMTPHost == "" {
+			b.replyText(c.Message, T(lang, "notify.email_disabled"))
+			return
+		}
+		update = func(p *notifyPrefs) { p.Email = value }
+	}
+
+	prefs, err := b.notifySettings.Update(userID, update)
+	if err != nil {
+		b.logger.Warn("保存通知设置失败", "user_id", userID, "error", err)
+	}
+	b.logger.Info("用户修改了通知设置", "user_id", userID, "setting", setting)
+	b.replyText(c.Message, T(lang, "notify.saved")+"\n\n"+b.notifySettingsText(lang, prefs))
+}
+
+// notifySettingsText 用户当前的通知设置
+func (b *Bot) notifySettingsText(lang string, p notifyPrefs) string {
+	onOff := func(on bool) string {
+		if on {
+			return T(lang, "common.on")
+		}
+		return T(lang, "common.off")
+	}
+	email := p.Email
+	if email == "" {
+		email = T(lang, "common.off")
+	}
+	return T(lang, "notify.settings",
+		"telegram", onOff(p.Telegram),
+		"silent", onOff(p.Silent),
+		"email", email,
+		"events", strings.Join(p.events(), ","),
+		"all", strings.Join(notifyEvents, ","))
+}
+
+// parseOnOff 解析 on / off
+func parseOnOff(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "on":
+		return true, true
+	case "off":
+		return false, true
+	}
+	return false, false
+}
+
+// parseNotifyEvents 解析以逗号或空格分隔的事件列表，all 表示全部事件
+func parseNotifyEvents(s string) ([]string, bool) {
+	if strings.EqualFold(strings.TrimSpace(s), "all") {
+		return append([]string(nil), notifyEvents...), true
+	}
+	var events []string
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if !containsFold(notifyEvents, f) {
+			return nil, false
+		}
+		events = append(events, strings.ToLower(f))
+	}
+	return events, len(events) > 0
+}
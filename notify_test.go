@@ -0,0 +1,201 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseNotifyEvents(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+		ok   bool
+	}{
+		{"all", notifyEvents, true},
+		{" ALL ", notifyEvents, true},
+		{"completed", []string{EventCompleted}, true},
+		{"completed,failed", []string{EventCompleted, EventFailed}, true},
+		{"Queued, Started  failed", []string{EventQueued, EventStarted, EventFailed}, true},
+		{"completed,,failed,", []string{EventCompleted, EventFailed}, true},
+		{"completed,unknown", nil, false},
+		{"all,completed", nil, false},
+		{"", nil, false},
+		{" , ", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseNotifyEvents(tt.in)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseNotifyEvents(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNotifyPrefsEvents(t *testing.T) {
+	tests := []struct {
+		prefs notifyPrefs
+		want  []string
+	}{
+		{notifyPrefs{}, NotifyDefaultEvents},
+		{notifyPrefs{Telegram: true}, NotifyDefaultEvents},
+		{notifyPrefs{Events: []string{EventQueued}}, []string{EventQueued}},
+	}
+	for _, tt := range tests {
+		if got := tt.prefs.events(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v.events() = %v, want %v", tt.prefs, got, tt.want)
+		}
+	}
+}
+
+// webhookRecorder 记录各 Webhook 收到的事件，并校验签名
+type webhookRecorder struct {
+	mu    sync.Mutex
+	calls []string // 路径 + 事件
+	errs  []string
+}
+
+func (rec *webhookRecorder) handler(secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var e taskEvent
+		json.Unmarshal(body, &e)
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.calls = append(rec.calls, r.URL.Path+" "+e.Event)
+		if e.Event != r.Header.Get("X-Tgbot-Event") {
+			rec.errs = append(rec.errs, "event header mismatch")
+		}
+		if secret != "" && r.Header.Get("X-Tgbot-Signature") != "sha256="+signPayload(secret, body) {
+			rec.errs = append(rec.errs, "bad signature on "+r.URL.Path)
+		}
+	}
+}
+
+func (rec *webhookRecorder) get() ([]string, []string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	calls := append([]string(nil), rec.calls...)
+	sort.Strings(calls)
+	return calls, append([]string(nil), rec.errs...)
+}
+
+func TestNotifyTaskRouting(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		prefs notifyPrefs
+		user  bool     // 用户收到 Telegram 通知
+		admin bool     // 管理员聊天收到通知
+		hooks []string // 收到事件的 Webhook
+	}{
+		{
+			name:  "default events",
+			event: EventCompleted,
+			prefs: notifyPrefs{Telegram: true},
+			user:  true,
+			hooks: []string{"/all completed", "/completed completed"},
+		},
+		{
+			name:  "event not in defaults",
+			event: EventQueued,
+			prefs: notifyPrefs{Telegram: true},
+			hooks: []string{"/all queued"},
+		},
+		{
+			name:  "chosen events",
+			event: EventQueued,
+			prefs: notifyPrefs{Telegram: true, Events: []string{EventQueued}},
+			user:  true,
+			hooks: []string{"/all queued"},
+		},
+		{
+			name:  "telegram off",
+			event: EventCompleted,
+			prefs: notifyPrefs{Events: []string{EventCompleted}},
+			hooks: []string{"/all completed", "/completed completed"},
+		},
+		{
+			name:  "admin event",
+			event: EventFailed,
+			admin: true,
+			hooks: []string{"/all failed"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			rec := &webhookRecorder{}
+			mux := http.NewServeMux()
+			mux.Handle("/all", rec.handler("s3cret"))
+			mux.Handle("/completed", rec.handler(""))
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			savedChat, savedAdmin, savedHooks, savedSMTP := NotifyAdminChatID, NotifyAdminEvents, NotifyWebhooks, SMTPHost
+			defer func() {
+				NotifyAdminChatID, NotifyAdminEvents, NotifyWebhooks, SMTPHost = savedChat, savedAdmin, savedHooks, savedSMTP
+			}()
+			NotifyAdminChatID = -1001
+			NotifyAdminEvents = []string{EventLoginRequired, EventFailed}
+			NotifyWebhooks = []WebhookConfig{
+				{URL: srv.URL + "/all", Secret: "s3cret"},
+				{URL: srv.URL + "/completed", Events: []string{EventCompleted}},
+			}
+			SMTPHost = ""
+			if _, err := b.notifySettings.Update(42, func(p *notifyPrefs) { *p = tt.prefs }); err != nil {
+				t.Fatal(err)
+			}
+
+			q := &QueuedTask{TaskID: 3, UserID: 42, Link: "https://t.me/chan/1", Message: testMessage(), Lang: LangEN}
+			b.notifyTask(tt.event, q, "acc", "")
+
+			wantMessages := 0
+			if tt.user {
+				wantMessages++
+			}
+			if tt.admin {
+				wantMessages++
+			}
+			// 通知异步发送：等待预期的通知到达，再确认没有多余的通知
+			deadline := time.Now().Add(2 * time.Second)
+			for time.Now().Before(deadline) {
+				calls, _ := rec.get()
+				if len(fake.sent()) >= wantMessages && len(calls) >= len(tt.hooks) {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			time.Sleep(100 * time.Millisecond)
+
+			var user, admin bool
+			adminLine := T(b.langOf(NotifyAdminChatID), "notify.admin_user", "user", 42)
+			for _, text := range fake.sent() {
+				if strings.Contains(text, adminLine) {
+					admin = true
+				} else {
+					user = true
+				}
+			}
+			if user != tt.user || admin != tt.admin || len(fake.sent()) != wantMessages {
+				t.Errorf("messages = %q, want user %v, admin %v", fake.sent(), tt.user, tt.admin)
+			}
+			calls, errs := rec.get()
+			if !reflect.DeepEqual(calls, tt.hooks) && !(len(calls) == 0 && len(tt.hooks) == 0) {
+				t.Errorf("webhooks = %v, want %v", calls, tt.hooks)
+			}
+			for _, err := range errs {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,369 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// subOutboxFile 待提交订阅队列的持久化文件名
+const subOutboxFile = "sub_outbox.json"
+
+// subStatusRef 指向展示订阅提交结果的消息（单条消息或汇总消息中的某一行）
+type subStatusRef struct {
+	ChatID    int64  `json:"chat_id"`
+	MessageID int    `json:"message_id"`
+	Batch     bool   `json:"batch"`            // 是否为批量汇总消息
+	Index     int    `json:"index"`            // 汇总消息中的行索引
+	Suffix    string `json:"suffix,omitempty"` // 汇总行结果后附加的说明
+	Lang      string `json:"lang,omitempty"`   // 提交者的界面语言
+}
+
+// outboxEntry 待提交队列中的一条订阅
+type outboxEntry struct {
+	ID           int          `json:"id"`
+	UserID       int64        `json:"user_id"`
+	Backend      string       `json:"backend,omitempty"` // 目标订阅后端，为空表示默认后端
+	URL          string       `json:"url"`
+	Ref          subStatusRef `json:"ref"`
+	SummaryLines []string     `json:"summary_lines,omitempty"` // 入队时汇总消息的快照，用于重启后恢复
+	Attempts     int          `json:"attempts"`
+	NextAttempt  time.Time    `json:"next_attempt"`
+	CreatedAt    time.Time    `json:"created_at"`
+	LastError    string       `json:"last_error,omitempty"`
+}
+
+// subscriptionOutbox 持久化的待提交订阅队列
+type subscriptionOutbox struct {
+	mu      sync.Mutex
+	nextID  int
+	entries map[int]*outboxEntry
+}
+
+func newSubscriptionOutbox() *subscriptionOutbox {
+	return &subscriptionOutbox{entries: make(map[int]*outboxEntry)}
+}
+
+func (o *subscriptionOutbox) load() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var entries []*outboxEntry
+	if _, err := loadJSONFile(subOutboxFile, &entries); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		o.entries[e.ID] = e
+		if e.ID > o.nextID {
+			o.nextID = e.ID
+		}
+	}
+	return nil
+}
+
+// saveLocked 保存队列，调用方需持有锁
+func (o *subscriptionOutbox) saveLocked() error {
+	entries := make([]*outboxEntry, 0, len(o.entries))
+	for _, e := range o.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return saveJSONFile(subOutboxFile, entries)
+}
+
+// Enqueue 加入待提交队列
+func (o *subscriptionOutbox) Enqueue(e *outboxEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.nextID++
+	e.ID = o.nextID
+	e.CreatedAt = time.Now()
+	e.NextAttempt = time.Now().Add(SubOutboxRetryBase)
+	o.entries[e.ID] = e
+	return o.saveLocked()
+}
+
+// Due 返回已到重试时间的条目副本
+func (o *subscriptionOutbox) Due(now time.Time) []outboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var due []outboxEntry
+	for _, e := range o.entries {
+		if !e.NextAttempt.After(now) {
+			due = append(due, *e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	return due
+}
+
+// Reschedule 记录一次失败并按指数退避安排下次重试
+func (o *subscriptionOutbox) Reschedule(id int, lastErr string) (*outboxEntry, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	e, ok := o.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	e.Attempts++
+	e.LastError = lastErr
+	e.NextAttempt = time.Now().Add(outboxBackoff(e.Attempts))
+	copied := *e
+	return &copied, o.saveLocked()
+}
+
+// Remove 从队列中移除
+func (o *subscriptionOutbox) Remove(id int) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.entries, id)
+	return o.saveLocked()
+}
+
+// CountMessage 返回结果展示在指定汇总消息中的条目数
+func (o *subscriptionOutbox) CountMessage(chatID int64, messageID int) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	n := 0
+	for _, e := range o.entries {
+		if e.Ref.Batch && e.Ref.ChatID == chatID && e.Ref.MessageID == messageID {
+			n++
+		}
+	}
+	return n
+}
+
+// Len 返回队列长度
+func (o *subscriptionOutbox) Len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.entries)
+}
+
+// outboxBackoff 计算第 n 次失败后的重试间隔
+func outboxBackoff(attempts int) time.Duration {
+	d := SubOutboxRetryBase
+	for i := 1; i < attempts && d < SubOutboxRetryMax; i++ {
+		d *= 2
+	}
+	if d > SubOutboxRetryMax {
+		d = SubOutboxRetryMax
+	}
+	return d
+}
+
+// ==================== 熔断器 ====================
+
+// circuitBreaker 连续失败达到阈值后熔断，冷却期过后放行一次探测请求
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// Allow 判断是否允许发起请求
+func (cb *circuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.failures < cb.threshold {
+		return true
+	}
+	// 熔断中：冷却期结束后只放行一个探测请求
+	if time.Now().Before(cb.openUntil) || cb.probing {
+		return false
+	}
+	cb.probing = true
+	return true
+}
+
+// Success 记录一次成功，关闭熔断
+func (cb *circuitBreaker) Success() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures = 0
+	cb.probing = false
+}
+
+// Failure 记录一次失败，达到阈值后打开熔断
+func (cb *circuitBreaker) Failure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.failures++
+	cb.probing = false
+	if cb.failures >= cb.threshold {
+		cb.openUntil = time.Now().Add(cb.cooldown)
+	}
+}
+
+// State 返回熔断器状态描述
+func (cb *circuitBreaker) State(lang string) string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch {
+	case cb.failures < cb.threshold:
+		return T(lang, "breaker.closed")
+	case time.Now().Before(cb.openUntil):
+		return T(lang, "breaker.open")
+	default:
+		return T(lang, "breaker.probing")
+	}
+}
+
+// ==================== Bot 集成 ====================
+
+// errSubscriptionUnavailable 订阅 API 处于熔断状态
+var errSubscriptionUnavailable = errors.New("订阅 API 暂时不可用")
+
+// submitSubscription 通过后端的熔断器提交订阅。返回的 error 表示 API 不可达（可稍后重试），
+// 此时熔断器会记录失败；API 返回 5xx 同样视为不可达。
+func (b *Bot) submitSubscription(backend *subscriptionBackend, subURL string) (*SubscriptionResult, error) {
+	if !backend.Breaker.Allow() {
+		return nil, errSubscriptionUnavailable
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := backend.Client.Add(ctx, subURL)
+	if err == nil && result.StatusCode >= http.StatusInternalServerError {
+		err = newError("err.sub.api_status", "status", result.StatusCode)
+	}
+	if err != nil {
+		backend.Breaker.Failure()
+		return nil, err
+	}
+	backend.Breaker.Success()
+	return result, nil
+}
+
+// subscriptionResultText 将 API 响应转换为用户可见的结果文本
+func subscriptionResultText(result *SubscriptionResult) string {
+	if result.Success {
+		return fmt.Sprintf("✅ %s", result.Message)
+	}
+	// 特殊处理重复订阅
+	if result.Duplicate {
+		return fmt.Sprintf("⚠️ %s", result.Message)
+	}
+	return fmt.Sprintf("❌ %s", result.Message)
+}
+
+// queueSubscription 将暂时无法提交的订阅加入待提交队列，返回给用户的提示以及是否已加入队列
+func (b *Bot) queueSubscription(userID int64, backend, subURL string, ref subStatusRef, cause error) (string, bool) {
+	entry := &outboxEntry{UserID: userID, Backend: backend, URL: subURL, Ref: ref, LastError: cause.Error()}
+	if ref.Batch {
+		if lines, ok := b.taskManager.GetSummaryLines(ref.ChatID, ref.MessageID); ok {
+			entry.SummaryLines = append([]string(nil), lines...)
+		}
+	}
+	if err := b.subOutbox.Enqueue(entry); err != nil {
+		b.logger.Error("保存待提交订阅失败", "sub_url", subURL, "error", err)
+		if os.IsTimeout(cause) || errors.Is(cause, context.DeadlineExceeded) {
+			return T(ref.Lang, "sub.timeout"), false
+		}
+		return T(ref.Lang, "sub.unreachable"), false
+	}
+	b.logger.Info("订阅已加入待提交队列", "user_id", userID, "sub_url", subURL, "outbox_id", entry.ID, "cause", cause)
+	if errors.Is(cause, errSubscriptionUnavailable) {
+		return T(ref.Lang, "sub.queued_unavailable"), true
+	}
+	return T(ref.Lang, "sub.queued_unreachable"), true
+}
+
+// runSubscriptionOutbox 后台重试待提交队列中的订阅，直到停机
+func (b *Bot) runSubscriptionOutbox() {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopping:
+			return
+		case <-ticker.C:
+		}
+
+		// 某个后端熔断中时跳过其余发往该后端的条目，等待下一轮
+		blocked := make(map[string]bool)
+		for _, entry := range b.subOutbox.Due(time.Now()) {
+			backend := b.subRouter.Get(entry.Backend)
+			if blocked[backend.Name] {
+				continue
+			}
+			if !b.retryOutboxEntry(backend, entry) {
+				blocked[backend.Name] = true
+			}
+		}
+	}
+}
+
+// retryOutboxEntry 重试一条待提交订阅，并在最终成功或失败时编辑原状态消息。
+// 熔断器未放行时返回 false，且不计入重试次数。
+func (b *Bot) retryOutboxEntry(backend *subscriptionBackend, entry outboxEntry) bool {
+	elog := b.logger.With("outbox_id", entry.ID, "user_id", entry.UserID, "sub_url", entry.URL, "backend", backend.Name)
+
+	result, err := b.submitSubscription(backend, entry.URL)
+	if errors.Is(err, errSubscriptionUnavailable) {
+		return false
+	}
+	if err != nil {
+		updated, saveErr := b.subOutbox.Reschedule(entry.ID, err.Error())
+		if saveErr != nil {
+			elog.Error("保存待提交订阅失败", "error", saveErr)
+		}
+		if updated == nil {
+			return true
+		}
+		if updated.Attempts < SubOutboxMaxAttempts {
+			elog.Info("待提交订阅重试失败", "attempts", updated.Attempts, "next_attempt", updated.NextAttempt, "error", err)
+			return true
+		}
+		elog.Warn("待提交订阅多次重试失败，放弃提交", "attempts", updated.Attempts, "error", err)
+		b.finishOutboxEntry(entry, T(entry.Ref.Lang, "sub.retry_exhausted", "error", errorText(entry.Ref.Lang, err)))
+		return true
+	}
+
+	if result.Success {
+		elog.Info("待提交订阅已提交成功", "message", result.Message)
+		if err := b.subStore.Record(entry.URL, entry.UserID, backend.Name); err != nil {
+			elog.Warn("保存订阅归属失败", "error", err)
+		}
+	} else {
+		elog.Warn("待提交订阅被 API 拒绝", "status", result.StatusCode, "error", result.Message)
+	}
+	b.finishOutboxEntry(entry, subscriptionResultText(result))
+	return true
+}
+
+// finishOutboxEntry 移除条目并把最终结果写回原状态消息
+func (b *Bot) finishOutboxEntry(entry outboxEntry, text string) {
+	if err := b.subOutbox.Remove(entry.ID); err != nil {
+		b.logger.Error("移除待提交订阅失败", "outbox_id", entry.ID, "error", err)
+	}
+
+	ref := entry.Ref
+	if !ref.Batch {
+		b.updateTaskMessage(ref.ChatID, ref.MessageID, text+" "+T(ref.Lang, "sub.deferred")+"\n"+entry.URL, nil)
+		return
+	}
+	// 重启后汇总缓存已丢失时，用入队时的快照恢复，避免整条消息被单行覆盖；
+	// 待完成数为该消息仍在队列中的条目数加上本条
+	if _, ok := b.taskManager.GetSummaryLines(ref.ChatID, ref.MessageID); !ok && len(entry.SummaryLines) > 0 {
+		b.taskManager.InitSummary(ref.ChatID, ref.MessageID, entry.SummaryLines, nil)
+		b.taskManager.SetSummaryPending(ref.ChatID, ref.MessageID, b.subOutbox.CountMessage(ref.ChatID, ref.MessageID)+1)
+	}
+	b.updateSummaryLine(ref.ChatID, ref.MessageID, ref.Index, formatSubBatchLine(ref.Index, entry.URL, text+" "+T(ref.Lang, "sub.deferred")+ref.Suffix))
+	b.finishSubBatchLine(ref.ChatID, ref.MessageID)
+}
@@ -0,0 +1,359 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// errSourceInaccessible 用户账号无法访问来源 (私有、不存在或未加入)
+var errSourceInaccessible = errors.New("用户账号无法访问该来源")
+
+// sourcePreview 转发前对来源的预估
+type sourcePreview struct {
+	Messages int
+	Media    map[string]int // photo / video / audio / document / text -> 数量
+	Bytes    int64          // 媒体总大小，无法获取大小的媒体不计入
+	Capped   bool           // 达到 ForwardPreviewMaxMessages，只统计了最近的消息
+}
+
+// large 是否达到需要确认的阈值
+func (p *sourcePreview) large() bool {
+	return p.Messages >= ForwardPreviewMinMessages || (ForwardPreviewMinBytes > 0 && p.Bytes >= ForwardPreviewMinBytes)
+}
+
+// parseTelegramLink 解析 t.me 链接中的聊天 (用户名或数字 ID) 与消息 ID，
+// 邀请链接 (t.me/+xxx、t.me/joinchat/xxx) 无法解析
+func parseTelegramLink(link string) (chat string, msgID int, ok bool) {
+	s := strings.TrimSpace(link)
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "https://"), "http://")
+	if i := strings.IndexAny(s, "?#"); i != -1 {
+		s = s[:i]
+	}
+	if len(s) < 5 || !strings.EqualFold(s[:5], "t.me/") {
+		return "", 0, false
+	}
+	parts := strings.Split(strings.Trim(s[5:], "/"), "/")
+	if parts[0] == "" || strings.HasPrefix(parts[0], "+") || parts[0] == "joinchat" {
+		return "", 0, false
+	}
+	rest := parts[1:]
+	chat = parts[0]
+	if chat == "c" || chat == "s" {
+		if len(parts) < 2 {
+			return "", 0, false
+		}
+		chat, rest = parts[1], parts[2:]
+	}
+	// 话题中的消息形如 t.me/c/<id>/<话题>/<消息>，取最后一段
+	if len(rest) > 0 {
+		if id, err := strconv.Atoi(rest[len(rest)-1]); err == nil {
+			msgID = id
+		}
+	}
+	return chat, msgID, true
+}
+
+// needsPreview 链接是否需要先预览。单条消息的链接 (t.me/频道/123) 在阈值大于 1 时直接加入队列
+func needsPreview(link string) bool {
+	if !ForwardPreviewEnabled {
+		return false
+	}
+	_, msgID, ok := parseTelegramLink(link)
+	if !ok {
+		return false
+	}
+	return msgID == 0 || ForwardPreviewMinMessages <= 1
+}
+
+// previewSources 返回无需预览、可以直接加入队列的链接；其余链接在后台逐个预览 (所有用户共用，同一时间只预览一个)。
+// hooked 表示链接已执行过 pre_enqueue 钩子，预览后加入队列时不再执行
+func (b *Bot) previewSources(message *tgbotapi.Message, links []string, priority TaskPriority, hooked bool) []string {
+	var direct, large []string
+	for _, link := range links {
+		if needsPreview(link) {
+			large = append(large, link)
+		} else {
+			direct = append(direct, link)
+		}
+	}
+	if len(large) > 0 {
+		go func() {
+			for _, link := range large {
+				b.previewSource(message, link, priority, hooked)
+			}
+		}()
+	}
+	return direct
+}
+
+// previewSource 检查来源并回复预估结果与 "开始 / 取消" 按钮。未达到阈值时直接加入队列
+func (b *Bot) previewSource(message *tgbotapi.Message, link string, priority TaskPriority, hooked bool) {
+	lang := b.userLang(message.From)
+	statusMsg := tgbotapi.NewMessage(message.Chat.ID, T(lang, "preview.checking", "link", link))
+	statusMsg.ReplyToMessageID = message.MessageID
+	sentMsg, err := b.send(statusMsg)
+	if err != nil {
+		b.logger.Error("发送消息失败", "chat_id", message.Chat.ID, "error", err)
+		return
+	}
+
+	// 与队列中的任务共用 tdl，有任务正在执行时提示并等待其完成
+	if !b.tdlMu.TryLock() {
+		b.updateTaskMessage(message.Chat.ID, sentMsg.MessageID, T(lang, "preview.waiting", "link", link), nil)
+		b.tdlMu.Lock()
+	}
+	preview, err := b.inspectSource(message.From.ID, link)
+	b.tdlMu.Unlock()
+	var text string
+	switch {
+	case errors.Is(err, errSourceInaccessible):
+		b.logger.Info("来源无法访问", "user_id", message.From.ID, "link", link)
+		b.updateTaskMessage(message.Chat.ID, sentMsg.MessageID, T(lang, "preview.inaccessible", "link", link), nil)
+		return
+	case err != nil:
+		// 无法预估时仍由用户决定是否开始
+		b.logger.Warn("来源预览失败", "user_id", message.From.ID, "link", link, "error", err)
+		text = T(lang, "preview.failed", "error", errorText(lang, err), "link", link)
+	case !preview.large():
+		b.api.Request(tgbotapi.NewDeleteMessage(message.Chat.ID, sentMsg.MessageID))
+		if hooked {
+			b.addLinks(message, []string{link}, priority)
+		} else {
+			b.enqueueLinks(message, []string{link}, priority)
+		}
+		return
+	default:
+		count := strconv.Itoa(preview.Messages)
+		if preview.Capped {
+			count = T(lang, "preview.at_least", "count", preview.Messages)
+		}
+		text = T(lang, "preview.summary", "link", link, "count", count,
+			"media", formatMediaBreakdown(lang, preview.Media), "size", formatBytes(preview.Bytes))
+	}
+
+	id := b.pendingForwards.Add(&pendingForward{UserID: message.From.ID, Message: message, Links: []string{link}, Priority: priority, Hooked: hooked})
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "preview.btn_start"), fmt.Sprintf("fwdok_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "preview.btn_cancel"), fmt.Sprintf("fwdno_%d", id)),
+		),
+	)
+	b.updateTaskMessage(message.Chat.ID, sentMsg.MessageID, text, &keyboard)
+}
+
+// handleSourcePreviewCallback 处理来源预览的按钮: fwdok_<id> / fwdno_<id>
+func (b *Bot) handleSourcePreviewCallback(query *tgbotapi.CallbackQuery) {
+	start := strings.HasPrefix(query.Data, "fwdok_")
+	var id int
+	fmt.Sscanf(query.Data[len("fwdok_"):], "%d", &id)
+	o, ok := b.takePendingForward(query, id)
+	if !ok {
+		return
+	}
+
+	lang := b.userLang(query.From)
+	chatID := query.Message.Chat.ID
+	if !start {
+		b.updateTaskMessage(chatID, query.Message.MessageID, T(lang, "preview.cancelled", "link", o.Links[0]), nil)
+		return
+	}
+	b.logger.Info("用户确认开始转发", "user_id", o.UserID, "link", o.Links[0])
+	b.updateTaskMessage(chatID, query.Message.MessageID, query.Message.Text+"\n\n"+T(lang, "preview.started"), nil)
+	b.enqueuePending(o)
+}
+
+// inspectSource 通过 tdl 导出来源的消息列表 (不下载媒体) 并统计数量、媒体类型与大小。
+// 整个来源最多导出最近的 ForwardPreviewMaxMessages 条消息
+func (b *Bot) inspectSource(userID int64, link string) (*sourcePreview, error) {
+	chat, msgID, ok := parseTelegramLink(link)
+	if !ok {
+		return nil, newError("err.preview.bad_link")
+	}
+	account, ready := b.accounts.Pick(userID, time.Now())
+	if account == "" {
+		return nil, newError("err.accounts.all_paused", "time", ready.Format("15:04:05"))
+	}
+	out, err := os.CreateTemp("", "tdl_export_*.json")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	ctx, cancel := context.WithTimeout(context.Background(), ForwardPreviewTimeout)
+	defer cancel()
+	args := []string{TDLScriptPath, "--export", chat, out.Name()}
+	if msgID != 0 {
+		args = append(args, strconv.Itoa(msgID))
+	}
+	cmd := exec.CommandContext(ctx, "bash", args...)
+	cmd.Env = append(os.Environ(), "TDL_NAMESPACE="+account)
+	capped := msgID == 0 && ForwardPreviewMaxMessages > 0
+	if capped {
+		cmd.Env = append(cmd.Env, "TDL_EXPORT_LIMIT="+strconv.Itoa(ForwardPreviewMaxMessages))
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd.Process.Pid) }
+	output, err := cmd.CombinedOutput()
+	for _, line := range strings.Split(string(output), "\n") {
+		if wait, ok := parseFloodWait(line); ok {
+			b.logger.Warn("预览时账号触发限流", "account", account, "wait", wait)
+			if err := b.accounts.Flood(account, wait); err != nil {
+				b.logger.Error("保存账号状态失败", "error", err)
+			}
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		return nil, newError("err.preview.timeout", "timeout", ForwardPreviewTimeout)
+	}
+	if err != nil {
+		if sourceInaccessible(string(output)) {
+			return nil, errSourceInaccessible
+		}
+		return nil, wrapError(err, "err.preview.export_failed")
+	}
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		return nil, fmt.Errorf("读取导出结果失败: %w", err)
+	}
+	preview, err := parseTDLExport(data)
+	if err != nil {
+		return nil, err
+	}
+	preview.Capped = capped && preview.Messages >= ForwardPreviewMaxMessages
+	return preview, nil
+}
+
+// sourceInaccessible 根据 tdl 输出判断是否为无权访问或来源不存在
+func sourceInaccessible(output string) bool {
+	upper := strings.ToUpper(output)
+	for _, s := range []string{"CHANNEL_PRIVATE", "CHANNEL_INVALID", "CHAT_FORBIDDEN", "USERNAME_NOT_OCCUPIED", "USERNAME_INVALID", "PEER_ID_INVALID"} {
+		if strings.Contains(upper, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// tdlExport tdl chat export --raw 的输出，raw 为 MTProto 消息对象
+type tdlExport struct {
+	Messages []struct {
+		ID   int             `json:"id"`
+		Type string          `json:"type"`
+		File string          `json:"file"`
+		Raw  json.RawMessage `json:"raw"`
+	} `json:"messages"`
+}
+
+// tdlRawMessage raw 中与媒体类型、大小有关的字段
+type tdlRawMessage struct {
+	Media *struct {
+		Document *struct {
+			MimeType string
+			Size     int64
+		}
+		Photo *struct {
+			Sizes []struct {
+				Size  int64
+				Sizes []int64 // 渐进式图片的各级大小
+			}
+		}
+	}
+}
+
+// parseTDLExport 统计导出结果
+func parseTDLExport(data []byte) (*sourcePreview, error) {
+	var export tdlExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, wrapError(err, "err.preview.bad_export")
+	}
+	p := &sourcePreview{Media: make(map[string]int)}
+	for _, m := range export.Messages {
+		p.Messages++
+		var raw tdlRawMessage
+		if len(m.Raw) > 0 {
+			json.Unmarshal(m.Raw, &raw)
+		}
+		kind, size := "text", int64(0)
+		switch {
+		case raw.Media != nil && raw.Media.Document != nil:
+			kind, size = "document", raw.Media.Document.Size
+			mime := raw.Media.Document.MimeType
+			if strings.HasPrefix(mime, "video/") {
+				kind = "video"
+			} else if strings.HasPrefix(mime, "audio/") {
+				kind = "audio"
+			}
+		case raw.Media != nil && raw.Media.Photo != nil:
+			kind = "photo"
+			// 转发时使用最大尺寸
+			for _, s := range raw.Media.Photo.Sizes {
+				if s.Size > size {
+					size = s.Size
+				}
+				if n := len(s.Sizes); n > 0 && s.Sizes[n-1] > size {
+					size = s.Sizes[n-1]
+				}
+			}
+		case m.Type == "photo":
+			kind = "photo"
+		case m.File != "":
+			kind = "document"
+			switch strings.ToLower(filepath.Ext(m.File)) {
+			case ".mp4", ".mkv", ".mov", ".webm":
+				kind = "video"
+			case ".mp3", ".m4a", ".ogg", ".flac":
+				kind = "audio"
+			}
+		}
+		p.Media[kind]++
+		p.Bytes += size
+	}
+	return p, nil
+}
+
+// mediaKinds 媒体类型的显示顺序
+var mediaKinds = []string{"photo", "video", "audio", "document", "text"}
+
+// formatMediaBreakdown 生成媒体类型统计，如 "图片 12 · 视频 3"
+func formatMediaBreakdown(lang string, media map[string]int) string {
+	var parts []string
+	for _, kind := range mediaKinds {
+		if n := media[kind]; n > 0 {
+			parts = append(parts, T(lang, "preview.media."+kind, "count", n))
+		}
+	}
+	if len(parts) == 0 {
+		return T(lang, "preview.media.none")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatBytes 以 KB/MB/GB 显示大小
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGT"[exp])
+}
@@ -0,0 +1,141 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTelegramLink(t *testing.T) {
+	tests := []struct {
+		link  string
+		chat  string
+		msgID int
+		ok    bool
+	}{
+		{"https://t.me/channel_name", "channel_name", 0, true},
+		{"https://t.me/channel_name/", "channel_name", 0, true},
+		{"https://t.me/channel_name/123", "channel_name", 123, true},
+		{"http://t.me/channel_name/123?single", "channel_name", 123, true},
+		{"t.me/channel_name/123#comment", "channel_name", 123, true},
+		{"https://T.ME/channel_name/5", "channel_name", 5, true},
+		{"https://t.me/c/1234567890/42", "1234567890", 42, true},
+		{"https://t.me/c/1234567890/7/42", "1234567890", 42, true},
+		{"https://t.me/s/channel_name", "channel_name", 0, true},
+		{"https://t.me/channel_name/abc", "channel_name", 0, true},
+		{"https://t.me/+AbCdEf", "", 0, false},
+		{"https://t.me/joinchat/AbCdEf", "", 0, false},
+		{"https://t.me/c", "", 0, false},
+		{"https://t.me/", "", 0, false},
+		{"https://example.com/channel_name/1", "", 0, false},
+		{"", "", 0, false},
+	}
+	for _, tt := range tests {
+		chat, msgID, ok := parseTelegramLink(tt.link)
+		if chat != tt.chat || msgID != tt.msgID || ok != tt.ok {
+			t.Errorf("parseTelegramLink(%q) = %q, %d, %v, want %q, %d, %v", tt.link, chat, msgID, ok, tt.chat, tt.msgID, tt.ok)
+		}
+	}
+}
+
+func TestParseTDLExport(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		media map[string]int
+		bytes int64
+		err   bool
+	}{
+		{
+			name:  "empty",
+			data:  `{"messages": []}`,
+			media: map[string]int{},
+		},
+		{
+			name: "raw media",
+			data: `{"messages": [
+				{"id": 1, "raw": {"Media": {"Document": {"MimeType": "video/mp4", "Size": 1000}}}},
+				{"id": 2, "raw": {"Media": {"Document": {"MimeType": "audio/mpeg", "Size": 200}}}},
+				{"id": 3, "raw": {"Media": {"Document": {"MimeType": "application/zip", "Size": 30}}}},
+				{"id": 4, "raw": {"Media": {"Photo": {"Sizes": [{"Size": 10}, {"Sizes": [5, 50]}, {"Size": 20}]}}}},
+				{"id": 5, "raw": {"Message": "hello"}}
+			]}`,
+			media: map[string]int{"video": 1, "audio": 1, "document": 1, "photo": 1, "text": 1},
+			bytes: 1280,
+		},
+		{
+			name: "fallback to type and file name",
+			data: `{"messages": [
+				{"id": 1, "type": "photo"},
+				{"id": 2, "file": "clip.MKV"},
+				{"id": 3, "file": "song.flac"},
+				{"id": 4, "file": "notes.pdf"}
+			]}`,
+			media: map[string]int{"photo": 1, "video": 1, "audio": 1, "document": 1},
+		},
+		{
+			name: "invalid json",
+			data: `not json`,
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := parseTDLExport([]byte(tt.data))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("parseTDLExport = %+v, want error", p)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			messages := 0
+			for _, n := range tt.media {
+				messages += n
+			}
+			if p.Messages != messages || p.Bytes != tt.bytes || !reflect.DeepEqual(p.Media, tt.media) {
+				t.Errorf("parseTDLExport = %+v, want %d messages, %d bytes, media %v", p, messages, tt.bytes, tt.media)
+			}
+		})
+	}
+}
+
+func TestPreviewSourceWaitsForTDL(t *testing.T) {
+	b, _ := newTestBot(t)
+	script, argsFile := fakeTDLScript(t)
+	savedScript := TDLScriptPath
+	TDLScriptPath = script
+	defer func() { TDLScriptPath = savedScript }()
+	t.Setenv("TDL_MANAGED", "1")
+	t.Setenv("FAKE_TDL_EXIT", "1")
+	b.accounts = newAccountPool()
+	b.accounts.accounts = []*tdlAccount{{Name: "acc"}}
+
+	// 模拟正在执行的任务
+	b.tdlMu.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.previewSource(testMessage(), "https://t.me/chan", PriorityNormal, false)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	if _, err := os.Stat(argsFile); err == nil {
+		t.Fatal("tdl ran while another task held the tdl slot")
+	}
+
+	b.tdlMu.Unlock()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("preview did not finish after the tdl slot was released")
+	}
+	if _, err := os.Stat(argsFile); err != nil {
+		t.Errorf("tdl not run after the tdl slot was released: %v", err)
+	}
+}
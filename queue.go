@@ -0,0 +1,538 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// queueDisplayLimit /queue 最多列出的任务数（每个任务一行按钮）
+const queueDisplayLimit = 20
+
+// isAdmin 检查用户是否为管理员
+func isAdmin(userID int64) bool {
+	return AdminUsers[userID]
+}
+
+// ==================== 任务优先级 ====================
+
+// TaskPriority 任务优先级
+type TaskPriority int
+
+const (
+	PriorityLow TaskPriority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// Key 返回优先级的配置名称 (low / normal / high)
+func (p TaskPriority) Key() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// priorityName 返回优先级的显示名称
+func priorityName(lang string, p TaskPriority) string {
+	return T(lang, "priority."+p.Key())
+}
+
+// priorityLabel 返回附加在排队状态后的优先级说明，普通优先级不显示
+func priorityLabel(lang string, p TaskPriority) string {
+	if p == PriorityNormal {
+		return ""
+	}
+	return T(lang, "priority.label", "name", priorityName(lang, p))
+}
+
+// queuePositionText 返回新任务的排队状态文本
+func queuePositionText(lang string, held bool, pos int, p TaskPriority) string {
+	switch {
+	case held:
+		return T(lang, "task.position_paused", "pos", pos)
+	case pos > 1:
+		return T(lang, "task.position", "pos", pos, "priority", priorityLabel(lang, p))
+	default:
+		return T(lang, "task.starting_soon")
+	}
+}
+
+// parseTaskPriority 解析 low / normal / high
+func parseTaskPriority(s string) (TaskPriority, bool) {
+	switch strings.ToLower(s) {
+	case "low":
+		return PriorityLow, true
+	case "normal":
+		return PriorityNormal, true
+	case "high":
+		return PriorityHigh, true
+	}
+	return PriorityNormal, false
+}
+
+// defaultPriorityFor 返回用户的默认优先级：UserPriorities 中的配置优先，其次管理员为高优先级
+func defaultPriorityFor(userID int64) TaskPriority {
+	if p, ok := UserPriorities[userID]; ok {
+		return p
+	}
+	if isAdmin(userID) {
+		return PriorityHigh
+	}
+	return DefaultTaskPriority
+}
+
+// canRaisePriority 判断用户能否通过 !high 提升任务优先级
+func canRaisePriority(userID int64) bool {
+	return isAdmin(userID) || PriorityBoostUsers[userID]
+}
+
+// extractPriorityFlag 识别消息末尾的 !low / !normal / !high 标记
+func extractPriorityFlag(text string) (TaskPriority, bool) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[len(fields)-1], "!") {
+		return PriorityNormal, false
+	}
+	return parseTaskPriority(strings.TrimPrefix(fields[len(fields)-1], "!"))
+}
+
+// taskPriorityFor 根据用户默认值与消息中的标记确定任务优先级。
+// 没有权限的用户只能降低优先级，提升请求会被忽略，此时 denied 为 true。
+func taskPriorityFor(userID int64, text string) (priority TaskPriority, denied bool) {
+	priority = defaultPriorityFor(userID)
+	requested, ok := extractPriorityFlag(text)
+	if !ok {
+		return priority, false
+	}
+	if requested > priority && !canRaisePriority(userID) {
+		return priority, true
+	}
+	return requested, false
+}
+
+// effectivePriority 计算任务的有效优先级：每等待 PriorityAgingInterval 提升一级，最高为高优先级
+func (q *QueuedTask) effectivePriority(now time.Time) TaskPriority {
+	p := q.Priority
+	if PriorityAgingInterval > 0 && !q.EnqueuedAt.IsZero() {
+		p += TaskPriority(now.Sub(q.EnqueuedAt) / PriorityAgingInterval)
+	}
+	if p > PriorityHigh {
+		p = PriorityHigh
+	}
+	return p
+}
+
+// queueLess 队列排序规则：置顶任务最先（最近置顶的在前），其次按有效优先级，同级按入队顺序
+func queueLess(a, b *QueuedTask, now time.Time) bool {
+	if a.PinnedAt.IsZero() != b.PinnedAt.IsZero() {
+		return !a.PinnedAt.IsZero()
+	}
+	if !a.PinnedAt.IsZero() {
+		return a.PinnedAt.After(b.PinnedAt)
+	}
+	pa, pb := a.effectivePriority(now), b.effectivePriority(now)
+	if pa != pb {
+		return pa > pb
+	}
+	return a.seq < b.seq
+}
+
+// ==================== 队列操作 ====================
+
+// notifyQueue 通知队列处理器有新任务（非阻塞）
+func (tm *TaskManager) notifyQueue() {
+	select {
+	case tm.queueSignal <- struct{}{}:
+	default:
+	}
+}
+
+// sortPendingLocked 按当前有效优先级重新排列等待队列，调用方需持有锁
+func (tm *TaskManager) sortPendingLocked() {
+	now := time.Now()
+	sort.SliceStable(tm.pending, func(i, j int) bool {
+		return queueLess(tm.pending[i], tm.pending[j], now)
+	})
+}
+
+// DequeueTask 取出有效优先级最高的任务，队列为空时返回 nil
+func (tm *TaskManager) DequeueTask() *QueuedTask {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if len(tm.pending) == 0 {
+		return nil
+	}
+	tm.sortPendingLocked()
+	task := tm.pending[0]
+	tm.pending[0] = nil
+	tm.pending = tm.pending[1:]
+	return task
+}
+
+// DrainQueue 按执行顺序取出全部等待中的任务并清空队列
+func (tm *TaskManager) DrainQueue() []*QueuedTask {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.sortPendingLocked()
+	tasks := tm.pending
+	tm.pending = nil
+	return tasks
+}
+
+// PendingTasks 返回等待中任务的快照（按执行顺序）
+func (tm *TaskManager) PendingTasks() []*QueuedTask {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.sortPendingLocked()
+	return append([]*QueuedTask(nil), tm.pending...)
+}
+
+// PositionForNew 返回以指定优先级新入队的任务将处于的位置（从 1 开始）
+func (tm *TaskManager) PositionForNew(priority TaskPriority) int {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	now := time.Now()
+	ahead := 0
+	for _, q := range tm.pending {
+		if !q.PinnedAt.IsZero() || q.effectivePriority(now) >= priority {
+			ahead++
+		}
+	}
+	return ahead + 1
+}
+
+// QueuePosition 返回任务在等待队列中的位置（从 1 开始），不在队列中时返回 0
+func (tm *TaskManager) QueuePosition(userID int64, taskID int) int {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.sortPendingLocked()
+	for i, q := range tm.pending {
+		if q.UserID == userID && q.TaskID == taskID {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// MoveToFront 将等待中的任务置顶，置顶任务排在所有优先级之前
+func (tm *TaskManager) MoveToFront(userID int64, taskID int) (*QueuedTask, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for _, q := range tm.pending {
+		if q.UserID == userID && q.TaskID == taskID {
+			q.PinnedAt = time.Now()
+			return q, true
+		}
+	}
+	return nil, false
+}
+
+// CyclePriority 循环切换等待中任务的优先级 (低 → 普通 → 高 → 低)，返回切换后的优先级
+func (tm *TaskManager) CyclePriority(userID int64, taskID int) (*QueuedTask, TaskPriority, bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	for _, q := range tm.pending {
+		if q.UserID == userID && q.TaskID == taskID {
+			q.Priority = (q.Priority + 1) % (PriorityHigh + 1)
+			return q, q.Priority, true
+		}
+	}
+	return nil, 0, false
+}
+
+// queuedView 渲染队列时在锁内取得的任务状态快照 (优先级与置顶状态会被管理员修改)
+type queuedView struct {
+	Task      *QueuedTask
+	Priority  TaskPriority
+	Effective TaskPriority // 老化后的有效优先级
+	Pinned    bool
+}
+
+// PendingSnapshot 按执行顺序返回等待中任务的快照
+func (tm *TaskManager) PendingSnapshot(now time.Time) []queuedView {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.sortPendingLocked()
+	views := make([]queuedView, len(tm.pending))
+	for i, q := range tm.pending {
+		views[i] = queuedView{Task: q, Priority: q.Priority, Effective: q.effectivePriority(now), Pinned: !q.PinnedAt.IsZero()}
+	}
+	return views
+}
+
+// removePendingLocked 从等待队列中移除任务，调用方需持有锁
+func (tm *TaskManager) removePendingLocked(userID int64, taskID int) {
+	for i, q := range tm.pending {
+		if q.UserID == userID && q.TaskID == taskID {
+			tm.pending = append(tm.pending[:i], tm.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// ==================== Bot 集成 ====================
+
+// updateQueuedStatus 更新等待中任务的状态消息（单条任务保留终止按钮）
+func (b *Bot) updateQueuedStatus(q *QueuedTask, status string) {
+	if q.StatusMsg == nil {
+		return
+	}
+	if q.Shared {
+		b.updateSummaryLine(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID, q.Index, b.formatSummaryLine(q, status))
+		return
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(T(q.Lang, "btn.cancel_task"), fmt.Sprintf("cancel_%d_%d", q.UserID, q.TaskID)),
+		),
+	)
+	b.updateTaskMessage(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID, b.formatLine(q, status, false), &keyboard)
+}
+
+// cancelQueuedWithNotice 取消等待中的任务并将状态消息更新为 status
+func (b *Bot) cancelQueuedWithNotice(q *QueuedTask, status string) bool {
+	if !b.taskManager.CancelQueuedTask(q.UserID, q.TaskID) {
+		return false
+	}
+	b.taskLogger(q).Info("取消了队列中的任务")
+	b.notifyTask(EventCancelled, q, "", status)
+	if q.StatusMsg == nil {
+		return true
+	}
+	if q.Shared {
+		chatID, messageID := q.StatusMsg.Chat.ID, q.StatusMsg.MessageID
+		b.updateSummaryLine(chatID, messageID, q.Index, b.formatSummaryLine(q, status))
+		// 递减汇总待完成计数并在必要时清除键盘
+		if remaining := b.taskManager.DecrementSummaryPending(chatID, messageID); remaining <= 0 {
+			b.clearSummaryKeyboard(chatID, messageID)
+		}
+		return true
+	}
+	b.updateTaskMessage(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID, b.formatLine(q, status, false), nil)
+	return true
+}
+
+// handleQueue 处理 /queue 命令
+func (b *Bot) handleQueue(c *commandContext) {
+	text, markup := b.renderQueue(c.Message.From.ID, c.Lang)
+	msg := tgbotapi.NewMessage(c.Message.Chat.ID, text)
+	msg.ReplyToMessageID = c.Message.MessageID
+	msg.DisableWebPagePreview = true
+	msg.ReplyMarkup = markup
+	b.send(msg)
+}
+
+// renderQueue 渲染任务队列。管理员可看到所有任务及其所有者，普通用户只看到自己的任务
+func (b *Bot) renderQueue(viewerID int64, lang string) (string, tgbotapi.InlineKeyboardMarkup) {
+	admin := isAdmin(viewerID)
+	now := time.Now()
+	pending := b.taskManager.PendingSnapshot(now)
+
+	var sb strings.Builder
+	sb.WriteString(T(lang, "queue.title", "count", len(pending)))
+	sb.WriteString("\n")
+
+	if current := b.taskManager.GetCurrentTask(); current != nil && current.Source != nil {
+		if admin || current.UserID == viewerID {
+			sb.WriteString("\n" + T(lang, "queue.running", "id", current.ID, "link", current.Source.displayLink()))
+			if admin {
+				sb.WriteString(" " + T(lang, "queue.owner_inline", "user", current.UserID))
+			}
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString("\n" + T(lang, "queue.running_other") + "\n")
+		}
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	shown, mine := 0, 0
+	for i, v := range pending {
+		q := v.Task
+		if q.UserID == viewerID {
+			mine++
+		}
+		if !admin && q.UserID != viewerID {
+			continue
+		}
+		if shown >= queueDisplayLimit {
+			continue
+		}
+		shown++
+
+		fmt.Fprintf(&sb, "\n%d. [#%d] %s", i+1, q.TaskID, q.displayLink())
+		if v.Pinned {
+			sb.WriteString("\n   " + T(lang, "queue.pinned"))
+		} else {
+			sb.WriteString("\n   " + T(lang, "queue.priority", "name", priorityName(lang, v.Priority)))
+			if v.Effective != v.Priority {
+				sb.WriteString(" " + T(lang, "queue.aged", "name", priorityName(lang, v.Effective)))
+			}
+		}
+		if admin {
+			sb.WriteString("\n   " + T(lang, "queue.owner", "user", q.UserID))
+		}
+
+		row := []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "queue.btn_cancel", "id", q.TaskID), fmt.Sprintf("queue_cancel_%d_%d", q.UserID, q.TaskID)),
+		}
+		if admin && i > 0 {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(T(lang, "queue.btn_top"), fmt.Sprintf("queue_top_%d_%d", q.UserID, q.TaskID)))
+		}
+		if admin {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(T(lang, "queue.btn_priority"), fmt.Sprintf("queue_prio_%d_%d", q.UserID, q.TaskID)))
+		}
+		rows = append(rows, row)
+	}
+
+	switch {
+	case len(pending) == 0:
+		sb.WriteString("\n" + T(lang, "queue.empty"))
+	case shown == 0:
+		sb.WriteString("\n" + T(lang, "queue.empty_mine"))
+	}
+	if (admin && len(pending) > shown) || (!admin && mine > shown) {
+		sb.WriteString("\n\n" + T(lang, "queue.truncated", "count", shown))
+	}
+
+	var footer []tgbotapi.InlineKeyboardButton
+	if mine > 0 {
+		footer = append(footer, tgbotapi.NewInlineKeyboardButtonData(T(lang, "queue.btn_cancel_mine"), "queue_mine"))
+	}
+	footer = append(footer, tgbotapi.NewInlineKeyboardButtonData(T(lang, "queue.btn_refresh"), "queue_refresh"))
+	rows = append(rows, footer)
+
+	return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleQueueCallback 处理 /queue 中的按钮:
+// queue_cancel_<userID>_<taskID>, queue_top_<userID>_<taskID>, queue_prio_<userID>_<taskID>, queue_mine, queue_refresh
+func (b *Bot) handleQueueCallback(query *tgbotapi.CallbackQuery) {
+	viewerID := query.From.ID
+	lang := b.userLang(query.From)
+	if !checkUserPermission(viewerID) {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "common.no_permission"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return
+	}
+
+	notice := ""
+	data := strings.TrimPrefix(query.Data, "queue_")
+	switch {
+	case data == "refresh":
+
+	case data == "mine":
+		count := 0
+		for _, q := range b.taskManager.PendingTasks() {
+			if q.UserID != viewerID {
+				continue
+			}
+			if b.cancelQueuedWithNotice(q, T(q.Lang, "task.cancelled_queue", "id", q.TaskID)) {
+				count++
+			}
+		}
+		notice = T(lang, "queue.cancelled_mine", "count", count)
+
+	case strings.HasPrefix(data, "cancel_"), strings.HasPrefix(data, "top_"), strings.HasPrefix(data, "prio_"):
+		action, ids, _ := strings.Cut(data, "_")
+		var ownerID int64
+		var taskID int
+		if _, err := fmt.Sscanf(ids, "%d_%d", &ownerID, &taskID); err != nil {
+			notice = T(lang, "cb.invalid_task")
+			break
+		}
+		if action == "top" {
+			notice = b.moveQueuedToFront(viewerID, lang, ownerID, taskID)
+			break
+		}
+		if action == "prio" {
+			notice = b.cycleQueuedPriority(viewerID, lang, ownerID, taskID)
+			break
+		}
+		q, ok := b.taskManager.GetQueuedTask(ownerID, taskID)
+		if ok && !b.canCancelFor(viewerID, ownerID, q.Message.Chat) {
+			notice = T(lang, "queue.no_permission_cancel")
+			break
+		}
+		if !ok || b.taskManager.QueuePosition(ownerID, taskID) == 0 {
+			notice = T(lang, "queue.task_started")
+			break
+		}
+		status := T(q.Lang, "task.cancelled_queue", "id", taskID)
+		if ownerID != viewerID {
+			status = T(q.Lang, "task.cancelled_admin", "id", taskID)
+		}
+		if b.cancelQueuedWithNotice(q, status) {
+			b.logger.Info("通过 /queue 取消任务", "operator_id", viewerID, "user_id", ownerID, "task_id", taskID)
+			notice = T(lang, "queue.cancelled", "id", taskID)
+		}
+
+	default:
+		notice = T(lang, "queue.unknown_action")
+	}
+
+	if query.Message != nil {
+		text, markup := b.renderQueue(viewerID, lang)
+		edit := tgbotapi.NewEditMessageTextAndMarkup(query.Message.Chat.ID, query.Message.MessageID, text, markup)
+		edit.DisableWebPagePreview = true
+		if _, err := b.api.Send(edit); err != nil && !strings.Contains(err.Error(), "message is not modified") {
+			b.logger.Warn("更新队列消息失败", "error", err)
+		}
+	}
+	b.api.Request(tgbotapi.NewCallback(query.ID, notice))
+}
+
+// moveQueuedToFront 管理员将任务移到队首，返回给操作者的提示
+func (b *Bot) moveQueuedToFront(operatorID int64, lang string, ownerID int64, taskID int) string {
+	if !isAdmin(operatorID) {
+		return T(lang, "queue.admin_only_order")
+	}
+	q, ok := b.taskManager.MoveToFront(ownerID, taskID)
+	if !ok {
+		return T(lang, "queue.task_started")
+	}
+	b.logger.Info("任务已移至队首", "operator_id", operatorID, "user_id", ownerID, "task_id", taskID)
+	b.updateQueuedStatus(q, T(q.Lang, "task.pinned"))
+	return T(lang, "queue.moved_top", "id", taskID)
+}
+
+// cycleQueuedPriority 管理员循环切换任务优先级 (低 → 普通 → 高 → 低)，返回给操作者的提示
+func (b *Bot) cycleQueuedPriority(operatorID int64, lang string, ownerID int64, taskID int) string {
+	if !isAdmin(operatorID) {
+		return T(lang, "queue.admin_only_priority")
+	}
+	q, next, ok := b.taskManager.CyclePriority(ownerID, taskID)
+	if !ok {
+		return T(lang, "queue.task_started")
+	}
+	b.logger.Info("任务优先级已调整", "operator_id", operatorID, "user_id", ownerID, "task_id", taskID, "priority", next.Key())
+	b.updateQueuedStatus(q, T(q.Lang, "task.priority_changed", "name", priorityName(q.Lang, next)))
+	return T(lang, "queue.priority_set", "id", taskID, "name", priorityName(lang, next))
+}
+
+// handleCancel 处理 /cancel 命令：终止自己正在执行的任务
+func (b *Bot) handleCancel(c *commandContext) {
+	userID := c.Message.From.ID
+	lang := c.Lang
+	current := b.taskManager.GetCurrentTask()
+	if current == nil || current.UserID != userID {
+		b.replyText(c.Message, T(lang, "cancel.none"))
+		return
+	}
+	if !b.taskManager.CancelTask(userID, current.ID) {
+		b.replyText(c.Message, T(lang, "cb.task_gone"))
+		return
+	}
+	b.logger.Info("通过 /cancel 终止了执行中的任务", "user_id", userID, "task_id", current.ID)
+	b.replyText(c.Message, T(lang, "cancel.done", "id", current.ID))
+}
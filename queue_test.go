@@ -0,0 +1,114 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEffectivePriority(t *testing.T) {
+	defer func(old time.Duration) { PriorityAgingInterval = old }(PriorityAgingInterval)
+	PriorityAgingInterval = 10 * time.Minute
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		priority TaskPriority
+		waited   time.Duration
+		want     TaskPriority
+	}{
+		{"fresh low", PriorityLow, 0, PriorityLow},
+		{"low after one interval", PriorityLow, 10 * time.Minute, PriorityNormal},
+		{"low after long wait", PriorityLow, 3 * time.Hour, PriorityHigh},
+		{"high stays high", PriorityHigh, time.Hour, PriorityHigh},
+		{"normal just before interval", PriorityNormal, 9 * time.Minute, PriorityNormal},
+	}
+	for _, tt := range tests {
+		q := &QueuedTask{Priority: tt.priority, EnqueuedAt: now.Add(-tt.waited)}
+		if got := q.effectivePriority(now); got != tt.want {
+			t.Errorf("%s: effectivePriority = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueueLess(t *testing.T) {
+	defer func(old time.Duration) { PriorityAgingInterval = old }(PriorityAgingInterval)
+	PriorityAgingInterval = 0
+	now := time.Now()
+
+	pinnedOld := &QueuedTask{Priority: PriorityLow, PinnedAt: now.Add(-time.Minute), seq: 1}
+	pinnedNew := &QueuedTask{Priority: PriorityLow, PinnedAt: now, seq: 2}
+	high := &QueuedTask{Priority: PriorityHigh, seq: 3}
+	normalFirst := &QueuedTask{Priority: PriorityNormal, seq: 4}
+	normalSecond := &QueuedTask{Priority: PriorityNormal, seq: 5}
+
+	tests := []struct {
+		name string
+		a, b *QueuedTask
+		want bool
+	}{
+		{"pinned before high", pinnedOld, high, true},
+		{"high after pinned", high, pinnedOld, false},
+		{"latest pin first", pinnedNew, pinnedOld, true},
+		{"high before normal", high, normalFirst, true},
+		{"same priority by seq", normalFirst, normalSecond, true},
+		{"same priority later seq", normalSecond, normalFirst, false},
+	}
+	for _, tt := range tests {
+		if got := queueLess(tt.a, tt.b, now); got != tt.want {
+			t.Errorf("%s: queueLess = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCyclePriority(t *testing.T) {
+	tm := NewTaskManager()
+	tm.pending = []*QueuedTask{{UserID: 1, TaskID: 1, Priority: PriorityNormal}}
+
+	want := []TaskPriority{PriorityHigh, PriorityLow, PriorityNormal}
+	for _, w := range want {
+		_, got, ok := tm.CyclePriority(1, 1)
+		if !ok || got != w {
+			t.Fatalf("CyclePriority = %v, %v, want %v", got, ok, w)
+		}
+	}
+	if _, _, ok := tm.CyclePriority(1, 2); ok {
+		t.Error("CyclePriority on a missing task succeeded")
+	}
+}
+
+// 管理员调整优先级与渲染队列、队列处理器排序并发进行 (配合 go test -race)
+func TestPendingSnapshotConcurrentWithCycle(t *testing.T) {
+	tm := NewTaskManager()
+	for i := 1; i <= 5; i++ {
+		tm.pending = append(tm.pending, &QueuedTask{UserID: 1, TaskID: i, EnqueuedAt: time.Now(), seq: uint64(i)})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				tm.CyclePriority(1, j%5+1)
+				tm.MoveToFront(1, j%5+1)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				for _, v := range tm.PendingSnapshot(time.Now()) {
+					_ = v.Priority
+					_ = v.Pinned
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(tm.PendingSnapshot(time.Now())); got != 5 {
+		t.Errorf("snapshot has %d tasks, want 5", got)
+	}
+}
@@ -0,0 +1,371 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// bansFile 临时封禁记录的持久化文件名
+const bansFile = "bans.json"
+
+// tokenBucket 令牌桶，rate 为每秒补充的令牌数，burst 为桶容量
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill 按经过的时间补充令牌
+func (tb *tokenBucket) refill(now time.Time, rate float64, burst int) {
+	if tb.last.IsZero() {
+		tb.tokens = float64(burst)
+	} else if now.After(tb.last) {
+		tb.tokens += now.Sub(tb.last).Seconds() * rate
+		if tb.tokens > float64(burst) {
+			tb.tokens = float64(burst)
+		}
+	}
+	tb.last = now
+}
+
+// ready 桶中是否至少有一个令牌
+func (tb *tokenBucket) ready() bool {
+	return tb.tokens >= 1
+}
+
+// full 桶是否是满的
+func (tb *tokenBucket) full(burst int) bool {
+	return tb.tokens >= float64(burst)
+}
+
+// inboundDecision 限流结果
+type inboundDecision int
+
+const (
+	inboundAllow     inboundDecision = iota
+	inboundThrottled                 // 刚开始被限流，提示一次
+	inboundDropped                   // 限流中，静默丢弃
+	inboundBanned                    // 已被封禁，静默丢弃
+	inboundNewBan                    // 本次触发封禁
+)
+
+// limiterState 单个用户或群组的限流状态
+type limiterState struct {
+	bucket    tokenBucket
+	throttled bool        // 当前是否处于限流中 (已提示过)，令牌桶重新填满后结束
+	strikes   []time.Time // 最近被拒绝的消息时间，用于判定是否封禁
+}
+
+// mediaGroupWindow 相册的各条消息在该时间内到达，之后不再记录相册的限流结果
+const mediaGroupWindow = time.Minute
+
+// mediaGroupState 相册第一条消息的限流结果，相册中其余的消息沿用
+type mediaGroupState struct {
+	at      time.Time
+	allowed bool
+}
+
+// inboundLimiter 按用户和按聊天对入站消息与按钮回调限流，多次触发限流的用户会被临时封禁
+type inboundLimiter struct {
+	mu     sync.Mutex
+	users  map[int64]*limiterState
+	chats  map[int64]*limiterState
+	bans   map[int64]time.Time        // 用户 -> 封禁截止时间
+	groups map[string]mediaGroupState // MediaGroupID -> 第一条消息的限流结果
+}
+
+func newInboundLimiter() *inboundLimiter {
+	return &inboundLimiter{
+		users:  make(map[int64]*limiterState),
+		chats:  make(map[int64]*limiterState),
+		bans:   make(map[int64]time.Time),
+		groups: make(map[string]mediaGroupState),
+	}
+}
+
+func (l *inboundLimiter) load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err := loadJSONFile(bansFile, &l.bans)
+	if l.bans == nil {
+		l.bans = make(map[int64]time.Time)
+	}
+	return err
+}
+
+func (l *inboundLimiter) saveLocked() error {
+	return saveJSONFile(bansFile, l.bans)
+}
+
+// Check 检查一条入站更新是否放行。userID 与 chatID 分别使用各自的令牌桶
+func (l *inboundLimiter) Check(userID, chatID int64, now time.Time) (inboundDecision, time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until, ok := l.bans[userID]; ok {
+		if now.Before(until) {
+			return inboundBanned, until, nil
+		}
+		delete(l.bans, userID)
+		if err := l.saveLocked(); err != nil {
+			return inboundAllow, time.Time{}, err
+		}
+	}
+
+	// 先检查两个令牌桶，都有令牌时才同时取走，避免一个桶拒绝时另一个桶白白消耗
+	user := l.state(l.users, userID)
+	user.bucket.refill(now, InboundUserRate, InboundUserBurst)
+	var chat *limiterState
+	// 私聊中聊天 ID 与用户 ID 相同，只需检查用户令牌桶
+	if chatID != 0 && chatID != userID {
+		chat = l.state(l.chats, chatID)
+		chat.bucket.refill(now, InboundChatRate, InboundChatBurst)
+	}
+
+	if !user.bucket.ready() {
+		if SpamBanThreshold > 0 {
+			user.strikes = append(pruneBefore(user.strikes, now.Add(-SpamBanWindow)), now)
+			if len(user.strikes) >= SpamBanThreshold {
+				until := now.Add(SpamBanDuration)
+				l.bans[userID] = until
+				delete(l.users, userID)
+				return inboundNewBan, until, l.saveLocked()
+			}
+		}
+		if user.throttled {
+			return inboundDropped, time.Time{}, nil
+		}
+		user.throttled = true
+		return inboundThrottled, time.Time{}, nil
+	}
+	if chat != nil && !chat.bucket.ready() {
+		if chat.throttled {
+			return inboundDropped, time.Time{}, nil
+		}
+		chat.throttled = true
+		return inboundThrottled, time.Time{}, nil
+	}
+
+	if user.bucket.full(InboundUserBurst) {
+		user.throttled = false
+	}
+	user.bucket.tokens--
+	if chat != nil {
+		if chat.bucket.full(InboundChatBurst) {
+			chat.throttled = false
+		}
+		chat.bucket.tokens--
+	}
+	return inboundAllow, time.Time{}, nil
+}
+
+// CheckGroup 与 Check 相同，但同一相册 (MediaGroupID) 只有第一条消息消耗令牌：
+// 相册中其余的消息沿用第一条消息的结果，放行时一起放行，拒绝时静默丢弃，不会只处理相册的一部分
+func (l *inboundLimiter) CheckGroup(group string, userID, chatID int64, now time.Time) (inboundDecision, time.Time, error) {
+	if group == "" {
+		return l.Check(userID, chatID, now)
+	}
+	l.mu.Lock()
+	g, ok := l.groups[group]
+	l.mu.Unlock()
+	if ok && now.Sub(g.at) < mediaGroupWindow {
+		if g.allowed {
+			return inboundAllow, time.Time{}, nil
+		}
+		return inboundDropped, time.Time{}, nil
+	}
+
+	decision, until, err := l.Check(userID, chatID, now)
+	l.mu.Lock()
+	l.groups[group] = mediaGroupState{at: now, allowed: decision == inboundAllow}
+	l.mu.Unlock()
+	return decision, until, err
+}
+
+func (l *inboundLimiter) state(m map[int64]*limiterState, id int64) *limiterState {
+	s, ok := m[id]
+	if !ok {
+		s = &limiterState{}
+		m[id] = s
+	}
+	return s
+}
+
+// pruneBefore 移除早于 cutoff 的时间
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	out := times[:0]
+	for _, t := range times {
+		if t.After(cutoff) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Prune 移除空闲的限流状态 (令牌桶已重新填满且 SpamBanWindow 内没有被拒绝的消息)、过期的相册记录和已到期的封禁，
+// 返回移除的限流状态数量
+func (l *inboundLimiter) Prune(now time.Time) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	removed := pruneIdle(l.users, now, InboundUserRate, InboundUserBurst) + pruneIdle(l.chats, now, InboundChatRate, InboundChatBurst)
+	for group, g := range l.groups {
+		if now.Sub(g.at) >= mediaGroupWindow {
+			delete(l.groups, group)
+		}
+	}
+
+	expired := false
+	for userID, until := range l.bans {
+		if !now.Before(until) {
+			delete(l.bans, userID)
+			expired = true
+		}
+	}
+	if expired {
+		return removed, l.saveLocked()
+	}
+	return removed, nil
+}
+
+// pruneIdle 移除空闲的限流状态，移除后再次出现时与新状态等价
+func pruneIdle(m map[int64]*limiterState, now time.Time, rate float64, burst int) int {
+	removed := 0
+	for id, s := range m {
+		s.bucket.refill(now, rate, burst)
+		s.strikes = pruneBefore(s.strikes, now.Add(-SpamBanWindow))
+		if s.bucket.full(burst) && len(s.strikes) == 0 {
+			delete(m, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Unban 解除封禁，用户未被封禁时返回 false
+func (l *inboundLimiter) Unban(userID int64) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.bans[userID]; !ok {
+		return false, nil
+	}
+	delete(l.bans, userID)
+	delete(l.users, userID)
+	return true, l.saveLocked()
+}
+
+// ==================== Bot 集成 ====================
+
+// allowInbound 对消息和按钮回调限流，返回 false 时调用方应丢弃该更新。管理员不受限制
+func (b *Bot) allowInbound(update tgbotapi.Update) bool {
+	var user *tgbotapi.User
+	var chatID int64
+	var group string
+	switch {
+	case update.Message != nil:
+		user = update.Message.From
+		chatID = update.Message.Chat.ID
+		group = update.Message.MediaGroupID
+	case update.CallbackQuery != nil:
+		user = update.CallbackQuery.From
+		if update.CallbackQuery.Message != nil {
+			chatID = update.CallbackQuery.Message.Chat.ID
+		}
+	}
+	if user == nil || isAdmin(user.ID) {
+		return true
+	}
+
+	decision, until, err := b.inbound.CheckGroup(group, user.ID, chatID, time.Now())
+	if err != nil {
+		b.logger.Error("保存封禁记录失败", "error", err)
+	}
+	if decision == inboundAllow {
+		return true
+	}
+
+	lang := b.userLang(user)
+	notice := ""
+	switch decision {
+	case inboundThrottled:
+		b.logger.Warn("入站消息被限流", "user_id", user.ID, "chat_id", chatID)
+		notice = T(lang, "ratelimit.notice")
+	case inboundNewBan:
+		b.logger.Warn("用户因频繁发送消息被临时封禁", "user_id", user.ID, "username", user.UserName, "until", until)
+		notice = T(lang, "ratelimit.banned", "until", until.Format("01-02 15:04"))
+		b.notifyAdminsOfBan(user, until)
+	}
+
+	if update.CallbackQuery != nil {
+		// 按钮回调总是需要应答，否则客户端会一直显示加载中
+		b.api.Request(tgbotapi.NewCallback(update.CallbackQuery.ID, notice))
+	} else if notice != "" {
+		b.replyText(update.Message, notice)
+	}
+	return false
+}
+
+// runInboundPrune 每 InboundPruneInterval 清理一次空闲的限流状态，直到停机
+func (b *Bot) runInboundPrune() {
+	if InboundPruneInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(InboundPruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopping:
+			return
+		case <-ticker.C:
+		}
+		removed, err := b.inbound.Prune(time.Now())
+		if err != nil {
+			b.logger.Error("保存封禁记录失败", "error", err)
+		}
+		if removed > 0 {
+			b.logger.Debug("已清理空闲的限流状态", "count", removed)
+		}
+	}
+}
+
+// notifyAdminsOfBan 通知所有管理员有用户被自动封禁
+func (b *Bot) notifyAdminsOfBan(user *tgbotapi.User, until time.Time) {
+	name := user.UserName
+	if name == "" {
+		name = user.FirstName
+	}
+	for adminID, ok := range AdminUsers {
+		if !ok {
+			continue
+		}
+		lang := b.langOf(adminID)
+		text := T(lang, "ratelimit.admin_banned", "user", user.ID, "name", name, "until", until.Format("01-02 15:04"))
+		if _, err := b.api.Send(tgbotapi.NewMessage(adminID, text)); err != nil {
+			b.logger.Warn("通知管理员失败", "admin_id", adminID, "error", err)
+		}
+	}
+}
+
+// handleUnban 处理 /unban <用户ID> 命令
+func (b *Bot) handleUnban(c *commandContext) {
+	lang := c.Lang
+	userID, err := strconv.ParseInt(c.Args[0], 10, 64)
+	if err != nil {
+		b.replyText(c.Message, T(lang, "cmd.usage", "usage", c.Command.usage(lang)))
+		return
+	}
+	ok, err := b.inbound.Unban(userID)
+	if err != nil {
+		b.logger.Error("保存封禁记录失败", "error", err)
+	}
+	if !ok {
+		b.replyText(c.Message, T(lang, "ratelimit.not_banned", "user", userID))
+		return
+	}
+	b.logger.Info("已解除封禁", "operator_id", c.Message.From.ID, "user_id", userID)
+	b.replyText(c.Message, T(lang, "ratelimit.unbanned", "user", userID))
+}
@@ -0,0 +1,131 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInboundLimiterChatRejectKeepsUserTokens(t *testing.T) {
+	saved := []int{InboundUserBurst, InboundChatBurst, SpamBanThreshold}
+	defer func() { InboundUserBurst, InboundChatBurst, SpamBanThreshold = saved[0], saved[1], saved[2] }()
+	InboundUserBurst, InboundChatBurst, SpamBanThreshold = 3, 1, 0
+
+	l := newInboundLimiter()
+	now := time.Unix(1000, 0)
+	if d, _, _ := l.Check(1, -100, now); d != inboundAllow {
+		t.Fatalf("first message = %v, want allow", d)
+	}
+	for i := 0; i < 5; i++ {
+		if d, _, _ := l.Check(1, -100, now); d == inboundAllow {
+			t.Fatalf("message %d allowed by an empty chat bucket", i+2)
+		}
+	}
+	if got := l.users[1].bucket.tokens; got != 2 {
+		t.Errorf("user tokens after chat rejections = %v, want 2", got)
+	}
+	// 私聊不经过群组的令牌桶
+	for i := 0; i < 2; i++ {
+		if d, _, _ := l.Check(1, 1, now); d != inboundAllow {
+			t.Fatalf("private message %d = %v, want allow", i+1, d)
+		}
+	}
+}
+
+func TestInboundLimiterUserRejectKeepsChatTokens(t *testing.T) {
+	saved := []int{InboundUserBurst, InboundChatBurst, SpamBanThreshold}
+	defer func() { InboundUserBurst, InboundChatBurst, SpamBanThreshold = saved[0], saved[1], saved[2] }()
+	InboundUserBurst, InboundChatBurst, SpamBanThreshold = 1, 3, 0
+
+	l := newInboundLimiter()
+	now := time.Unix(1000, 0)
+	l.Check(1, -100, now)
+	if d, _, _ := l.Check(1, -100, now); d != inboundThrottled {
+		t.Fatalf("second message = %v, want throttled", d)
+	}
+	if got := l.chats[-100].bucket.tokens; got != 2 {
+		t.Errorf("chat tokens after user rejection = %v, want 2", got)
+	}
+	if d, _, _ := l.Check(2, -100, now); d != inboundAllow {
+		t.Errorf("other user in the same chat = %v, want allow", d)
+	}
+}
+
+func TestInboundLimiterPrune(t *testing.T) {
+	saved := []int{InboundUserBurst, SpamBanThreshold}
+	savedRate, savedDir := InboundUserRate, BotDataDir
+	defer func() {
+		InboundUserBurst, SpamBanThreshold = saved[0], saved[1]
+		InboundUserRate, BotDataDir = savedRate, savedDir
+	}()
+	InboundUserBurst, SpamBanThreshold, InboundUserRate = 2, 10, 1
+	BotDataDir = t.TempDir()
+
+	l := newInboundLimiter()
+	now := time.Unix(1000, 0)
+	l.Check(1, 1, now)       // 用户 1 取走一个令牌
+	for i := 0; i < 3; i++ { // 用户 2 被拒绝一次
+		l.Check(2, 2, now)
+	}
+	l.Check(3, 3, now)
+	l.bans[4] = now.Add(time.Second)
+
+	if removed, err := l.Prune(now); err != nil || removed != 0 {
+		t.Fatalf("Prune(now) = %d, %v, want 0 removed", removed, err)
+	}
+	later := now.Add(5 * time.Second)
+	removed, err := l.Prune(later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 用户 1、3 的令牌桶已填满；用户 2 在 SpamBanWindow 内有被拒绝的记录，保留
+	if removed != 2 || len(l.users) != 1 || l.users[2] == nil {
+		t.Errorf("Prune(later) removed %d, users = %v, want only user 2 kept", removed, l.users)
+	}
+	if _, ok := l.bans[4]; ok {
+		t.Error("expired ban kept after Prune")
+	}
+	if removed, _ := l.Prune(later.Add(SpamBanWindow)); removed != 1 || len(l.users) != 0 {
+		t.Errorf("Prune after SpamBanWindow removed %d, users = %v, want all removed", removed, l.users)
+	}
+}
+
+func TestInboundLimiterMediaGroup(t *testing.T) {
+	saved := []int{InboundUserBurst, SpamBanThreshold}
+	savedRate := InboundUserRate
+	defer func() { InboundUserBurst, SpamBanThreshold, InboundUserRate = saved[0], saved[1], savedRate }()
+	InboundUserBurst, SpamBanThreshold, InboundUserRate = 3, 0, 0.001
+
+	l := newInboundLimiter()
+	now := time.Unix(1000, 0)
+	l.Check(1, 1, now)
+	// 10 条消息的相册只消耗一个令牌
+	for i := 0; i < 10; i++ {
+		if d, _, _ := l.CheckGroup("album1", 1, 1, now); d != inboundAllow {
+			t.Fatalf("album message %d = %v, want allow", i+1, d)
+		}
+	}
+	if got := l.users[1].bucket.tokens; got != 1 {
+		t.Errorf("tokens after album = %v, want 1", got)
+	}
+
+	// 第一条消息被拒绝时整个相册都被丢弃
+	l.Check(1, 1, now)
+	if d, _, _ := l.CheckGroup("album2", 1, 1, now); d != inboundThrottled {
+		t.Fatalf("first message of a throttled album = %v, want throttled", d)
+	}
+	l.users[1].bucket.tokens = 3
+	if d, _, _ := l.CheckGroup("album2", 1, 1, now); d != inboundDropped {
+		t.Errorf("rest of a throttled album = %v, want dropped", d)
+	}
+
+	// 过期的相册记录被清理
+	if _, err := l.Prune(now.Add(mediaGroupWindow)); err != nil {
+		t.Fatal(err)
+	}
+	if len(l.groups) != 0 {
+		t.Errorf("groups after Prune = %v, want none", l.groups)
+	}
+}
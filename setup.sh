@@ -121,7 +121,7 @@ compile_program() {
         return 1
     fi
     
-    go build -o "${BINARY_NAME}" tgbot.go
+    go build -o "${BINARY_NAME}" .
     chmod +x "${BINARY_NAME}"
     echo -e "${GREEN}✅ 编译完成${NC}"
     echo ""
@@ -173,6 +173,7 @@ WorkingDirectory=${SCRIPT_DIR}
 ExecStart=${SCRIPT_DIR}/${BINARY_NAME}
 ExecReload=/bin/kill -HUP \$MAINPID
 KillMode=process
+TimeoutStopSec=60
 Restart=always
 RestartSec=10
 StandardOutput=journal
@@ -0,0 +1,241 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// pendingTasksFile 停机时保存未完成任务的文件名
+const pendingTasksFile = "pending_tasks.json"
+
+// persistedTask 停机时保存的队列任务
+type persistedTask struct {
+	UserID          int64    `json:"user_id"`
+	TaskID          int      `json:"task_id"`
+	Link            string   `json:"link"`
+	Album           []string `json:"album,omitempty"` // 转发相册的全部消息链接
+	ChatID          int64    `json:"chat_id"`
+	MessageID       int      `json:"message_id"`        // 用户发送的原始消息
+	StatusMessageID int      `json:"status_message_id"` // Bot 的状态消息
+	Index           int      `json:"index"`
+	Shared          bool     `json:"shared"`
+	Priority        string   `json:"priority,omitempty"` // low / normal / high，缺省为 normal
+	Lang            string   `json:"lang,omitempty"`     // 任务所有者的界面语言
+}
+
+// persistedSummary 停机时保存的汇总消息内容
+type persistedSummary struct {
+	ChatID    int64    `json:"chat_id"`
+	MessageID int      `json:"message_id"`
+	OwnerID   int64    `json:"owner_id"`
+	Lines     []string `json:"lines"`
+}
+
+// pendingState 停机时保存的全部待处理状态
+type pendingState struct {
+	SavedAt   time.Time          `json:"saved_at"`
+	Tasks     []persistedTask    `json:"tasks"`
+	Summaries []persistedSummary `json:"summaries"`
+}
+
+// shutdown 优雅停机：停止接收新链接，等待或中断当前任务，
+// 将所有未完成任务的状态消息更新为已暂停，并持久化剩余任务。
+func (b *Bot) shutdown() error {
+	b.shuttingDown.Store(true)
+	close(b.stopping)
+	close(b.stopQueue)
+
+	finished := false
+	if ShutdownWaitCurrent {
+		if current := b.taskManager.GetCurrentTask(); current != nil {
+			b.logger.Info("等待当前任务完成", "task_id", current.ID, "user_id", current.UserID, "grace_period", ShutdownGracePeriod)
+		}
+		select {
+		case <-b.queueDone:
+			finished = true
+		case <-time.After(ShutdownGracePeriod):
+			b.logger.Warn("宽限期已到，中断当前任务")
+		}
+	}
+
+	var interrupted *QueuedTask
+	if !finished {
+		interrupted = b.taskManager.InterruptCurrentTask()
+		// 进程组已被终止，这里只需短暂等待队列处理器收尾
+		select {
+		case <-b.queueDone:
+		case <-time.After(10 * time.Second):
+			b.logger.Warn("队列处理器未能及时退出")
+		}
+	}
+
+	pending := b.drainQueue()
+	if b.unstarted != nil {
+		pending = append([]*QueuedTask{b.unstarted}, pending...)
+	}
+	if interrupted != nil {
+		pending = append([]*QueuedTask{interrupted}, pending...)
+	}
+
+	state := pendingState{SavedAt: time.Now()}
+	summarySeen := make(map[[2]int64]bool)
+	for _, q := range pending {
+		b.markTaskPaused(q)
+		state.Tasks = append(state.Tasks, persistedTask{
+			UserID:          q.UserID,
+			TaskID:          q.TaskID,
+			Link:            q.Link,
+			Album:           q.Album,
+			ChatID:          q.Message.Chat.ID,
+			MessageID:       q.Message.MessageID,
+			StatusMessageID: q.StatusMsg.MessageID,
+			Index:           q.Index,
+			Shared:          q.Shared,
+			Priority:        q.Priority.Key(),
+			Lang:            q.Lang,
+		})
+
+		if !q.Shared {
+			continue
+		}
+		key := [2]int64{q.StatusMsg.Chat.ID, int64(q.StatusMsg.MessageID)}
+		if summarySeen[key] {
+			continue
+		}
+		summarySeen[key] = true
+		if lines, ok := b.taskManager.GetSummaryLines(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID); ok {
+			state.Summaries = append(state.Summaries, persistedSummary{
+				ChatID:    q.StatusMsg.Chat.ID,
+				MessageID: q.StatusMsg.MessageID,
+				OwnerID:   q.UserID,
+				Lines:     append([]string(nil), lines...),
+			})
+		}
+	}
+
+	if len(state.Tasks) == 0 {
+		b.logger.Info("没有未完成的任务，停机完成")
+		return removeDataFile(pendingTasksFile)
+	}
+
+	if err := saveJSONFile(pendingTasksFile, state); err != nil {
+		return fmt.Errorf("保存未完成任务失败: %w", err)
+	}
+	b.logger.Info("未完成任务已保存，停机完成", "count", len(state.Tasks), "file", dataPath(pendingTasksFile))
+	return nil
+}
+
+// drainQueue 取出队列中剩余的、未被取消的任务
+func (b *Bot) drainQueue() []*QueuedTask {
+	var pending []*QueuedTask
+	for _, q := range b.taskManager.DrainQueue() {
+		q.CancelMutex.Lock()
+		cancelled := q.Cancelled
+		q.CancelMutex.Unlock()
+		if cancelled || q.StatusMsg == nil {
+			continue
+		}
+		pending = append(pending, q)
+	}
+	return pending
+}
+
+// markTaskPaused 将任务的状态消息更新为已暂停（移除按钮，恢复后重新添加）
+func (b *Bot) markTaskPaused(q *QueuedTask) {
+	if q.Shared {
+		b.taskManager.mu.Lock()
+		if km, ok := b.taskManager.summaryKeyboards[q.StatusMsg.Chat.ID]; ok {
+			delete(km, q.StatusMsg.MessageID)
+		}
+		b.taskManager.mu.Unlock()
+		b.updateSummaryLine(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID, q.Index, b.formatSummaryLine(q, T(q.Lang, "task.shutdown_paused")))
+		return
+	}
+	b.updateTaskMessage(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID, b.formatLine(q, T(q.Lang, "task.shutdown_paused"), false), nil)
+}
+
+// restorePendingTasks 启动时恢复上次停机保存的任务并重新排队
+func (b *Bot) restorePendingTasks() {
+	var state pendingState
+	found, err := loadJSONFile(pendingTasksFile, &state)
+	if err != nil {
+		b.logger.Error("恢复未完成任务失败", "error", err)
+		return
+	}
+	if !found {
+		return
+	}
+	// 先删除文件，避免恢复过程中再次停机时重复恢复
+	if err := removeDataFile(pendingTasksFile); err != nil {
+		b.logger.Warn("删除未完成任务文件失败", "error", err)
+	}
+
+	// 统计每条汇总消息中恢复的任务数，作为新的待完成计数
+	summaryCounts := make(map[[2]int64]int)
+	for _, pt := range state.Tasks {
+		if pt.Shared {
+			summaryCounts[[2]int64{pt.ChatID, int64(pt.StatusMessageID)}]++
+		}
+	}
+	for _, ps := range state.Summaries {
+		markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(T(b.langOf(ps.OwnerID), "btn.cancel_all"), fmt.Sprintf("cancel_summary_%d", ps.OwnerID)),
+		))
+		b.taskManager.InitSummary(ps.ChatID, ps.MessageID, ps.Lines, &markup)
+		b.taskManager.SetSummaryPending(ps.ChatID, ps.MessageID, summaryCounts[[2]int64{ps.ChatID, int64(ps.MessageID)}])
+	}
+
+	for _, pt := range state.Tasks {
+		chat := &tgbotapi.Chat{ID: pt.ChatID}
+		priority, _ := parseTaskPriority(pt.Priority)
+		q := &QueuedTask{
+			Link:      pt.Link,
+			Album:     pt.Album,
+			Message:   &tgbotapi.Message{MessageID: pt.MessageID, Chat: chat, From: &tgbotapi.User{ID: pt.UserID}},
+			UserID:    pt.UserID,
+			StatusMsg: &tgbotapi.Message{MessageID: pt.StatusMessageID, Chat: chat},
+			TaskID:    pt.TaskID,
+			Index:     pt.Index,
+			Shared:    pt.Shared,
+			Priority:  priority,
+			Lang:      pt.Lang,
+		}
+		if q.Lang == "" {
+			q.Lang = b.langOf(pt.UserID)
+		}
+
+		// 保证新任务编号不会与恢复的任务冲突
+		b.taskManager.mu.Lock()
+		if b.taskManager.counters[pt.UserID] < pt.TaskID {
+			b.taskManager.counters[pt.UserID] = pt.TaskID
+		}
+		b.taskManager.mu.Unlock()
+
+		restoredText := T(q.Lang, "task.restored")
+		if q.Shared {
+			b.updateSummaryLine(pt.ChatID, pt.StatusMessageID, q.Index, b.formatSummaryLine(q, restoredText))
+		} else {
+			keyboard := tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData(T(q.Lang, "btn.cancel_task"), fmt.Sprintf("cancel_%d_%d", q.UserID, q.TaskID)),
+				),
+			)
+			b.updateTaskMessage(pt.ChatID, pt.StatusMessageID, b.formatLine(q, restoredText, false), &keyboard)
+		}
+		b.taskManager.EnqueueTask(q)
+	}
+
+	b.logger.Info("已恢复上次停机保存的任务", "count", len(state.Tasks), "saved_at", state.SavedAt)
+}
+
+// taskInterrupted 判断任务是否因停机被中断
+func (b *Bot) taskInterrupted(task *Task) bool {
+	b.taskManager.mu.RLock()
+	defer b.taskManager.mu.RUnlock()
+	return task.Interrupted
+}
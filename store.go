@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// dataPath 返回 Bot 数据目录下的文件路径
+func dataPath(name string) string {
+	return filepath.Join(BotDataDir, name)
+}
+
+// loadJSONFile 从数据目录读取 JSON 文件，文件不存在时返回 (false, nil)
+func loadJSONFile(name string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(dataPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("读取 %s 失败: %w", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("解析 %s 失败: %w", name, err)
+	}
+	return true, nil
+}
+
+// saveJSONFile 以原子方式（先写临时文件再重命名）把数据写入数据目录
+func saveJSONFile(name string, v interface{}) error {
+	if err := os.MkdirAll(BotDataDir, 0o700); err != nil {
+		return fmt.Errorf("创建数据目录失败: %w", err)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化 %s 失败: %w", name, err)
+	}
+	path := dataPath(name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("保存 %s 失败: %w", name, err)
+	}
+	return nil
+}
+
+// removeDataFile 删除数据目录中的文件，文件不存在时不报错
+func removeDataFile(name string) error {
+	if err := os.Remove(dataPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,488 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 订阅后端认证方式
+const (
+	SubAuthNone   = "none"   // 不认证
+	SubAuthAPIKey = "apikey" // 固定请求头，如 X-API-Key
+	SubAuthBearer = "bearer" // Authorization: Bearer <token>
+	SubAuthHMAC   = "hmac"   // HMAC-SHA256 请求签名
+)
+
+// SubscriptionAuthConfig 订阅后端认证配置
+type SubscriptionAuthConfig struct {
+	Type   string // none, apikey, bearer, hmac
+	Header string // apikey: 请求头名称 (默认 X-API-Key); hmac: 签名请求头名称 (默认 X-Signature)
+	Token  string // apikey / bearer 使用的令牌; hmac 时作为 X-Key-Id 发送（可选）
+	Secret string // hmac 签名密钥
+}
+
+// SubscriptionPathsConfig 订阅后端接口路径
+type SubscriptionPathsConfig struct {
+	Add    string // 默认 /api/config/add
+	List   string // 默认 /api/config/list
+	Delete string // 默认 /api/config/delete
+}
+
+// SubscriptionFieldsConfig 请求/响应 JSON 字段映射，支持用 . 访问嵌套字段 (如 data.list)
+type SubscriptionFieldsConfig struct {
+	URL       string // 订阅链接字段，默认 sub_url
+	ID        string // 订阅 ID 字段，默认 id
+	Name      string // 订阅名称字段，默认 name
+	Nodes     string // 节点数字段，默认 nodes
+	Message   string // 响应消息字段，默认 message
+	Error     string // 响应错误字段，默认 error
+	ListItems string // 列表响应中订阅数组的位置，为空时自动识别
+}
+
+// SubscriptionBackendConfig 订阅后端配置
+type SubscriptionBackendConfig struct {
+	Name    string                   // 后端名称，用于路由规则和归属记录
+	BaseURL string                   // 基础地址，如 https://sub.example.com 或 http://1.2.3.4:8080
+	CAFile  string                   // 自定义 CA 证书 (PEM)，为空使用系统证书
+	Timeout time.Duration            // 请求超时，默认 10 秒
+	Auth    SubscriptionAuthConfig   // 认证方式
+	Paths   SubscriptionPathsConfig  // 接口路径
+	Fields  SubscriptionFieldsConfig // 字段映射
+}
+
+// SubscriptionRoute 订阅路由规则：按顺序匹配，第一条命中的规则决定使用的后端
+type SubscriptionRoute struct {
+	UserIDs    []int64 // 匹配的用户，为空表示任意用户
+	URLPattern string  // 匹配订阅链接的正则，为空表示任意链接
+	Backend    string  // 目标后端名称
+}
+
+// withDefaults 填充未配置项的默认值
+func (cfg SubscriptionBackendConfig) withDefaults() SubscriptionBackendConfig {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.Auth.Type == "" {
+		cfg.Auth.Type = SubAuthNone
+	}
+	if cfg.Auth.Header == "" {
+		switch cfg.Auth.Type {
+		case SubAuthAPIKey:
+			cfg.Auth.Header = "X-API-Key"
+		case SubAuthHMAC:
+			cfg.Auth.Header = "X-Signature"
+		}
+	}
+	defaultString(&cfg.Paths.Add, "/api/config/add")
+	defaultString(&cfg.Paths.List, "/api/config/list")
+	defaultString(&cfg.Paths.Delete, "/api/config/delete")
+	defaultString(&cfg.Fields.URL, "sub_url")
+	defaultString(&cfg.Fields.ID, "id")
+	defaultString(&cfg.Fields.Name, "name")
+	defaultString(&cfg.Fields.Nodes, "nodes")
+	defaultString(&cfg.Fields.Message, "message")
+	defaultString(&cfg.Fields.Error, "error")
+	return cfg
+}
+
+func defaultString(s *string, def string) {
+	if *s == "" {
+		*s = def
+	}
+}
+
+// httpSubscriptionClient 按配置对接 HTTP(S) 订阅 API 的客户端
+type httpSubscriptionClient struct {
+	cfg  SubscriptionBackendConfig
+	http *http.Client
+}
+
+// NewHTTPSubscriptionClient 根据后端配置创建订阅 API 客户端
+func NewHTTPSubscriptionClient(cfg SubscriptionBackendConfig) (SubscriptionClient, error) {
+	cfg = cfg.withDefaults()
+	if _, err := url.Parse(cfg.BaseURL); err != nil || cfg.BaseURL == "" {
+		return nil, fmt.Errorf("订阅后端 %s 的地址无效: %q", cfg.Name, cfg.BaseURL)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取订阅后端 %s 的 CA 证书失败: %w", cfg.Name, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("订阅后端 %s 的 CA 证书无效", cfg.Name)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	return &httpSubscriptionClient{
+		cfg:  cfg,
+		http: &http.Client{Timeout: cfg.Timeout, Transport: transport},
+	}, nil
+}
+
+// do 发送请求并返回状态码与响应体
+func (c *httpSubscriptionClient) do(ctx context.Context, method, path string, body interface{}) (int, []byte, error) {
+	apiURL := strings.TrimSuffix(c.cfg.BaseURL, "/") + path
+
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("JSON 序列化失败: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.authorize(req, path, payload)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	return resp.StatusCode, data, nil
+}
+
+// authorize 按配置的认证方式为请求添加认证信息。
+// HMAC 签名内容为 "METHOD\nPATH\nTIMESTAMP\nBODY"，十六进制编码后放入签名请求头，
+// 时间戳 (Unix 秒) 放入 X-Timestamp。
+func (c *httpSubscriptionClient) authorize(req *http.Request, path string, body []byte) {
+	auth := c.cfg.Auth
+	switch auth.Type {
+	case SubAuthAPIKey:
+		req.Header.Set(auth.Header, auth.Token)
+	case SubAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+auth.Token)
+	case SubAuthHMAC:
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(auth.Secret))
+		fmt.Fprintf(mac, "%s\n%s\n%s\n", req.Method, path, ts)
+		mac.Write(body)
+		req.Header.Set("X-Timestamp", ts)
+		req.Header.Set(auth.Header, hex.EncodeToString(mac.Sum(nil)))
+		if auth.Token != "" {
+			req.Header.Set("X-Key-Id", auth.Token)
+		}
+	}
+}
+
+// responseText 按字段映射读取响应中的消息与错误
+func (c *httpSubscriptionClient) responseText(body []byte) (message, errMsg string, ok bool) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(body, &m); err != nil {
+		return "", "", false
+	}
+	return jsonScalarString(lookupJSONPath(m, c.cfg.Fields.Message)),
+		jsonScalarString(lookupJSONPath(m, c.cfg.Fields.Error)), true
+}
+
+func (c *httpSubscriptionClient) Add(ctx context.Context, subURL string) (*SubscriptionResult, error) {
+	status, body, err := c.do(ctx, http.MethodPost, c.cfg.Paths.Add, map[string]string{c.cfg.Fields.URL: subURL})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SubscriptionResult{StatusCode: status}
+	message, errMsg, ok := c.responseText(body)
+	if !ok {
+		result.Message = fmt.Sprintf("订阅添加失败 (状态码: %d)", status)
+		return result, nil
+	}
+
+	if status == http.StatusOK {
+		result.Success = true
+		result.Message = message
+		if result.Message == "" {
+			result.Message = "订阅添加成功"
+		}
+		return result, nil
+	}
+
+	result.Message = errMsg
+	if result.Message == "" {
+		result.Message = message
+	}
+	if result.Message == "" {
+		result.Message = fmt.Sprintf("订阅添加失败 (状态码: %d)", status)
+	}
+	result.Duplicate = strings.Contains(result.Message, "已存在") ||
+		strings.Contains(strings.ToLower(result.Message), "already exists")
+	return result, nil
+}
+
+func (c *httpSubscriptionClient) List(ctx context.Context) ([]SubscriptionInfo, error) {
+	status, body, err := c.do(ctx, http.MethodGet, c.cfg.Paths.List, nil)
+	if err != nil {
+		return nil, err
+	}
+	if unsupportedStatus(status) {
+		return nil, fmt.Errorf("%w: GET %s (状态码: %d)", ErrSubscriptionUnsupported, c.cfg.Paths.List, status)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("获取订阅列表失败 (状态码: %d)", status)
+	}
+	return parseSubscriptionList(body, c.cfg.Fields)
+}
+
+func (c *httpSubscriptionClient) Delete(ctx context.Context, sub SubscriptionInfo) error {
+	payload := map[string]string{c.cfg.Fields.URL: sub.URL}
+	if sub.ID != "" {
+		payload[c.cfg.Fields.ID] = sub.ID
+	}
+	status, body, err := c.do(ctx, http.MethodPost, c.cfg.Paths.Delete, payload)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusOK {
+		return nil
+	}
+	if unsupportedStatus(status) {
+		return fmt.Errorf("%w: POST %s (状态码: %d)", ErrSubscriptionUnsupported, c.cfg.Paths.Delete, status)
+	}
+	if message, errMsg, ok := c.responseText(body); ok {
+		if errMsg != "" {
+			return newError("err.sub.backend_message", "message", errMsg)
+		}
+		if message != "" {
+			return newError("err.sub.backend_message", "message", message)
+		}
+	}
+	return newError("err.sub.delete_status", "status", status)
+}
+
+// unsupportedStatus 状态码是否表示后端没有该接口
+func unsupportedStatus(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusMethodNotAllowed || status == http.StatusNotImplemented
+}
+
+// parseSubscriptionList 按字段映射解析订阅列表。未配置 ListItems 时宽松识别：
+// 支持顶层数组或 {"subscriptions"|"configs"|"data"|"items": [...]} 包装，id 可为数字或字符串
+func parseSubscriptionList(body []byte, fields SubscriptionFieldsConfig) ([]SubscriptionInfo, error) {
+	var root interface{}
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("解析订阅列表失败: %w", err)
+	}
+
+	var rawItems interface{}
+	switch r := root.(type) {
+	case []interface{}:
+		rawItems = r
+	case map[string]interface{}:
+		if fields.ListItems != "" {
+			rawItems = lookupJSONPath(r, fields.ListItems)
+		} else {
+			for _, key := range []string{"subscriptions", "configs", "data", "items"} {
+				if v, ok := r[key]; ok {
+					rawItems = v
+					break
+				}
+			}
+		}
+	}
+	list, ok := rawItems.([]interface{})
+	if !ok {
+		if rawItems == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("解析订阅列表失败: 未找到订阅数组")
+	}
+
+	subs := make([]SubscriptionInfo, 0, len(list))
+	for _, raw := range list {
+		item, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		info := SubscriptionInfo{
+			ID:   jsonScalarString(lookupJSONPath(item, fields.ID)),
+			URL:  jsonScalarString(lookupJSONPath(item, fields.URL)),
+			Name: jsonScalarString(lookupJSONPath(item, fields.Name)),
+		}
+		if info.URL == "" {
+			info.URL = jsonScalarString(item["url"])
+		}
+		if n, err := strconv.Atoi(jsonScalarString(lookupJSONPath(item, fields.Nodes))); err == nil {
+			info.Nodes = n
+		}
+		subs = append(subs, info)
+	}
+	return subs, nil
+}
+
+// lookupJSONPath 按 a.b.c 形式读取嵌套 JSON 字段
+func lookupJSONPath(m map[string]interface{}, path string) interface{} {
+	var cur interface{} = m
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = obj[key]
+	}
+	return cur
+}
+
+// jsonScalarString 将 JSON 标量值转为字符串
+func jsonScalarString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	default:
+		return ""
+	}
+}
+
+// ==================== 后端与路由 ====================
+
+// subscriptionBackend 一个已配置的订阅后端及其熔断器
+type subscriptionBackend struct {
+	Name    string
+	BaseURL string
+	Client  SubscriptionClient
+	Breaker *circuitBreaker
+}
+
+// compiledRoute 预编译的路由规则
+type compiledRoute struct {
+	users   map[int64]bool
+	pattern *regexp.Regexp
+	backend *subscriptionBackend
+}
+
+// subscriptionRouter 管理多个订阅后端，并按规则为订阅选择后端
+type subscriptionRouter struct {
+	backends []*subscriptionBackend
+	byName   map[string]*subscriptionBackend
+	routes   []compiledRoute
+}
+
+// newSubscriptionRouter 根据配置创建后端与路由规则，第一个后端为默认后端
+func newSubscriptionRouter(cfgs []SubscriptionBackendConfig, routes []SubscriptionRoute) (*subscriptionRouter, error) {
+	if len(cfgs) == 0 {
+		return nil, fmt.Errorf("未配置任何订阅后端")
+	}
+
+	r := &subscriptionRouter{byName: make(map[string]*subscriptionBackend)}
+	for _, cfg := range cfgs {
+		if cfg.Name == "" {
+			return nil, fmt.Errorf("订阅后端缺少名称: %s", cfg.BaseURL)
+		}
+		if _, dup := r.byName[cfg.Name]; dup {
+			return nil, fmt.Errorf("订阅后端名称重复: %s", cfg.Name)
+		}
+		client, err := NewHTTPSubscriptionClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		registerSecret(cfg.Auth.Token)
+		registerSecret(cfg.Auth.Secret)
+
+		backend := &subscriptionBackend{
+			Name:    cfg.Name,
+			BaseURL: cfg.BaseURL,
+			Client:  client,
+			Breaker: newCircuitBreaker(SubBreakerThreshold, SubBreakerCooldown),
+		}
+		r.backends = append(r.backends, backend)
+		r.byName[cfg.Name] = backend
+	}
+
+	for i, route := range routes {
+		backend, ok := r.byName[route.Backend]
+		if !ok {
+			return nil, fmt.Errorf("订阅路由规则 #%d 引用了不存在的后端: %s", i+1, route.Backend)
+		}
+		cr := compiledRoute{backend: backend}
+		if len(route.UserIDs) > 0 {
+			cr.users = make(map[int64]bool, len(route.UserIDs))
+			for _, id := range route.UserIDs {
+				cr.users[id] = true
+			}
+		}
+		if route.URLPattern != "" {
+			re, err := regexp.Compile(route.URLPattern)
+			if err != nil {
+				return nil, fmt.Errorf("订阅路由规则 #%d 的正则无效: %w", i+1, err)
+			}
+			cr.pattern = re
+		}
+		r.routes = append(r.routes, cr)
+	}
+	return r, nil
+}
+
+// Route 为用户提交的订阅选择后端，无规则命中时使用默认后端
+func (r *subscriptionRouter) Route(userID int64, subURL string) *subscriptionBackend {
+	for _, route := range r.routes {
+		if route.users != nil && !route.users[userID] {
+			continue
+		}
+		if route.pattern != nil && !route.pattern.MatchString(subURL) {
+			continue
+		}
+		return route.backend
+	}
+	return r.backends[0]
+}
+
+// Get 按名称获取后端，名称为空或不存在时返回默认后端（兼容旧记录）
+func (r *subscriptionRouter) Get(name string) *subscriptionBackend {
+	if backend, ok := r.byName[name]; ok {
+		return backend
+	}
+	return r.backends[0]
+}
+
+// All 返回全部后端
+func (r *subscriptionRouter) All() []*subscriptionBackend {
+	return r.backends
+}
+
+// subscriptionBackendsStatus 返回各后端地址及熔断状态，用于 /status
+func (b *Bot) subscriptionBackendsStatus(lang string) string {
+	parts := make([]string, 0, len(b.subRouter.All()))
+	for _, backend := range b.subRouter.All() {
+		parts = append(parts, fmt.Sprintf("%s %s [%s]", backend.Name, backend.BaseURL, backend.Breaker.State(lang)))
+	}
+	return strings.Join(parts, ", ")
+}
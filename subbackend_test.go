@@ -0,0 +1,110 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseSubscriptionList(t *testing.T) {
+	defaults := SubscriptionBackendConfig{}.withDefaults().Fields
+	nested := defaults
+	nested.URL = "url"
+	nested.ListItems = "data.list"
+
+	tests := []struct {
+		name   string
+		body   string
+		fields SubscriptionFieldsConfig
+		want   []SubscriptionInfo
+		err    bool
+	}{
+		{
+			name:   "top-level array",
+			body:   `[{"id": 1, "sub_url": "https://a.example/sub", "name": "a", "nodes": 12}]`,
+			fields: defaults,
+			want:   []SubscriptionInfo{{ID: "1", URL: "https://a.example/sub", Name: "a", Nodes: 12}},
+		},
+		{
+			name:   "wrapped in data",
+			body:   `{"data": [{"id": "x", "sub_url": "https://b.example/sub"}]}`,
+			fields: defaults,
+			want:   []SubscriptionInfo{{ID: "x", URL: "https://b.example/sub"}},
+		},
+		{
+			name:   "url fallback",
+			body:   `{"items": [{"url": "https://c.example/sub"}]}`,
+			fields: defaults,
+			want:   []SubscriptionInfo{{URL: "https://c.example/sub"}},
+		},
+		{
+			name:   "configured nested path",
+			body:   `{"data": {"list": [{"id": 7, "url": "https://d.example/sub"}]}}`,
+			fields: nested,
+			want:   []SubscriptionInfo{{ID: "7", URL: "https://d.example/sub"}},
+		},
+		{
+			name:   "empty object",
+			body:   `{}`,
+			fields: defaults,
+		},
+		{
+			name:   "no array",
+			body:   `{"data": "oops"}`,
+			fields: defaults,
+			err:    true,
+		},
+		{
+			name:   "invalid json",
+			body:   `<html>`,
+			fields: defaults,
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSubscriptionList([]byte(tt.body), tt.fields)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriptionClientUnsupportedEndpoints(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/config/add" {
+			w.Write([]byte(`{"message": "ok"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	client, err := NewHTTPSubscriptionClient(SubscriptionBackendConfig{Name: "test", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if _, err := client.List(ctx); !errors.Is(err, ErrSubscriptionUnsupported) {
+		t.Errorf("List error = %v, want ErrSubscriptionUnsupported", err)
+	}
+	if err := client.Delete(ctx, SubscriptionInfo{URL: "https://a.example/sub"}); !errors.Is(err, ErrSubscriptionUnsupported) {
+		t.Errorf("Delete error = %v, want ErrSubscriptionUnsupported", err)
+	}
+	if res, err := client.Add(ctx, "https://a.example/sub"); err != nil || !res.Success {
+		t.Errorf("Add = %+v, %v, want success", res, err)
+	}
+}
@@ -0,0 +1,156 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// subBatchItem 批量订阅中的一条链接
+type subBatchItem struct {
+	Index int
+	URL   string
+	Check *SubscriptionCheck
+	Err   error
+}
+
+// formatSubBatchLine 生成批量订阅汇总中的一行
+func formatSubBatchLine(index int, subURL, status string) string {
+	return fmt.Sprintf("%d. %s — %s", index+1, subURL, status)
+}
+
+// runBounded 以最多 SubBatchConcurrency 个并发执行 fn
+func runBounded(n int, fn func(i int)) {
+	limit := SubBatchConcurrency
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// handleSubscriptionBatch 检测一条消息中的多个订阅链接，并以单条汇总消息等待用户确认
+func (b *Bot) handleSubscriptionBatch(message *tgbotapi.Message, links []string) {
+	chatID := message.Chat.ID
+	lang := b.userLang(message.From)
+	lines := make([]string, len(links))
+	for i, link := range links {
+		lines[i] = formatSubBatchLine(i, link, T(lang, "sub.batch_checking"))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, strings.Join(lines, "\n\n"))
+	msg.ReplyToMessageID = message.MessageID
+	msg.DisableWebPagePreview = true
+	sentMsg, err := b.send(msg)
+	if err != nil {
+		b.logger.Error("发送汇总消息失败", "chat_id", chatID, "error", err)
+		return
+	}
+	b.taskManager.InitSummary(chatID, sentMsg.MessageID, lines, nil)
+
+	items := make([]subBatchItem, len(links))
+	runBounded(len(links), func(i int) {
+		items[i] = subBatchItem{Index: i, URL: links[i]}
+		items[i].Check, items[i].Err = b.checkSubscription(links[i])
+	})
+
+	// 检测阶段只更新缓存，最后一次性编辑消息，避免频繁编辑
+	valid := 0
+	for _, item := range items {
+		status := ""
+		if item.Err != nil {
+			status = T(lang, "sub.invalid", "error", errorText(lang, item.Err))
+		} else {
+			valid++
+			status = T(lang, "sub.batch_nodes", "count", item.Check.Nodes, "format", subFormatName(lang, item.Check.Format))
+		}
+		b.taskManager.UpdateSummaryLine(chatID, sentMsg.MessageID, item.Index, formatSubBatchLine(item.Index, item.URL, status))
+	}
+
+	// 等待确认期间不占用汇总缓存 (预览可能过期而不会被确认)，汇总行保存在预览中
+	lines, _ = b.taskManager.GetSummaryLines(chatID, sentMsg.MessageID)
+	lines = append([]string(nil), lines...)
+	b.taskManager.RemoveSummary(chatID, sentMsg.MessageID)
+	text := strings.Join(lines, "\n\n")
+	if valid == 0 {
+		b.updateTaskMessage(chatID, sentMsg.MessageID, text+"\n\n"+T(lang, "sub.batch_none_valid"), nil)
+		return
+	}
+
+	id := b.subPreviews.Add(&subPreview{UserID: message.From.ID, Items: items, Lines: lines, Lang: lang})
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "sub.btn_submit_valid", "count", valid), fmt.Sprintf("subok_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "sub.btn_cancel"), fmt.Sprintf("subno_%d", id)),
+		),
+	)
+	b.updateTaskMessage(chatID, sentMsg.MessageID, text, &keyboard)
+}
+
+// submitSubscriptionBatch 以有限并发提交批量订阅中的有效链接，并逐行更新汇总消息。
+// 汇总缓存在全部链接 (包括加入待提交队列的链接) 得到最终结果后删除
+func (b *Bot) submitSubscriptionBatch(chatID int64, messageID int, p *subPreview) {
+	b.taskManager.InitSummary(chatID, messageID, append([]string(nil), p.Lines...), nil)
+	var valid []subBatchItem
+	for _, item := range p.Items {
+		if item.Err == nil {
+			valid = append(valid, item)
+			b.taskManager.UpdateSummaryLine(chatID, messageID, item.Index, formatSubBatchLine(item.Index, item.URL, T(p.Lang, "sub.batch_submitting")))
+		}
+	}
+	b.taskManager.SetSummaryPending(chatID, messageID, len(valid))
+	// 提交开始后移除按钮
+	if lines, ok := b.taskManager.GetSummaryLines(chatID, messageID); ok {
+		b.updateTaskMessage(chatID, messageID, strings.Join(lines, "\n\n"), nil)
+	}
+
+	// 串行化消息编辑，避免并发编辑时旧内容覆盖新内容
+	var editMu sync.Mutex
+	runBounded(len(valid), func(i int) {
+		item := valid[i]
+		suffix := " " + T(p.Lang, "sub.batch_suffix", "count", item.Check.Nodes, "format", subFormatName(p.Lang, item.Check.Format))
+		ref := subStatusRef{ChatID: chatID, MessageID: messageID, Batch: true, Index: item.Index, Suffix: suffix, Lang: p.Lang}
+		responseMsg, deferred := b.addSubscription(p.UserID, item.URL, ref)
+		status := strings.ReplaceAll(responseMsg, "\n", " ") + suffix
+		editMu.Lock()
+		b.updateSummaryLine(chatID, messageID, item.Index, formatSubBatchLine(item.Index, item.URL, status))
+		editMu.Unlock()
+		if !deferred {
+			b.finishSubBatchLine(chatID, messageID)
+		}
+	})
+	b.logger.Info("批量订阅提交完成", "user_id", p.UserID, "count", len(valid))
+}
+
+// cancelSubscriptionBatch 取消批量订阅提交
+func (b *Bot) cancelSubscriptionBatch(chatID int64, messageID int, p *subPreview) {
+	lines := append([]string(nil), p.Lines...)
+	for _, item := range p.Items {
+		if item.Err == nil && item.Index < len(lines) {
+			lines[item.Index] = formatSubBatchLine(item.Index, item.URL, T(p.Lang, "sub.cancelled"))
+		}
+	}
+	b.updateTaskMessage(chatID, messageID, strings.Join(lines, "\n\n"), nil)
+}
+
+// finishSubBatchLine 批量订阅中的一条链接得到最终结果，全部完成后删除汇总缓存
+func (b *Bot) finishSubBatchLine(chatID int64, messageID int) {
+	if b.taskManager.DecrementSummaryPending(chatID, messageID) == 0 {
+		b.taskManager.RemoveSummary(chatID, messageID)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "testing"
+
+func TestFinishSubBatchLineRemovesSummary(t *testing.T) {
+	b := &Bot{taskManager: NewTaskManager()}
+	tm := b.taskManager
+	tm.InitSummary(1, 10, []string{"a", "b", "c"}, nil)
+	tm.SetSummaryPending(1, 10, 2)
+
+	b.finishSubBatchLine(1, 10)
+	if _, ok := tm.GetSummaryLines(1, 10); !ok {
+		t.Fatal("summary removed before every line finished")
+	}
+	b.finishSubBatchLine(1, 10)
+	if _, ok := tm.GetSummaryLines(1, 10); ok {
+		t.Fatal("summary lines kept after the batch finished")
+	}
+	if len(tm.summaryLines) != 0 || len(tm.summaryPendingCounts) != 0 || len(tm.summaryKeyboards) != 0 {
+		t.Errorf("summary caches not empty: lines=%v pending=%v keyboards=%v", tm.summaryLines, tm.summaryPendingCounts, tm.summaryKeyboards)
+	}
+}
+
+func TestSubscriptionOutboxCountMessage(t *testing.T) {
+	o := newSubscriptionOutbox()
+	o.entries[1] = &outboxEntry{ID: 1, Ref: subStatusRef{ChatID: 1, MessageID: 10, Batch: true}}
+	o.entries[2] = &outboxEntry{ID: 2, Ref: subStatusRef{ChatID: 1, MessageID: 10, Batch: true, Index: 1}}
+	o.entries[3] = &outboxEntry{ID: 3, Ref: subStatusRef{ChatID: 1, MessageID: 11, Batch: true}}
+	o.entries[4] = &outboxEntry{ID: 4, Ref: subStatusRef{ChatID: 1, MessageID: 10}}
+
+	if got := o.CountMessage(1, 10); got != 2 {
+		t.Errorf("CountMessage(1, 10) = %d, want 2", got)
+	}
+	if got := o.CountMessage(2, 10); got != 0 {
+		t.Errorf("CountMessage(2, 10) = %d, want 0", got)
+	}
+}
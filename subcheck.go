@@ -0,0 +1,441 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// 订阅格式名称
+const (
+	SubFormatBase64  = "Base64"
+	SubFormatClash   = "Clash"
+	SubFormatSingBox = "sing-box"
+	SubFormatURIList = "URI 列表"
+)
+
+// subFormatName 返回订阅格式的显示名称
+func subFormatName(lang, format string) string {
+	if format == SubFormatURIList {
+		return T(lang, "subformat.uri_list")
+	}
+	return format
+}
+
+// SubscriptionCheck 订阅内容检测结果
+type SubscriptionCheck struct {
+	Format string
+	Nodes  int
+}
+
+// 订阅节点 URI 的协议前缀
+var nodeURIPattern = regexp.MustCompile(`(?i)^(vmess|vless|ss|ssr|trojan|hysteria|hysteria2|hy2|tuic|wireguard|socks5?|anytls)://\S+`)
+
+// Clash 配置中的顶层 proxies 键
+var clashProxiesPattern = regexp.MustCompile(`(?m)^proxies:`)
+
+// sing-box 中不属于代理节点的出站类型
+var singBoxNonProxyTypes = map[string]bool{
+	"direct": true, "block": true, "dns": true, "selector": true, "urltest": true,
+}
+
+// ==================== 下载订阅 ====================
+
+// errSubPrivateAddress 订阅链接 (或其重定向目标) 解析到内网、本机或链路本地地址
+var errSubPrivateAddress = errors.New("订阅链接指向内网或本机地址")
+
+// 不属于 IsPrivate / IsLoopback / IsLinkLocalUnicast 但同样不应访问的 IPv4 地址段
+var subBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+}
+
+// subFetchClient 下载用户提交的订阅链接。用户可以发送任意链接，
+// 因此连接前检查 DNS 解析后的地址，并限制重定向次数与协议
+var subFetchClient = &http.Client{
+	Transport: &http.Transport{
+		// 不使用环境变量中的代理：经代理访问时无法检查目标地址
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   checkSubDialAddress,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) > SubFetchMaxRedirects {
+			return newError("err.sub.too_many_redirects")
+		}
+		if !subURLSchemeAllowed(req.URL.Scheme) {
+			return newError("err.sub.bad_redirect", "scheme", req.URL.Scheme)
+		}
+		return nil
+	},
+}
+
+// subURLSchemeAllowed 只允许 http / https 订阅链接
+func subURLSchemeAllowed(scheme string) bool {
+	return strings.EqualFold(scheme, "http") || strings.EqualFold(scheme, "https")
+}
+
+// checkSubDialAddress 在建立连接前检查已解析的地址，拒绝内网、本机、链路本地等地址
+func checkSubDialAddress(network, address string, _ syscall.RawConn) error {
+	if SubFetchAllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(ip) {
+		return fmt.Errorf("%w: %s", errSubPrivateAddress, ip)
+	}
+	return nil
+}
+
+// publicAddr 地址是否为可以访问的公网单播地址
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range subBlockedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchSubscription 在大小和时间限制内下载订阅内容
+func fetchSubscription(ctx context.Context, subURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, SubValidateTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, subURL, nil)
+	if err != nil || !subURLSchemeAllowed(req.URL.Scheme) {
+		return nil, newError("err.sub.invalid_url")
+	}
+	req.Header.Set("User-Agent", SubValidateUserAgent)
+
+	resp, err := subFetchClient.Do(req)
+	if err != nil {
+		if errors.Is(err, errSubPrivateAddress) {
+			return nil, newError("err.sub.private")
+		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, newError("err.sub.timeout")
+		}
+		return nil, wrapError(err, "err.sub.unreachable")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newError("err.sub.http_status", "status", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, SubValidateMaxBytes+1))
+	if err != nil {
+		return nil, newError("err.sub.read_failed")
+	}
+	if int64(len(data)) > SubValidateMaxBytes {
+		return nil, newError("err.sub.too_large", "size", SubValidateMaxBytes/1024)
+	}
+	return data, nil
+}
+
+// detectSubscription 识别订阅格式并统计节点数，内容为空或无效时返回错误
+func detectSubscription(data []byte) (*SubscriptionCheck, error) {
+	text := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff"))
+	if text == "" {
+		return nil, newError("err.sub.empty")
+	}
+
+	var check *SubscriptionCheck
+	switch {
+	case strings.HasPrefix(text, "{"):
+		n, err := countSingBoxNodes(text)
+		if err != nil {
+			return nil, err
+		}
+		check = &SubscriptionCheck{Format: SubFormatSingBox, Nodes: n}
+	case clashProxiesPattern.MatchString(text):
+		check = &SubscriptionCheck{Format: SubFormatClash, Nodes: countClashNodes(text)}
+	default:
+		if n := countURINodes(text); n > 0 {
+			check = &SubscriptionCheck{Format: SubFormatURIList, Nodes: n}
+		} else if decoded, ok := decodeBase64Loose(text); ok {
+			check = &SubscriptionCheck{Format: SubFormatBase64, Nodes: countURINodes(decoded)}
+		} else {
+			return nil, newError("err.sub.unknown_format")
+		}
+	}
+
+	if check.Nodes == 0 {
+		return nil, newError("err.sub.no_nodes", "format", check.Format)
+	}
+	return check, nil
+}
+
+// countURINodes 统计以节点协议开头的行数
+func countURINodes(text string) int {
+	n := 0
+	for _, line := range strings.Split(text, "\n") {
+		if nodeURIPattern.MatchString(strings.TrimSpace(line)) {
+			n++
+		}
+	}
+	return n
+}
+
+// countClashNodes 统计 Clash YAML 中 proxies 列表的条目数
+func countClashNodes(text string) int {
+	n := 0
+	inProxies := false
+	itemIndent := -1
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if !inProxies {
+			if indent == 0 && strings.HasPrefix(trimmed, "proxies:") {
+				inProxies = true
+				// 行内写法: proxies: [{...}, {...}]
+				if rest := strings.TrimSpace(strings.TrimPrefix(trimmed, "proxies:")); strings.HasPrefix(rest, "[") {
+					return strings.Count(rest, "{")
+				}
+			}
+			continue
+		}
+		// 遇到新的顶层键，proxies 列表结束
+		if indent == 0 && !strings.HasPrefix(trimmed, "-") {
+			break
+		}
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			if itemIndent == -1 {
+				itemIndent = indent
+			}
+			if indent == itemIndent {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// countSingBoxNodes 统计 sing-box 配置中的代理出站数量
+func countSingBoxNodes(text string) (int, error) {
+	var cfg struct {
+		Outbounds []struct {
+			Type string `json:"type"`
+		} `json:"outbounds"`
+	}
+	if err := json.Unmarshal([]byte(text), &cfg); err != nil {
+		return 0, newError("err.sub.bad_json")
+	}
+	n := 0
+	for _, o := range cfg.Outbounds {
+		if !singBoxNonProxyTypes[strings.ToLower(o.Type)] {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// decodeBase64Loose 宽松解码 Base64（忽略空白，兼容 URL 安全字符与缺失的填充）
+func decodeBase64Loose(text string) (string, bool) {
+	compact := strings.Join(strings.Fields(text), "")
+	compact = strings.TrimRight(compact, "=")
+	for _, enc := range []*base64.Encoding{base64.RawStdEncoding, base64.RawURLEncoding} {
+		if decoded, err := enc.DecodeString(compact); err == nil {
+			return string(decoded), true
+		}
+	}
+	return "", false
+}
+
+// ==================== 订阅提交预览 ====================
+
+// subPreviewTTL 预览确认的有效期
+const subPreviewTTL = 10 * time.Minute
+
+// subPreview 等待用户确认的订阅（单条链接或批量链接）
+type subPreview struct {
+	ID        int
+	UserID    int64
+	URL       string
+	Check     *SubscriptionCheck
+	Items     []subBatchItem // 非空时表示批量提交
+	Lines     []string       // 批量提交时检测完成后的汇总行
+	Lang      string         // 提交者的界面语言
+	CreatedAt time.Time
+}
+
+// subPreviewStore 保存等待确认的订阅预览
+type subPreviewStore struct {
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]*subPreview
+}
+
+func newSubPreviewStore() *subPreviewStore {
+	return &subPreviewStore{pending: make(map[int]*subPreview)}
+}
+
+// Add 保存预览并返回其 ID，同时清理过期的预览
+func (s *subPreviewStore) Add(p *subPreview) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, old := range s.pending {
+		if time.Since(old.CreatedAt) > subPreviewTTL {
+			delete(s.pending, id)
+		}
+	}
+	s.nextID++
+	p.ID = s.nextID
+	p.CreatedAt = time.Now()
+	s.pending[p.ID] = p
+	return p.ID
+}
+
+// Take 取出并删除预览
+func (s *subPreviewStore) Take(id int) (*subPreview, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[id]
+	if !ok {
+		return nil, false
+	}
+	delete(s.pending, id)
+	if time.Since(p.CreatedAt) > subPreviewTTL {
+		return nil, false
+	}
+	return p, true
+}
+
+// Peek 查看预览但不删除
+func (s *subPreviewStore) Peek(id int) (*subPreview, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[id]
+	return p, ok
+}
+
+// previewSubscription 检测订阅内容并回复预览与确认按钮
+func (b *Bot) previewSubscription(message *tgbotapi.Message, subURL string) {
+	lang := b.userLang(message.From)
+	statusMsg := tgbotapi.NewMessage(message.Chat.ID, T(lang, "sub.checking"))
+	statusMsg.ReplyToMessageID = message.MessageID
+	sentMsg, err := b.send(statusMsg)
+	if err != nil {
+		b.logger.Error("发送消息失败", "chat_id", message.Chat.ID, "error", err)
+		return
+	}
+
+	check, err := b.checkSubscription(subURL)
+	if err != nil {
+		b.updateTaskMessage(message.Chat.ID, sentMsg.MessageID, T(lang, "sub.invalid", "error", errorText(lang, err))+"\n"+subURL, nil)
+		return
+	}
+
+	id := b.subPreviews.Add(&subPreview{UserID: message.From.ID, URL: subURL, Check: check, Lang: lang})
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "sub.btn_confirm"), fmt.Sprintf("subok_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "sub.btn_cancel"), fmt.Sprintf("subno_%d", id)),
+		),
+	)
+	text := T(lang, "sub.preview", "count", check.Nodes, "format", subFormatName(lang, check.Format), "url", subURL)
+	b.updateTaskMessage(message.Chat.ID, sentMsg.MessageID, text, &keyboard)
+}
+
+// checkSubscription 下载并检测订阅内容
+func (b *Bot) checkSubscription(subURL string) (*SubscriptionCheck, error) {
+	data, err := fetchSubscription(context.Background(), subURL)
+	if err != nil {
+		b.logger.Info("订阅内容获取失败", "sub_url", subURL, "error", err)
+		return nil, err
+	}
+	check, err := detectSubscription(data)
+	if err != nil {
+		b.logger.Info("订阅内容无效", "sub_url", subURL, "bytes", len(data), "error", err)
+		return nil, err
+	}
+	b.logger.Info("订阅内容检测通过", "sub_url", subURL, "format", check.Format, "nodes", check.Nodes)
+	return check, nil
+}
+
+// handleSubPreviewCallback 处理订阅预览的确认/取消按钮: subok_<id> / subno_<id>
+func (b *Bot) handleSubPreviewCallback(query *tgbotapi.CallbackQuery) {
+	lang := b.userLang(query.From)
+	confirm := strings.HasPrefix(query.Data, "subok_")
+	var id int
+	fmt.Sscanf(query.Data[len("subok_"):], "%d", &id)
+
+	p, ok := b.subPreviews.Peek(id)
+	if !ok || query.Message == nil {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "sub.preview_expired"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return
+	}
+	if p.UserID != query.From.ID {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "sub.not_owner"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return
+	}
+	if _, ok := b.subPreviews.Take(id); !ok {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "sub.preview_expired"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return
+	}
+	b.api.Request(tgbotapi.NewCallback(query.ID, ""))
+
+	chatID := query.Message.Chat.ID
+	messageID := query.Message.MessageID
+	if p.Items != nil {
+		if confirm {
+			go b.submitSubscriptionBatch(chatID, messageID, p)
+		} else {
+			b.cancelSubscriptionBatch(chatID, messageID, p)
+		}
+		return
+	}
+	if !confirm {
+		b.updateTaskMessage(chatID, messageID, T(p.Lang, "sub.cancelled")+"\n"+p.URL, nil)
+		return
+	}
+
+	b.updateTaskMessage(chatID, messageID, T(p.Lang, "sub.adding"), nil)
+	responseMsg, _ := b.addSubscription(p.UserID, p.URL, subStatusRef{ChatID: chatID, MessageID: messageID, Lang: p.Lang})
+	b.updateTaskMessage(chatID, messageID, responseMsg, nil)
+}
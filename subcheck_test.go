@@ -0,0 +1,132 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"1.1.1.1", true},
+		{"93.184.216.34", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"0.1.2.3", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:8.8.8.8", true},
+	}
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestFetchSubscriptionRejectsPrivateAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("vmess://node"))
+	}))
+	defer srv.Close()
+
+	_, err := fetchSubscription(context.Background(), srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "内网") {
+		t.Fatalf("fetchSubscription(loopback) error = %v, want private address error", err)
+	}
+
+	SubFetchAllowPrivate = true
+	defer func() { SubFetchAllowPrivate = false }()
+	if data, err := fetchSubscription(context.Background(), srv.URL); err != nil || string(data) != "vmess://node" {
+		t.Fatalf("fetchSubscription with SubFetchAllowPrivate = %q, %v", data, err)
+	}
+}
+
+func TestFetchSubscriptionRedirects(t *testing.T) {
+	SubFetchAllowPrivate = true
+	defer func() { SubFetchAllowPrivate = false }()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/ftp", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/sub", http.StatusFound)
+	})
+	mux.HandleFunc("/once", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/sub", http.StatusFound)
+	})
+	mux.HandleFunc("/sub", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ss://node"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, path := range []string{"/loop", "/ftp"} {
+		if _, err := fetchSubscription(context.Background(), srv.URL+path); err == nil {
+			t.Errorf("fetchSubscription(%s) succeeded, want error", path)
+		}
+	}
+	if data, err := fetchSubscription(context.Background(), srv.URL+"/once"); err != nil || string(data) != "ss://node" {
+		t.Errorf("fetchSubscription(/once) = %q, %v", data, err)
+	}
+	if _, err := fetchSubscription(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("fetchSubscription(file://) succeeded, want error")
+	}
+}
+
+func TestDetectSubscription(t *testing.T) {
+	uriList := "vmess://a\nss://b\n# comment\ntrojan://c\n"
+	tests := []struct {
+		name   string
+		data   string
+		format string
+		nodes  int
+		err    bool
+	}{
+		{name: "uri list", data: uriList, format: SubFormatURIList, nodes: 3},
+		{name: "base64", data: base64.StdEncoding.EncodeToString([]byte(uriList)), format: SubFormatBase64, nodes: 3},
+		{name: "base64 url without padding", data: base64.RawURLEncoding.EncodeToString([]byte("vless://x\nhy2://y")), format: SubFormatBase64, nodes: 2},
+		{name: "clash", data: "port: 7890\nproxies:\n  - name: a\n    type: ss\n  - name: b\n    type: vmess\nproxy-groups:\n  - name: g\n", format: SubFormatClash, nodes: 2},
+		{name: "sing-box", data: `{"outbounds": [{"type": "vless"}, {"type": "direct"}, {"type": "selector"}, {"type": "trojan"}]}`, format: SubFormatSingBox, nodes: 2},
+		{name: "empty", data: "  \n", err: true},
+		{name: "html", data: "<html><body>not found</body></html>", err: true},
+		{name: "sing-box without proxies", data: `{"outbounds": [{"type": "direct"}]}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := detectSubscription([]byte(tt.data))
+			if tt.err {
+				if err == nil {
+					t.Fatalf("detectSubscription = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Format != tt.format || got.Nodes != tt.nodes {
+				t.Errorf("detectSubscription = %+v, want %s/%d", got, tt.format, tt.nodes)
+			}
+		})
+	}
+}
@@ -0,0 +1,435 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SubscriptionInfo 订阅 API 中的一条订阅
+type SubscriptionInfo struct {
+	ID    string `json:"id"`
+	URL   string `json:"sub_url"`
+	Name  string `json:"name,omitempty"`
+	Nodes int    `json:"nodes,omitempty"`
+}
+
+// SubscriptionResult 添加订阅的结果（API 已响应）
+type SubscriptionResult struct {
+	Success    bool
+	Duplicate  bool
+	Message    string
+	StatusCode int
+}
+
+// SubscriptionClient 订阅 API 客户端。
+// 只有 Add 是订阅后端必须提供的接口；/subs、/unsub、/subinfo 还需要后端提供列表与删除接口，
+// 后端没有这两个接口时 List / Delete 返回 ErrSubscriptionUnsupported，不以本地记录代替
+type SubscriptionClient interface {
+	// Add 添加订阅，仅在无法得到 API 响应时返回 error
+	Add(ctx context.Context, subURL string) (*SubscriptionResult, error)
+	// List 列出 API 中的全部订阅
+	List(ctx context.Context) ([]SubscriptionInfo, error)
+	// Delete 删除订阅
+	Delete(ctx context.Context, sub SubscriptionInfo) error
+}
+
+// ErrSubscriptionUnsupported 订阅后端未提供列表或删除接口 (HTTP 404 / 405 / 501)
+var ErrSubscriptionUnsupported = errors.New("订阅后端不支持该接口")
+
+// ==================== 订阅归属记录 ====================
+
+// subscriptionsFile 订阅归属记录文件名
+const subscriptionsFile = "subscriptions.json"
+
+// subscriptionRecord 记录某条订阅由哪个 Telegram 用户添加
+type subscriptionRecord struct {
+	URL     string    `json:"url"`
+	OwnerID int64     `json:"owner_id"`
+	Backend string    `json:"backend,omitempty"` // 所在订阅后端，为空表示默认后端
+	AddedAt time.Time `json:"added_at"`
+}
+
+// subscriptionStore 持久化的订阅归属记录
+type subscriptionStore struct {
+	mu      sync.Mutex
+	records map[string]*subscriptionRecord // 规范化 URL -> 记录
+}
+
+func newSubscriptionStore() *subscriptionStore {
+	return &subscriptionStore{records: make(map[string]*subscriptionRecord)}
+}
+
+// normalizeSubURL 规范化订阅链接用于比较
+func normalizeSubURL(u string) string {
+	return strings.TrimSuffix(strings.TrimSpace(u), "/")
+}
+
+func (s *subscriptionStore) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var records []*subscriptionRecord
+	if _, err := loadJSONFile(subscriptionsFile, &records); err != nil {
+		return err
+	}
+	for _, r := range records {
+		s.records[normalizeSubURL(r.URL)] = r
+	}
+	return nil
+}
+
+// saveLocked 保存记录，调用方需持有锁
+func (s *subscriptionStore) saveLocked() error {
+	records := make([]*subscriptionRecord, 0, len(s.records))
+	for _, r := range s.records {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].AddedAt.Before(records[j].AddedAt) })
+	return saveJSONFile(subscriptionsFile, records)
+}
+
+// Record 记录订阅归属（已存在时保留最早的归属）
+func (s *subscriptionStore) Record(subURL string, ownerID int64, backend string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := normalizeSubURL(subURL)
+	if _, ok := s.records[key]; ok {
+		return nil
+	}
+	s.records[key] = &subscriptionRecord{URL: subURL, OwnerID: ownerID, Backend: backend, AddedAt: time.Now()}
+	return s.saveLocked()
+}
+
+// Remove 删除订阅归属记录
+func (s *subscriptionStore) Remove(subURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, normalizeSubURL(subURL))
+	return s.saveLocked()
+}
+
+// Get 获取订阅归属记录
+func (s *subscriptionStore) Get(subURL string) (subscriptionRecord, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[normalizeSubURL(subURL)]
+	if !ok {
+		return subscriptionRecord{}, false
+	}
+	return *r, true
+}
+
+// OwnedBy 返回用户添加的全部订阅（按添加时间排序）
+func (s *subscriptionStore) OwnedBy(ownerID int64) []subscriptionRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []subscriptionRecord
+	for _, r := range s.records {
+		if r.OwnerID == ownerID {
+			out = append(out, *r)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AddedAt.Before(out[j].AddedAt) })
+	return out
+}
+
+// ==================== Bot 订阅操作 ====================
+
+// addSubscription 添加订阅到 API，并记录添加者，返回结果文本。API 不可达时加入待提交队列 (deferred 为 true)，
+// ref 指向展示结果的消息，队列重试完成后会编辑该消息。
+func (b *Bot) addSubscription(userID int64, subURL string, ref subStatusRef) (string, bool) {
+	backend := b.subRouter.Route(userID, subURL)
+	b.logger.Info("发送订阅请求", "user_id", userID, "sub_url", subURL, "backend", backend.Name)
+
+	result, err := b.submitSubscription(backend, subURL)
+	if err != nil {
+		b.logger.Warn("订阅 API 请求失败", "sub_url", subURL, "backend", backend.Name, "error", err)
+		return b.queueSubscription(userID, backend.Name, subURL, ref, err)
+	}
+
+	if result.Success {
+		b.logger.Info("订阅添加成功", "sub_url", subURL, "backend", backend.Name, "message", result.Message)
+		if err := b.subStore.Record(subURL, userID, backend.Name); err != nil {
+			b.logger.Warn("保存订阅归属失败", "error", err)
+		}
+	} else {
+		b.logger.Warn("订阅添加失败", "sub_url", subURL, "status", result.StatusCode, "error", result.Message)
+	}
+	return subscriptionResultText(result), false
+}
+
+// subsPageSize /subs 每页显示的订阅数
+const subsPageSize = 10
+
+// userSubscription 用户订阅列表中的一项（本地归属记录 + API 信息）
+type userSubscription struct {
+	Record      subscriptionRecord
+	Info        SubscriptionInfo
+	InAPI       bool // API 中是否仍存在
+	Checked     bool // 是否成功从 API 获取过列表
+	Unsupported bool // 后端未提供列表接口
+}
+
+// listUserSubscriptions 合并本地归属记录与各后端的 API 列表，返回用户自己的订阅。
+// 只查询用户订阅所在的后端；任一后端查询失败时返回最后一个错误。
+func (b *Bot) listUserSubscriptions(userID int64) ([]userSubscription, error) {
+	owned := b.subStore.OwnedBy(userID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	type backendList struct {
+		byURL map[string]SubscriptionInfo
+		err   error
+	}
+	lists := make(map[string]*backendList)
+	var lastErr error
+	for _, r := range owned {
+		backend := b.subRouter.Get(r.Backend)
+		if _, ok := lists[backend.Name]; ok {
+			continue
+		}
+		apiSubs, err := backend.Client.List(ctx)
+		bl := &backendList{byURL: make(map[string]SubscriptionInfo, len(apiSubs)), err: err}
+		for _, s := range apiSubs {
+			bl.byURL[normalizeSubURL(s.URL)] = s
+		}
+		if err != nil {
+			lastErr = err
+			b.logger.Warn("获取订阅列表失败", "backend", backend.Name, "error", err)
+		}
+		lists[backend.Name] = bl
+	}
+
+	out := make([]userSubscription, 0, len(owned))
+	for _, r := range owned {
+		bl := lists[b.subRouter.Get(r.Backend).Name]
+		us := userSubscription{
+			Record:      r,
+			Info:        SubscriptionInfo{URL: r.URL},
+			Checked:     bl.err == nil,
+			Unsupported: errors.Is(bl.err, ErrSubscriptionUnsupported),
+		}
+		if info, ok := bl.byURL[normalizeSubURL(r.URL)]; ok {
+			us.Info = info
+			us.InAPI = true
+		}
+		out = append(out, us)
+	}
+	return out, lastErr
+}
+
+// findUserSubscription 按 API ID 或订阅链接查找用户自己的订阅
+func (b *Bot) findUserSubscription(userID int64, ref string) (*userSubscription, error) {
+	subs, err := b.listUserSubscriptions(userID)
+	ref = strings.TrimSpace(ref)
+	for i := range subs {
+		if subs[i].Info.ID != "" && subs[i].Info.ID == ref {
+			return &subs[i], err
+		}
+		if normalizeSubURL(subs[i].Record.URL) == normalizeSubURL(ref) {
+			return &subs[i], err
+		}
+	}
+	return nil, err
+}
+
+// handleSubs 处理 /subs 命令
+func (b *Bot) handleSubs(c *commandContext) {
+	text, markup := b.renderSubsPage(c.Message.From.ID, c.Lang, 0)
+	msg := tgbotapi.NewMessage(c.Message.Chat.ID, text)
+	msg.ReplyToMessageID = c.Message.MessageID
+	msg.DisableWebPagePreview = true
+	if markup != nil {
+		msg.ReplyMarkup = *markup
+	}
+	b.send(msg)
+}
+
+// renderSubsPage 渲染订阅列表的某一页
+func (b *Bot) renderSubsPage(userID int64, lang string, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	subs, err := b.listUserSubscriptions(userID)
+	if len(subs) == 0 {
+		if err != nil {
+			return T(lang, "subs.list_failed"), nil
+		}
+		return T(lang, "subs.empty"), nil
+	}
+
+	pages := (len(subs) + subsPageSize - 1) / subsPageSize
+	if page < 0 {
+		page = 0
+	}
+	if page >= pages {
+		page = pages - 1
+	}
+	start := page * subsPageSize
+	end := start + subsPageSize
+	if end > len(subs) {
+		end = len(subs)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(T(lang, "subs.title", "count", len(subs), "page", page+1, "pages", pages) + "\n")
+	switch {
+	case errors.Is(err, ErrSubscriptionUnsupported):
+		sb.WriteString(T(lang, "subs.list_unsupported") + "\n")
+	case err != nil:
+		sb.WriteString(T(lang, "subs.local_only") + "\n")
+	}
+	for i := start; i < end; i++ {
+		s := subs[i]
+		state := ""
+		if s.Checked && !s.InAPI {
+			state = " " + T(lang, "subs.missing_in_api")
+		}
+		id := s.Info.ID
+		if id == "" {
+			id = "-"
+		}
+		fmt.Fprintf(&sb, "\n%d. [ID: %s]%s\n%s", i+1, id, state, s.Record.URL)
+	}
+	sb.WriteString("\n\n" + T(lang, "subs.hint"))
+
+	if pages <= 1 {
+		return sb.String(), nil
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(T(lang, "subs.btn_prev"), fmt.Sprintf("subs_page_%d_%d", userID, page-1)))
+	}
+	if page < pages-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(T(lang, "subs.btn_next"), fmt.Sprintf("subs_page_%d_%d", userID, page+1)))
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(row)
+	return sb.String(), &markup
+}
+
+// handleSubsPageCallback 处理订阅列表翻页按钮: subs_page_<userID>_<page>
+func (b *Bot) handleSubsPageCallback(query *tgbotapi.CallbackQuery) {
+	lang := b.userLang(query.From)
+	var ownerID int64
+	var page int
+	if _, err := fmt.Sscanf(strings.TrimPrefix(query.Data, "subs_page_"), "%d_%d", &ownerID, &page); err != nil || query.Message == nil {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "subs.invalid_page"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return
+	}
+	if ownerID != query.From.ID {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "subs.not_owner"))
+		callback.ShowAlert = true
+		b.api.Request(callback)
+		return
+	}
+
+	text, markup := b.renderSubsPage(ownerID, lang, page)
+	edit := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
+	edit.DisableWebPagePreview = true
+	edit.ReplyMarkup = markup
+	if _, err := b.api.Send(edit); err != nil {
+		b.logger.Warn("更新订阅列表失败", "error", err)
+	}
+	b.api.Request(tgbotapi.NewCallback(query.ID, ""))
+}
+
+// handleUnsub 处理 /unsub <链接|ID> 命令
+func (b *Bot) handleUnsub(c *commandContext) {
+	lang := c.Lang
+	ref := c.Args[0]
+
+	sub, err := b.findUserSubscription(c.Message.From.ID, ref)
+	if sub == nil {
+		if err != nil {
+			b.replyText(c.Message, T(lang, "subs.list_failed"))
+			return
+		}
+		b.replyText(c.Message, T(lang, "subs.not_found"))
+		return
+	}
+
+	// 后端列表中已没有该订阅：只清理本地记录，并说明没有调用删除接口
+	if sub.Checked && !sub.InAPI {
+		if err := b.subStore.Remove(sub.Record.URL); err != nil {
+			b.logger.Warn("删除订阅归属失败", "error", err)
+		}
+		b.logger.Info("订阅已不在 API 中，删除本地记录", "user_id", c.Message.From.ID, "sub_url", sub.Record.URL)
+		b.replyText(c.Message, T(lang, "unsub.not_in_api", "url", sub.Record.URL))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := b.subRouter.Get(sub.Record.Backend).Client.Delete(ctx, sub.Info); err != nil {
+		b.logger.Warn("删除订阅失败", "user_id", c.Message.From.ID, "sub_url", sub.Record.URL, "error", err)
+		if errors.Is(err, ErrSubscriptionUnsupported) {
+			b.replyText(c.Message, T(lang, "unsub.unsupported"))
+			return
+		}
+		b.replyText(c.Message, T(lang, "unsub.failed", "error", errorText(lang, err)))
+		return
+	}
+	if err := b.subStore.Remove(sub.Record.URL); err != nil {
+		b.logger.Warn("删除订阅归属失败", "error", err)
+	}
+	b.logger.Info("订阅已删除", "user_id", c.Message.From.ID, "sub_url", sub.Record.URL)
+	b.replyText(c.Message, T(lang, "unsub.done", "url", sub.Record.URL))
+}
+
+// handleSubInfo 处理 /subinfo <链接|ID> 命令
+func (b *Bot) handleSubInfo(c *commandContext) {
+	lang := c.Lang
+	ref := c.Args[0]
+
+	sub, err := b.findUserSubscription(c.Message.From.ID, ref)
+	if sub == nil {
+		if err != nil {
+			b.replyText(c.Message, T(lang, "subs.list_failed"))
+			return
+		}
+		b.replyText(c.Message, T(lang, "subs.not_found"))
+		return
+	}
+
+	apiState := T(lang, "subinfo.api_exists")
+	switch {
+	case sub.Unsupported:
+		apiState = T(lang, "subinfo.api_unsupported")
+	case !sub.Checked:
+		apiState = T(lang, "subinfo.api_unreachable")
+	case !sub.InAPI:
+		apiState = T(lang, "subinfo.api_missing")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(T(lang, "subinfo.title") + "\n\n")
+	sb.WriteString(T(lang, "subinfo.link", "url", sub.Record.URL) + "\n")
+	if sub.Info.ID != "" {
+		sb.WriteString(T(lang, "subinfo.id", "id", sub.Info.ID) + "\n")
+	}
+	if sub.Info.Name != "" {
+		sb.WriteString(T(lang, "subinfo.name", "name", sub.Info.Name) + "\n")
+	}
+	if sub.Info.Nodes > 0 {
+		sb.WriteString(T(lang, "subinfo.nodes", "count", sub.Info.Nodes) + "\n")
+	}
+	if len(b.subRouter.All()) > 1 {
+		sb.WriteString(T(lang, "subinfo.backend", "name", b.subRouter.Get(sub.Record.Backend).Name) + "\n")
+	}
+	sb.WriteString(T(lang, "subinfo.added_at", "time", sub.Record.AddedAt.Format("2006-01-02 15:04")) + "\n")
+	sb.WriteString(T(lang, "subinfo.api_state", "state", apiState))
+
+	msg := tgbotapi.NewMessage(c.Message.Chat.ID, sb.String())
+	msg.ReplyToMessageID = c.Message.MessageID
+	msg.DisableWebPagePreview = true
+	b.send(msg)
+}
@@ -13,6 +13,7 @@ tdl_dir="${tdl_data_dir}"
 tdl_bin="${tdl_dir}/tdl"
 version_file="${tdl_dir}/.version"
 lock_dir="/tmp/tdl_locks"  # 锁文件目录
+namespace="${TDL_NAMESPACE:-default}"  # 账号 (tdl 命名空间)，由 Bot 按任务指定
 
 # 创建数据目录
 mkdir -p "${tdl_data_dir}/data"
@@ -207,6 +208,7 @@ login_tdl() {
 run_tdl() {
     local str="${1:-}"
     local task_id="${2:-1}"  # 任务ID用于锁文件命名
+    local target="${3:-1838605845}"  # 转发目标
     local lock_file="${lock_dir}/task_${task_id}.lock"
     local lock_fd
     
@@ -230,7 +232,7 @@ run_tdl() {
     touch "$temp_output" || true
     
     # 在后台执行转发，保存 PID (即使失败也继续)
-    "${tdl_bin}" forward --from "$str" --to 1838605845 --single -n "default" --mode clone --storage "type=bolt,path=${tdl_data_dir}/data" > "$temp_output" 2>&1 &
+    "${tdl_bin}" forward --from "$str" --to "$target" --single -n "$namespace" --mode clone --storage "type=bolt,path=${tdl_data_dir}/data" > "$temp_output" 2>&1 &
     local forward_pid=$!
     
     # 等待一下确保文件有内容
@@ -332,7 +334,7 @@ run_tdl() {
         flock -u "$lock_fd"
         
         # 执行登录（交互式）
-        login_tdl "default"
+        login_tdl "$namespace"
         login_result=$?
         
         # 清理临时文件
@@ -344,7 +346,7 @@ run_tdl() {
         if [ $login_result -eq 0 ]; then
             # 登录成功，重新执行转发
             echo -e "[STATUS]🔄 重新开始转发任务"
-            exec "$0" "$str" "$task_id"
+            exec "$0" "$str" "$task_id" "$target"
         else
             # 登录失败
             flock -u "$lock_fd"
@@ -365,17 +367,76 @@ run_tdl() {
     return $exit_code
 }
 
+#导出来源的消息列表 (不下载媒体)，用于转发前预览
+#TDL_EXPORT_LIMIT 为正数时只导出最近的该数量条消息
+export_tdl() {
+    local chat="$1"
+    local output="$2"
+    local msg_id="${3:-}"
+    local limit="${TDL_EXPORT_LIMIT:-}"
+    local range_args=()
+
+    # 单条消息只导出该消息
+    if [[ -n "$msg_id" ]]; then
+        range_args=(-T id -i "${msg_id},${msg_id}")
+    elif [[ "$limit" =~ ^[1-9][0-9]*$ ]]; then
+        range_args=(-T last -i "$limit")
+    fi
+    "${tdl_bin}" chat export -c "$chat" ${range_args[@]+"${range_args[@]}"} --all --raw -o "$output" -n "$namespace" --storage "type=bolt,path=${tdl_data_dir}/data"
+}
+
+#检查会话是否已登录、转发目标是否可以访问 (读取目标的最新一条消息)
+check_tdl() {
+    local target="$1"
+    local output
+    local result=0
+
+    if [[ -z "$target" ]]; then
+        echo -e "${Error} 未指定转发目标"
+        return 1
+    fi
+    if [[ ! -x "${tdl_bin}" ]]; then
+        echo -e "${Error} tdl 未安装"
+        return 1
+    fi
+
+    output=$(mktemp /tmp/tdl_check_XXXXXX.json)
+    "${tdl_bin}" chat export -c "$target" -T last -i 1 -o "$output" -n "$namespace" --storage "type=bolt,path=${tdl_data_dir}/data" || result=$?
+    rm -f "$output"
+    return $result
+}
+
 #主函数
 main() {
     local param="${1:-}"
     local task_id="${2:-1}"
+    local target="${3:-1838605845}"
+
+    # 会话检查: tdl.sh --check <转发目标>，不检查更新，由 Bot 管理版本时同样可用
+    if [[ "$param" == "--check" ]]; then
+        check_tdl "${2:-}"
+        return $?
+    fi
     
-    # 检查并更新版本
-    check_and_update
+    # 检查并更新版本 (由 Bot 管理 tdl 版本时跳过)
+    if [[ -n "${TDL_MANAGED:-}" ]]; then
+        if [[ ! -x "${tdl_bin}" ]]; then
+            echo -e "${Error} tdl 未安装，请检查 Bot 日志"
+            return 1
+        fi
+    else
+        check_and_update
+    fi
+
+    # 预览模式: tdl.sh --export <聊天> <输出文件> [消息ID]
+    if [[ "$param" == "--export" ]]; then
+        export_tdl "${2:-}" "${3:-}" "${4:-}"
+        return $?
+    fi
     
     # 执行命令 (传递task_id用于锁管理)
-    run_tdl "$param" "$task_id"
+    run_tdl "$param" "$task_id" "$target"
 }
 
 # 执行主函数
-main "${1:-}" "${2:-1}"
+main "$@"
@@ -0,0 +1,190 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTDLScript 将 tdl.sh 复制到临时目录并放入假的 tdl，返回脚本路径与记录 tdl 参数的文件。
+// 假的 tdl 输出 $FAKE_TDL_OUTPUT 并以 $FAKE_TDL_EXIT 退出
+func fakeTDLScript(t *testing.T) (script, argsFile string) {
+	t.Helper()
+	if _, err := exec.LookPath("bash"); err != nil {
+		t.Skip("bash not found")
+	}
+	dir := t.TempDir()
+	data, err := os.ReadFile("tdl.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	script = filepath.Join(dir, "tdl.sh")
+	if err := os.WriteFile(script, data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".tdl"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	argsFile = filepath.Join(dir, "tdl_args")
+	fake := "#!/bin/sh\necho \"$*\" > " + argsFile + "\necho \"${FAKE_TDL_OUTPUT:-}\"\nexit ${FAKE_TDL_EXIT:-0}\n"
+	if err := os.WriteFile(filepath.Join(dir, ".tdl", "tdl"), []byte(fake), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return script, argsFile
+}
+
+// runTDLScript 使用假的 tdl 运行 tdl.sh，返回 tdl 收到的参数 (未调用时为空) 与脚本的退出码
+func runTDLScript(t *testing.T, env []string, args ...string) (string, int) {
+	t.Helper()
+	script, argsFile := fakeTDLScript(t)
+	cmd := exec.Command("bash", append([]string{script}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("run tdl.sh: %v\n%s", err, output)
+	}
+	data, _ := os.ReadFile(argsFile)
+	return strings.TrimSpace(string(data)), code
+}
+
+func TestTDLScriptExport(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+		args []string
+		want string
+	}{
+		{"whole chat", nil, []string{"--export", "chan", "/tmp/out.json"}, "chat export -c chan --all --raw -o /tmp/out.json -n acc"},
+		{"limited", []string{"TDL_EXPORT_LIMIT=1000"}, []string{"--export", "chan", "/tmp/out.json"}, "chat export -c chan -T last -i 1000 --all --raw -o /tmp/out.json -n acc"},
+		{"single message ignores limit", []string{"TDL_EXPORT_LIMIT=1000"}, []string{"--export", "chan", "/tmp/out.json", "42"}, "chat export -c chan -T id -i 42,42 --all --raw -o /tmp/out.json -n acc"},
+		{"invalid limit", []string{"TDL_EXPORT_LIMIT=abc"}, []string{"--export", "chan", "/tmp/out.json"}, "chat export -c chan --all --raw -o /tmp/out.json -n acc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := append([]string{"TDL_MANAGED=1", "TDL_NAMESPACE=acc"}, tt.env...)
+			got, code := runTDLScript(t, env, tt.args...)
+			if code != 0 {
+				t.Fatalf("exit code = %d", code)
+			}
+			if !strings.HasPrefix(got, tt.want+" --storage ") {
+				t.Errorf("tdl args = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTDLScriptCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		env  []string
+		args []string
+		want string
+		code int
+	}{
+		{"managed", []string{"TDL_MANAGED=1"}, []string{"--check", "target"}, "chat export -c target -T last -i 1", 0},
+		{"unmanaged skips update", nil, []string{"--check", "target"}, "chat export -c target -T last -i 1", 0},
+		{"target unreachable", []string{"TDL_MANAGED=1", "FAKE_TDL_EXIT=1"}, []string{"--check", "target"}, "chat export -c target -T last -i 1", 1},
+		{"missing target", []string{"TDL_MANAGED=1"}, []string{"--check"}, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, code := runTDLScript(t, append([]string{"TDL_NAMESPACE=acc"}, tt.env...), tt.args...)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d", code, tt.code)
+			}
+			if tt.want == "" && got != "" {
+				t.Errorf("tdl called with %q, want no call", got)
+			}
+			if tt.want != "" && !strings.HasPrefix(got, tt.want+" ") {
+				t.Errorf("tdl args = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckSession(t *testing.T) {
+	script, argsFile := fakeTDLScript(t)
+	savedScript, savedTarget := TDLScriptPath, ForwardTarget
+	defer func() { TDLScriptPath, ForwardTarget = savedScript, savedTarget }()
+	TDLScriptPath, ForwardTarget = script, "-100123"
+	t.Setenv("TDL_MANAGED", "1")
+
+	tests := []struct {
+		output string
+		exit   string
+		want   string
+	}{
+		{"", "0", sessionOK},
+		{"rpc error: AUTH_KEY_UNREGISTERED", "1", sessionUnauthorized},
+		{"Error: not authorized. please login first", "1", sessionUnauthorized},
+		{"rpc error code 400: CHANNEL_PRIVATE", "1", sessionUnreachable},
+		{"dial tcp: i/o timeout", "1", sessionError},
+	}
+	b := &Bot{}
+	for _, tt := range tests {
+		t.Setenv("FAKE_TDL_OUTPUT", tt.output)
+		t.Setenv("FAKE_TDL_EXIT", tt.exit)
+		if state, out := b.checkSession("acc"); state != tt.want {
+			t.Errorf("checkSession with %q = %s, want %s\n%s", tt.output, state, tt.want, out)
+		}
+		if data, _ := os.ReadFile(argsFile); !strings.Contains(string(data), "-c -100123 ") || !strings.Contains(string(data), "-n acc ") {
+			t.Errorf("tdl args = %q, want target -100123 and namespace acc", data)
+		}
+	}
+}
+
+func TestCheckSessionsHoldsTDL(t *testing.T) {
+	b, _ := newTestBot(t)
+	script, argsFile := fakeTDLScript(t)
+	// 检查需要一段时间，以便在检查过程中确认 tdl 被占用
+	slow := "#!/bin/sh\necho \"$*\" > " + argsFile + "\nsleep 0.5\n"
+	if err := os.WriteFile(filepath.Join(filepath.Dir(script), ".tdl", "tdl"), []byte(slow), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	savedScript, savedTarget := TDLScriptPath, ForwardTarget
+	defer func() { TDLScriptPath, ForwardTarget = savedScript, savedTarget }()
+	TDLScriptPath, ForwardTarget = script, "-100123"
+	t.Setenv("TDL_MANAGED", "1")
+	b.accounts = newAccountPool()
+	b.accounts.accounts = []*tdlAccount{{Name: "acc"}}
+
+	// 任务正在执行时跳过本轮
+	b.tdlMu.Lock()
+	b.checkSessions()
+	b.tdlMu.Unlock()
+	if _, err := os.Stat(argsFile); err == nil {
+		t.Fatal("session check ran while a task held the tdl slot")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.checkSessions()
+	}()
+	for i := 0; ; i++ {
+		if _, err := os.Stat(argsFile); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("session check did not run")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if b.tdlMu.TryLock() {
+		b.tdlMu.Unlock()
+		t.Error("tdl slot free while the session check was running")
+	}
+	<-done
+	if !b.health.Passed("acc") {
+		t.Error("account did not pass the session check")
+	}
+}
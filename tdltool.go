@@ -0,0 +1,540 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tdlToolFile 已安装 tdl 版本的持久化文件名
+const tdlToolFile = "tdl_tool.json"
+
+var (
+	tdlVersionPattern  = regexp.MustCompile(`^v\d+\.\d+\.\d+[0-9A-Za-z.-]*$`)
+	tdlChecksumPattern = regexp.MustCompile(`^[0-9A-Fa-f]{64}$`)
+)
+
+var (
+	errTDLBusy             = newError("err.tdl.busy")
+	errTDLVersion          = newError("err.tdl.bad_version")
+	errTDLChecksumRequired = newError("err.tdl.checksum_required")
+)
+
+// tdlToolState 当前与上一个 tdl 版本，用于显式升级后的回滚
+type tdlToolState struct {
+	Current   string    `json:"current"`
+	Previous  string    `json:"previous,omitempty"`
+	Pinned    string    `json:"pinned"` // 最近一次安装的 TDLVersion，配置中的固定版本变化时重新安装
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// tdlTool 管理 tdl 可执行文件：安装固定版本、校验 SHA-256、缓存下载的压缩包。
+// 各版本安装在 .tdl/versions/<版本>/tdl，.tdl/tdl 为指向当前版本的符号链接 (tdl.sh 使用该路径)
+type tdlTool struct {
+	mu        sync.Mutex
+	state     tdlToolState
+	upgrading atomic.Bool // 升级期间暂停启动新任务
+}
+
+func newTDLTool() *tdlTool {
+	return &tdlTool{}
+}
+
+// tdlDir tdl.sh 使用的 TDL 数据目录
+func tdlDir() string {
+	return filepath.Join(filepath.Dir(TDLScriptPath), ".tdl")
+}
+
+// tdlAsset 当前系统架构对应的发布文件名 (与 tdl.sh 的架构判断一致)
+func tdlAsset() (string, error) {
+	arch, ok := map[string]string{"386": "32bit", "amd64": "64bit", "arm64": "arm64", "arm": "armhf"}[runtime.GOARCH]
+	if !ok {
+		return "", newError("err.tdl.unsupported_arch", "arch", runtime.GOARCH)
+	}
+	return "tdl_Linux_" + arch + ".tar.gz", nil
+}
+
+// Version 当前使用的版本
+func (t *tdlTool) Version() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state.Current
+}
+
+// State 返回状态副本
+func (t *tdlTool) State() tdlToolState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.state
+}
+
+// ensure 启动时确保已安装 tdl：固定版本未变化时保持已有安装 (包括管理员显式升级的版本)，
+// 否则安装 TDLVersion。离线模式下没有压缩包时继续使用已安装的版本，或接管旧版 tdl.sh 下载的二进制
+func (t *tdlTool) ensure(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := loadJSONFile(tdlToolFile, &t.state); err != nil {
+		return err
+	}
+	if t.state.Current != "" && t.state.Pinned == TDLVersion {
+		if _, err := os.Stat(t.binaryPath(t.state.Current)); err == nil {
+			return t.activateLocked(t.state.Current)
+		}
+	}
+	if err := t.installLocked(ctx, TDLVersion, ""); err != nil {
+		if !TDLOffline {
+			return err
+		}
+		// 离线模式下没有固定版本的压缩包时继续使用已安装的 tdl
+		if t.state.Current != "" {
+			if _, statErr := os.Stat(t.binaryPath(t.state.Current)); statErr == nil {
+				return t.activateLocked(t.state.Current)
+			}
+		}
+		if adoptErr := t.adoptLocked(); adoptErr != nil {
+			return fmt.Errorf("离线模式下没有可用的 tdl: %v; %w", err, adoptErr)
+		}
+		return nil
+	}
+	t.state.Pinned = TDLVersion
+	return t.switchLocked(TDLVersion)
+}
+
+// Upgrade 安装并切换到指定版本。checksum 为空时使用 TDLChecksums 中固定的校验值，两者都没有时拒绝升级，
+// 不信任与压缩包同一来源的校验文件。冒烟测试失败时自动回滚到原版本，原来没有版本时撤销切换
+func (t *tdlTool) Upgrade(ctx context.Context, version, checksum string, smoke func() error) error {
+	if !tdlVersionPattern.MatchString(version) {
+		return errTDLVersion
+	}
+	if checksum == "" && !hasPinnedChecksum(version) {
+		return errTDLChecksumRequired
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.installLocked(ctx, version, checksum); err != nil {
+		return err
+	}
+	prev := t.state
+	previous := prev.Current
+	if err := t.switchLocked(version); err != nil {
+		return err
+	}
+	if err := smoke(); err != nil {
+		if previous == version {
+			return wrapError(err, "err.tdl.smoke_failed")
+		}
+		if previous == "" {
+			if rmErr := t.deactivateLocked(); rmErr != nil {
+				return wrapError(errors.Join(err, rmErr), "err.tdl.undo_failed")
+			}
+			t.state = prev
+			t.saveLocked()
+			return wrapError(err, "err.tdl.smoke_not_switched", "version", version)
+		}
+		if rbErr := t.switchLocked(previous); rbErr != nil {
+			return wrapError(errors.Join(err, rbErr), "err.tdl.rollback_failed")
+		}
+		t.state.Previous = ""
+		t.saveLocked()
+		return wrapError(err, "err.tdl.smoke_rolled_back", "version", previous)
+	}
+	return nil
+}
+
+// Rollback 切换回上一个版本
+func (t *tdlTool) Rollback(smoke func() error) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	previous, current := t.state.Previous, t.state.Current
+	if previous == "" {
+		return "", newError("err.tdl.no_previous")
+	}
+	if err := t.switchLocked(previous); err != nil {
+		return "", err
+	}
+	if err := smoke(); err != nil {
+		t.switchLocked(current)
+		return "", wrapError(err, "err.tdl.smoke_kept", "version", current)
+	}
+	return previous, nil
+}
+
+func (t *tdlTool) binaryPath(version string) string {
+	return filepath.Join(tdlDir(), "versions", version, "tdl")
+}
+
+func (t *tdlTool) saveLocked() error {
+	t.state.UpdatedAt = time.Now()
+	return saveJSONFile(tdlToolFile, t.state)
+}
+
+// switchLocked 将 .tdl/tdl 指向指定版本并记录上一个版本
+func (t *tdlTool) switchLocked(version string) error {
+	if err := t.activateLocked(version); err != nil {
+		return err
+	}
+	if t.state.Current != version {
+		t.state.Previous = t.state.Current
+		t.state.Current = version
+	}
+	return t.saveLocked()
+}
+
+// activateLocked 以原子方式更新 .tdl/tdl 符号链接，并写入 tdl.sh 使用的 .version
+func (t *tdlTool) activateLocked(version string) error {
+	link := filepath.Join(tdlDir(), "tdl")
+	tmp := link + ".new"
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Join("versions", version, "tdl"), tmp); err != nil {
+		return wrapError(err, "err.tdl.symlink_failed")
+	}
+	if err := os.Rename(tmp, link); err != nil {
+		return wrapError(err, "err.tdl.switch_failed")
+	}
+	return os.WriteFile(filepath.Join(tdlDir(), ".version"), []byte(version+"\n"), 0o644)
+}
+
+// deactivateLocked 移除 .tdl/tdl 符号链接与 .version，之后 tdl.sh 视为未安装
+func (t *tdlTool) deactivateLocked() error {
+	if err := os.Remove(filepath.Join(tdlDir(), "tdl")); err != nil && !os.IsNotExist(err) {
+		return wrapError(err, "err.tdl.unlink_failed")
+	}
+	if err := os.Remove(filepath.Join(tdlDir(), ".version")); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// adoptLocked 接管 tdl.sh 之前安装的二进制 (无法校验，仅用于离线模式)
+func (t *tdlTool) adoptLocked() error {
+	link := filepath.Join(tdlDir(), "tdl")
+	info, err := os.Lstat(link)
+	if err != nil || !info.Mode().IsRegular() {
+		return errors.New("未找到已安装的 tdl，请设置 TDLTarball")
+	}
+	data, _ := os.ReadFile(filepath.Join(tdlDir(), ".version"))
+	version := strings.TrimSpace(string(data))
+	if version == "" {
+		version = "unknown"
+	}
+	dest := t.binaryPath(version)
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(link, dest); err != nil {
+		return fmt.Errorf("移动 tdl 失败: %w", err)
+	}
+	return t.switchLocked(version)
+}
+
+// installLocked 安装指定版本 (已安装时跳过)。压缩包来源依次为 TDLTarball、本地缓存、GitHub 下载。
+// checksum 不为空时使用该校验值，否则由 tdlChecksum 决定
+func (t *tdlTool) installLocked(ctx context.Context, version, checksum string) error {
+	if _, err := os.Stat(t.binaryPath(version)); err == nil {
+		return nil
+	}
+	asset, err := tdlAsset()
+	if err != nil {
+		return err
+	}
+	tarball := TDLTarball
+	if tarball == "" || version != TDLVersion {
+		tarball = filepath.Join(tdlDir(), "cache", version+"_"+asset)
+		if _, err := os.Stat(tarball); err != nil {
+			if TDLOffline {
+				return newError("err.tdl.offline_no_cache", "version", version)
+			}
+			if err := downloadTDL(ctx, version, asset, tarball); err != nil {
+				return err
+			}
+		}
+	}
+
+	want := checksum
+	if want == "" {
+		if want, err = tdlChecksum(ctx, version, asset); err != nil {
+			return err
+		}
+	}
+	got, err := fileSHA256(tarball)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(got, want) {
+		// 缓存的压缩包损坏时删除，下次重新下载
+		if tarball != TDLTarball {
+			os.Remove(tarball)
+		}
+		return newError("err.tdl.checksum_mismatch", "asset", asset, "want", want, "got", got)
+	}
+	return extractTDL(tarball, t.binaryPath(version))
+}
+
+// hasPinnedChecksum 当前架构下 TDLChecksums 是否配置了该版本的校验值
+func hasPinnedChecksum(version string) bool {
+	asset, err := tdlAsset()
+	return err == nil && TDLChecksums[version+"/"+asset] != ""
+}
+
+// tdlChecksum 返回发布文件的 SHA-256：优先使用 TDLChecksums 中固定的值，否则下载发布的校验文件
+func tdlChecksum(ctx context.Context, version, asset string) (string, error) {
+	if sum := TDLChecksums[version+"/"+asset]; sum != "" {
+		return sum, nil
+	}
+	if TDLOffline {
+		return "", newError("err.tdl.offline_checksum", "version", version, "asset", asset)
+	}
+	body, err := fetchRelease(ctx, version, TDLChecksumsAsset)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == asset {
+			return fields[0], nil
+		}
+	}
+	return "", newError("err.tdl.checksum_missing", "asset", asset)
+}
+
+// downloadTDL 下载发布文件到缓存目录
+func downloadTDL(ctx context.Context, version, asset, dest string) error {
+	body, err := fetchRelease(ctx, version, asset)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	tmp := dest + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return wrapError(err, "err.tdl.download_failed", "name", asset)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
+// fetchRelease 下载 GitHub 发布中的文件，依次尝试 TDLDownloadMirrors
+func fetchRelease(ctx context.Context, version, name string) (io.ReadCloser, error) {
+	url := fmt.Sprintf("https://github.com/iyear/tdl/releases/download/%s/%s", version, name)
+	ctx, cancel := context.WithTimeout(ctx, TDLDownloadTimeout)
+	var lastErr error
+	for _, mirror := range TDLDownloadMirrors {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, mirror+url, nil)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			lastErr = fmt.Errorf("HTTP %d", resp.StatusCode)
+			continue
+		}
+		return cancelOnClose{resp.Body, cancel}, nil
+	}
+	cancel()
+	return nil, wrapError(lastErr, "err.tdl.download_failed", "name", name)
+}
+
+// cancelOnClose 关闭响应体时释放下载超时的 context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// extractTDL 从压缩包中解压 tdl 可执行文件
+func extractTDL(tarball, dest string) error {
+	f, err := os.Open(tarball)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return wrapError(err, "err.tdl.extract_failed")
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return newError("err.tdl.not_in_archive")
+		}
+		if err != nil {
+			return wrapError(err, "err.tdl.extract_failed")
+		}
+		if hdr.Typeflag != tar.TypeReg || filepath.Base(hdr.Name) != "tdl" {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return err
+		}
+		tmp := dest + ".tmp"
+		out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			os.Remove(tmp)
+			return wrapError(err, "err.tdl.extract_failed")
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		return os.Rename(tmp, dest)
+	}
+}
+
+// ==================== Bot 集成 ====================
+
+// smokeTestTDL 检查当前 tdl 是否可用：能够运行，且上次检查正常的账号仍能通过会话检查
+func (b *Bot) smokeTestTDL() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, filepath.Join(tdlDir(), "tdl"), "version").CombinedOutput(); err != nil {
+		return wrapError(fmt.Errorf("%v: %s", err, truncateString(string(out), 200)), "err.tdl.version_failed")
+	}
+	for _, name := range b.accounts.Names() {
+		if !b.health.Passed(name) {
+			continue
+		}
+		if state, _ := b.checkSession(name); state != sessionOK {
+			return newError("err.tdl.session_failed", "name", name, "state", state)
+		}
+		break
+	}
+	return nil
+}
+
+// tdlStatusText /status 中显示的 tdl 版本
+func (b *Bot) tdlStatusText(lang string) string {
+	switch {
+	case !TDLManaged:
+		return T(lang, "tdl.state_script")
+	case b.tdl.upgrading.Load():
+		return T(lang, "tdl.state_upgrading")
+	case b.tdl.Version() == "":
+		return T(lang, "tdl.state_missing")
+	}
+	return b.tdl.Version()
+}
+
+// handleTDL 处理 /tdl [upgrade <版本> [SHA-256]|rollback] 命令
+func (b *Bot) handleTDL(c *commandContext) {
+	lang := c.Lang
+	if !TDLManaged {
+		b.replyText(c.Message, T(lang, "tdl.unmanaged"))
+		return
+	}
+	if len(c.Args) == 0 {
+		state := b.tdl.State()
+		b.replyText(c.Message, T(lang, "tdl.status", "version", state.Current, "previous", state.Previous, "pinned", TDLVersion))
+		return
+	}
+	action := strings.ToLower(c.Args[0])
+	var version, checksum string
+	if action == "upgrade" {
+		if len(c.Args) < 2 {
+			b.replyText(c.Message, T(lang, "cmd.usage", "usage", c.Command.usage(lang)))
+			return
+		}
+		version = c.Args[1]
+		if len(c.Args) > 2 {
+			checksum = c.Args[2]
+			if !tdlChecksumPattern.MatchString(checksum) {
+				b.replyText(c.Message, T(lang, "tdl.bad_checksum"))
+				return
+			}
+		} else if !hasPinnedChecksum(version) {
+			b.replyText(c.Message, T(lang, "tdl.checksum_required", "version", version))
+			return
+		}
+	}
+
+	// 升级期间持有 tdl，队列不会启动新任务；有任务、预览或会话检查正在使用 tdl 时不升级
+	if !b.tdl.upgrading.CompareAndSwap(false, true) {
+		b.replyText(c.Message, T(lang, "tdl.in_progress"))
+		return
+	}
+	if !b.tdlMu.TryLock() {
+		b.tdl.upgrading.Store(false)
+		b.replyText(c.Message, T(lang, "tdl.failed", "error", errorText(lang, errTDLBusy)))
+		return
+	}
+	b.replyText(c.Message, T(lang, "tdl.working"))
+
+	go func() {
+		defer func() {
+			b.tdlMu.Unlock()
+			b.tdl.upgrading.Store(false)
+			b.taskManager.notifyQueue()
+		}()
+		var err error
+		if action == "rollback" {
+			version, err = b.tdl.Rollback(b.smokeTestTDL)
+		} else {
+			err = b.tdl.Upgrade(context.Background(), version, checksum, b.smokeTestTDL)
+		}
+		if err != nil {
+			b.logger.Error("切换 tdl 版本失败", "operator_id", c.Message.From.ID, "action", action, "version", version, "error", err)
+			b.replyText(c.Message, T(lang, "tdl.failed", "error", errorText(lang, err)))
+			return
+		}
+		b.logger.Info("tdl 版本已切换", "operator_id", c.Message.From.ID, "action", action, "version", version)
+		b.replyText(c.Message, T(lang, "tdl.done", "version", version))
+	}()
+}
@@ -0,0 +1,163 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupTDLDir 在临时目录中准备 tdl.sh 所在目录与 Bot 数据目录
+func setupTDLDir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	savedScript, savedData, savedOffline := TDLScriptPath, BotDataDir, TDLOffline
+	savedSums := TDLChecksums
+	t.Cleanup(func() {
+		TDLScriptPath, BotDataDir, TDLOffline = savedScript, savedData, savedOffline
+		TDLChecksums = savedSums
+	})
+	TDLScriptPath = filepath.Join(dir, "tdl.sh")
+	BotDataDir = filepath.Join(dir, ".bot")
+	TDLOffline = true
+	TDLChecksums = map[string]string{}
+	if err := os.MkdirAll(tdlDir(), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cacheTDLTarball 在缓存目录中放入包含 tdl 的压缩包，返回其 SHA-256
+func cacheTDLTarball(t *testing.T, version string) string {
+	t.Helper()
+	asset, err := tdlAsset()
+	if err != nil {
+		t.Skip(err)
+	}
+	path := filepath.Join(tdlDir(), "cache", version+"_"+asset)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	body := []byte("#!/bin/sh\necho " + version + "\n")
+	tw.WriteHeader(&tar.Header{Name: "tdl", Mode: 0o755, Size: int64(len(body)), Typeflag: tar.TypeReg})
+	tw.Write(body)
+	tw.Close()
+	gz.Close()
+	f.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func currentLink(t *testing.T) string {
+	t.Helper()
+	target, err := os.Readlink(filepath.Join(tdlDir(), "tdl"))
+	if err != nil {
+		return ""
+	}
+	return target
+}
+
+func TestTDLUpgradeRequiresChecksum(t *testing.T) {
+	setupTDLDir(t)
+	cacheTDLTarball(t, "v0.19.0")
+	tool := newTDLTool()
+	ok := func() error { return nil }
+
+	if err := tool.Upgrade(context.Background(), "v0.19.0", "", ok); !errors.Is(err, errTDLChecksumRequired) {
+		t.Fatalf("Upgrade without checksum = %v, want errTDLChecksumRequired", err)
+	}
+	if err := tool.Upgrade(context.Background(), "v0.19.0", strings.Repeat("0", 64), ok); err == nil || !strings.Contains(err.Error(), "校验失败") {
+		t.Fatalf("Upgrade with wrong checksum = %v, want checksum error", err)
+	}
+	if tool.Version() != "" {
+		t.Fatalf("version after failed upgrades = %q, want none", tool.Version())
+	}
+
+	// 校验失败时删除了缓存，重新放入后使用 TDLChecksums 中的值
+	sum := cacheTDLTarball(t, "v0.19.0")
+	asset, _ := tdlAsset()
+	TDLChecksums["v0.19.0/"+asset] = sum
+	if err := tool.Upgrade(context.Background(), "v0.19.0", "", ok); err != nil {
+		t.Fatalf("Upgrade with pinned checksum: %v", err)
+	}
+	if tool.Version() != "v0.19.0" || currentLink(t) != filepath.Join("versions", "v0.19.0", "tdl") {
+		t.Errorf("version = %q, link = %q, want v0.19.0", tool.Version(), currentLink(t))
+	}
+}
+
+func TestTDLUpgradeSmokeFailure(t *testing.T) {
+	setupTDLDir(t)
+	tool := newTDLTool()
+	fail := func() error { return errors.New("smoke") }
+	ok := func() error { return nil }
+
+	// 原来没有版本：冒烟测试失败时不切换
+	sum := cacheTDLTarball(t, "v0.19.0")
+	if err := tool.Upgrade(context.Background(), "v0.19.0", sum, fail); err == nil {
+		t.Fatal("Upgrade with failing smoke test succeeded")
+	}
+	if state := tool.State(); state.Current != "" || state.Previous != "" || currentLink(t) != "" {
+		t.Fatalf("after failed first upgrade: state = %+v, link = %q, want nothing active", state, currentLink(t))
+	}
+	if _, err := os.Stat(filepath.Join(tdlDir(), ".version")); !os.IsNotExist(err) {
+		t.Errorf(".version kept after failed first upgrade: %v", err)
+	}
+
+	if err := tool.Upgrade(context.Background(), "v0.19.0", sum, ok); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已有版本：冒烟测试失败时回滚
+	sum = cacheTDLTarball(t, "v0.20.0")
+	if err := tool.Upgrade(context.Background(), "v0.20.0", sum, fail); err == nil {
+		t.Fatal("Upgrade with failing smoke test succeeded")
+	}
+	if state := tool.State(); state.Current != "v0.19.0" || currentLink(t) != filepath.Join("versions", "v0.19.0", "tdl") {
+		t.Errorf("after failed upgrade: state = %+v, link = %q, want v0.19.0", state, currentLink(t))
+	}
+}
+
+func TestHandleTDLWaitsForTDLSlot(t *testing.T) {
+	b, fake := newTestBot(t)
+	setupTDLDir(t)
+	savedManaged := TDLManaged
+	TDLManaged = true
+	defer func() { TDLManaged = savedManaged }()
+
+	// 队列处理器已取得 tdl (例如任务已出队但尚未设置为当前任务)
+	b.tdlMu.Lock()
+	cmd := b.newCommandRouter().commands["tdl"]
+	b.handleTDL(&commandContext{Message: testMessage(), Command: cmd, Lang: LangEN, Args: []string{"rollback"}})
+	b.tdlMu.Unlock()
+
+	want := T(LangEN, "tdl.failed", "error", errorText(LangEN, errTDLBusy))
+	if sent := fake.sent(); len(sent) != 1 || sent[0] != want {
+		t.Errorf("replies = %q, want %q", sent, want)
+	}
+	if b.tdl.upgrading.Load() {
+		t.Error("upgrading flag left set after a busy reply")
+	}
+	if !b.tdlMu.TryLock() {
+		t.Fatal("tdl slot still held")
+	}
+	b.tdlMu.Unlock()
+}
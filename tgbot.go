@@ -5,20 +5,18 @@ package main
 
 import (
 	"bufio"
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -34,27 +32,224 @@ const (
 	SubscriptionAPIKey  = "123456"
 )
 
+// 订阅后端配置 (第一个为默认后端)。BaseURL 支持 http:// 与 https://，
+// Auth.Type 可选 none / apikey / bearer / hmac，Paths 与 Fields 可适配不同的订阅 API。
+// 默认使用上面的 SubscriptionAPIHost 与 SubscriptionAPIKey
+var SubscriptionBackends = []SubscriptionBackendConfig{
+	{
+		Name:    "default",
+		BaseURL: "http://" + SubscriptionAPIHost,
+		Auth:    SubscriptionAuthConfig{Type: SubAuthAPIKey, Token: SubscriptionAPIKey},
+	},
+}
+
+// 订阅路由规则 (按顺序匹配，未命中时使用默认后端)
+// 示例: var SubscriptionRoutes = []SubscriptionRoute{{UserIDs: []int64{123456789}, Backend: "backup"}, {URLPattern: `\.example\.com/`, Backend: "example"}}
+var SubscriptionRoutes []SubscriptionRoute
+
 // 示例: var AllowedUsers = map[int64]bool{123456789: true, 987654321: true}
 var AllowedUsers map[int64]bool = nil
 
+// 管理员 (可在 /queue 中查看所有人的任务、置顶或取消他人的任务)
+// 示例: var AdminUsers = map[int64]bool{123456789: true}
+var AdminUsers map[int64]bool = nil
+
+// 任务队列容量，队列已满时拒绝新的 TDL 链接
+var QueueCapacity = 100
+
+// 入站限流 (令牌桶)：每个用户/群组每秒补充 Rate 个令牌，最多积累 Burst 个，管理员不受限制。
+// 在 SpamBanWindow 内累计有 SpamBanThreshold 条消息被限流的用户将被临时封禁 SpamBanDuration (0 表示不自动封禁)，可用 /unban 解除。
+// 每 InboundPruneInterval 清理一次已空闲的限流状态与到期的封禁
+var (
+	InboundUserRate  = 0.5
+	InboundUserBurst = 10
+	InboundChatRate  = 2.0
+	InboundChatBurst = 30
+	SpamBanThreshold = 30
+	SpamBanWindow    = 10 * time.Minute
+	SpamBanDuration  = 30 * time.Minute
+
+	InboundPruneInterval = 10 * time.Minute
+)
+
+// 群组设置 (键为群组 ID)：群组中 Bot 只响应命令、@提及和对 Bot 消息的回复。
+// Submitters 可选 GroupSubmitAll / GroupSubmitAdmins / GroupSubmitUsers，未配置的群组按 AllowedUsers 白名单判断
+// 示例: var GroupSettings = map[int64]GroupConfig{-1001234567890: {Submitters: GroupSubmitAdmins}}
+var GroupSettings = map[int64]GroupConfig{}
+
+// 启动时通过 setMyCommands 按角色与语言发布命令菜单
+var RegisterCommandMenus = true
+
+// 任务超时配置：超过 TaskStallTimeout 没有任何输出即判定为无进度并终止；
+// 最长执行时间可按任务类型 (forward) 和角色 (admin / user) 配置，两者都配置时取较小值，0 或未配置表示不限制
+var (
+	TaskStallTimeout      = 3 * time.Minute
+	TaskMaxDurationByKind = map[string]time.Duration{}                      // 示例: {"forward": 2 * time.Hour}
+	TaskMaxDurationByRole = map[string]time.Duration{"user": 6 * time.Hour} // 示例: {"admin": 0, "user": time.Hour}
+	LoginTimeout          = 5 * time.Minute                                 // 等待扫码登录的最长时间，对所有角色生效，0 表示不限制
+)
+
+// 维护模式默认提示 (/maintenance on 未指定提示信息时使用)，为空时使用消息目录中对应语言的默认提示
+var MaintenanceDefaultMessage = ""
+
+// 默认界面语言 (zh / en)。用户未通过 /lang 设置且 Telegram 客户端语言不受支持时使用
+var DefaultLanguage = LangZH
+
+// 任务优先级配置 (PriorityLow / PriorityNormal / PriorityHigh)
+// 管理员默认高优先级；消息末尾加 !high / !low 可调整单次任务的优先级
+var (
+	DefaultTaskPriority   = PriorityNormal       // 普通用户的默认优先级
+	UserPriorities        map[int64]TaskPriority // 按用户指定默认优先级，示例: {123456789: PriorityHigh}
+	PriorityBoostUsers    map[int64]bool         // 允许使用 !high 提升优先级的非管理员用户
+	PriorityAgingInterval = 5 * time.Minute      // 任务每等待该时长有效优先级提升一级，避免低优先级任务饿死
+)
+
 // TDL 脚本路径 (动态获取)
 var TDLScriptPath string
 
+// 订阅内容检测配置 (提交前先下载订阅并识别格式、统计节点)
+var (
+	SubValidateTimeout         = 15 * time.Second // 下载订阅的超时时间
+	SubValidateMaxBytes  int64 = 5 * 1024 * 1024  // 订阅内容大小上限
+	SubValidateUserAgent       = "clash.meta"     // 下载订阅时使用的 User-Agent
+	SubFetchMaxRedirects       = 5                // 下载订阅时最多跟随的重定向次数
+	SubFetchAllowPrivate       = false            // 允许下载内网、本机地址的订阅 (订阅服务部署在内网时开启)
+)
+
+// 订阅提交重试配置 (订阅 API 不可达时加入待提交队列)
+var (
+	SubOutboxRetryBase   = 30 * time.Second // 首次重试间隔，之后指数退避
+	SubOutboxRetryMax    = 30 * time.Minute // 最大重试间隔
+	SubOutboxMaxAttempts = 20               // 超过该重试次数后放弃并通知用户
+	SubBreakerThreshold  = 3                // 连续失败多少次后熔断
+	SubBreakerCooldown   = time.Minute      // 熔断持续时间
+)
+
+// 批量订阅配置
+var (
+	SubBatchConcurrency        = 4           // 批量检测/提交订阅的并发数
+	TextDocumentMaxBytes int64 = 1024 * 1024 // 可解析的 .txt 文档大小上限
+)
+
+// 链接处理器：默认由第一个匹配到链接的处理器处理整条消息 (内置 telegram、subscription)
+var (
+	LinkDispatchAll  = false            // true 时同一条消息中的链接分别交给各自匹配的处理器；默认只处理第一个匹配的处理器的链接，其他链接忽略
+	LinkHandlerOrder []string           // 匹配顺序，未列出的处理器按注册顺序排在最后，示例: {"telegram", "subscription"}
+	UserLinkHandlers map[int64][]string // 用户可以使用的处理器，未配置的用户可以使用全部，示例: {123456789: {"telegram"}}
+)
+
+// TDL 账号池：每个账号对应 tdl 的一个命名空间 (-n)，按策略为每个任务选择账号
+var (
+	TDLAccounts            = []string{"default"} // 首次启动时的账号，之后由管理员通过 /accounts 管理 (保存在 accounts.json)
+	AccountStrategy        = AccountRoundRobin   // AccountRoundRobin / AccountLeastFlooded / AccountPinned
+	UserAccounts           map[int64]string      // AccountPinned 策略下用户固定使用的账号，示例: {123456789: "work"}
+	AccountMaxFailures     = 3                   // 连续失败多少次后暂停使用该账号
+	AccountFailureCooldown = 10 * time.Minute    // 连续失败后的暂停时间
+	AccountAuthCooldown    = 6 * time.Hour       // 会话失效或账号被封禁后的暂停时间
+)
+
+// 会话健康检查：定期检查每个账号是否已登录、转发目标是否可以访问，所有账号都未通过时暂停启动新任务
+var (
+	SessionCheckInterval = 15 * time.Minute // 检查间隔，0 表示不检查
+	SessionCheckTimeout  = 2 * time.Minute  // 单个账号的检查超时
+)
+
+// tdl 版本管理：Bot 启动时安装固定版本并校验 SHA-256，之后只能由管理员通过 /tdl upgrade 显式升级
+var (
+	TDLManaged         = true                                       // false 时由 tdl.sh 在每次执行时自行检查更新 (旧行为)
+	TDLVersion         = "v0.18.5"                                  // 固定版本
+	TDLOffline         = false                                      // 离线模式：不访问网络，只使用 TDLTarball、本地缓存或已安装的 tdl
+	TDLTarball         = ""                                         // 预先下载的 TDLVersion 压缩包路径
+	TDLChecksums       = map[string]string{}                        // 固定的 SHA-256，键为 "版本/文件名"；离线模式和 /tdl upgrade 未在命令中提供校验值时必须配置
+	TDLChecksumsAsset  = "tdl_checksums.txt"                        // 发布中的校验文件名
+	TDLDownloadMirrors = []string{"", "https://gh-acc.p3terx.com/"} // 下载地址前缀，"" 表示直接访问 GitHub
+	TDLDownloadTimeout = 5 * time.Minute                            // 单个文件的下载超时
+)
+
+// 生命周期钩子：在 pre_enqueue / pre_run / post_success / post_failure 阶段执行外部命令或 HTTP 调用。
+// 示例: {{Stage: HookPostSuccess, Command: []string{"/opt/hooks/index.sh"}}, {Stage: HookPreEnqueue, URL: "http://127.0.0.1:8080/check"}}
+var (
+	LifecycleHooks     []HookConfig
+	HookDefaultTimeout = 10 * time.Second // 未设置 Timeout 的钩子的超时时间
+)
+
+// 任务通知：编辑状态消息不会触发 Telegram 提醒，任务事件可另行发送到以下渠道。
+// 事件: queued / started / login_required / completed / failed / cancelled
+var (
+	NotifyDefaultEvents  = []string{EventLoginRequired, EventCompleted, EventFailed} // 用户通过 /notify 开启通知但未选择事件时使用
+	NotifyAdminChatID    int64                                                       // 接收事件的管理员聊天 (用户或群组 ID)，0 表示不发送
+	NotifyAdminEvents    = []string{EventLoginRequired, EventFailed}                 // 发送到管理员聊天的事件
+	NotifyWebhooks       []WebhookConfig                                             // 示例: {{URL: "https://example.com/hook", Secret: "s3cret", Events: []string{EventCompleted}}}
+	NotifyWebhookTimeout = 10 * time.Second
+)
+
+// 邮件通知 (用户通过 /notify email 设置地址)，SMTPHost 为空时不发送邮件
+var (
+	SMTPHost     = ""
+	SMTPPort     = 587
+	SMTPUsername = ""
+	SMTPPassword = ""
+	SMTPFrom     = ""
+)
+
+// 转发目标 (传给 tdl forward --to 的聊天 ID 或用户名)
+var ForwardTarget = "1838605845"
+
+// 跨时间去重：去重时间窗口内已转发到同一目标的源消息不会再次转发，用户可点击"仍然转发"
+var (
+	ForwardDedupWindow = 30 * 24 * time.Hour   // 默认去重时间窗口，0 表示不去重
+	UserDedupWindows   map[int64]time.Duration // 按用户指定去重时间窗口，示例: {123456789: 7 * 24 * time.Hour}
+)
+
+// 转发前预览：频道等多条消息的来源先通过 tdl 导出消息列表，回复消息数、媒体类型与总大小，确认后才加入队列
+var (
+	ForwardPreviewEnabled           = true
+	ForwardPreviewMinMessages       = 2                  // 消息数达到该值时需要确认；单条消息的链接在该值大于 1 时不预览
+	ForwardPreviewMinBytes    int64 = 1024 * 1024 * 1024 // 总大小达到该值时需要确认，0 表示只按消息数判断
+	ForwardPreviewTimeout           = 2 * time.Minute    // 检查来源的超时时间
+	ForwardPreviewMaxMessages       = 1000               // 最多导出最近的多少条消息，达到时按 "至少" 显示，0 表示导出全部
+)
+
+// 转发相册时等待同一相册其余消息的时间，超时后合并为一个任务
+var AlbumCollectDelay = 2 * time.Second
+
+// Bot 数据目录 (保存停机时的待处理任务等)，为空时使用 TDL 脚本所在目录下的 .bot
+var BotDataDir string
+
+// 停机配置
+var (
+	ShutdownGracePeriod = 30 * time.Second // 收到停止信号后等待当前任务的最长时间
+	ShutdownWaitCurrent = true             // true: 宽限期内等待当前任务完成; false: 立即中断并保存当前任务
+)
+
+// 日志配置
+var (
+	LogFormat         = "text" // 日志格式: text 或 json
+	LogLevel          = "info" // 日志级别: debug, info, warn, error
+	LogFile           = ""     // 日志文件路径，为空则只输出到标准输出
+	LogFileMaxSizeMB  = 20     // 单个日志文件最大体积 (MB)，超过后轮转
+	LogFileMaxBackups = 5      // 保留的轮转日志文件数量
+)
+
 // ==================== 配置区域结束 ====================
 
 // Task 表示一个正在运行的任务
 type Task struct {
-	ID      int
-	UserID  int64
-	Cmd     *exec.Cmd
-	Cancel  context.CancelFunc
-	Message *tgbotapi.Message
-	PGID    int
+	ID          int
+	UserID      int64
+	Cmd         *exec.Cmd
+	Cancel      context.CancelFunc
+	Message     *tgbotapi.Message
+	PGID        int
+	Source      *QueuedTask // 对应的队列任务
+	Account     string      // 执行任务的 tdl 账号
+	Interrupted bool        // 是否因服务停机被中断（中断后需重新排队而不是标记为失败）
 }
 
 // QueuedTask 表示队列中的任务
 type QueuedTask struct {
 	Link        string
+	Album       []string // 转发相册时相册中全部消息的链接 (第一条即 Link)，合并为一次转发
 	Message     *tgbotapi.Message
 	UserID      int64
 	StatusMsg   *tgbotapi.Message // 状态消息
@@ -63,6 +258,11 @@ type QueuedTask struct {
 	CancelMutex sync.Mutex        // 取消操作的互斥锁
 	Index       int               // 如果是汇总消息, 该任务在汇总消息中的行索引
 	Shared      bool              // 是否共享汇总消息
+	Priority    TaskPriority      // 任务优先级
+	Lang        string            // 任务所有者的界面语言
+	EnqueuedAt  time.Time         // 入队时间，用于优先级老化
+	PinnedAt    time.Time         // 被管理员置顶的时间，零值表示未置顶
+	seq         uint64            // 入队序号，同优先级按入队顺序执行
 }
 
 // TaskManager 管理所有活跃的任务和队列
@@ -70,7 +270,9 @@ type TaskManager struct {
 	mu              sync.RWMutex
 	tasks           map[int64]map[int]*Task       // user_id -> task_id -> task
 	counters        map[int64]int                 // user_id -> counter
-	queue           chan *QueuedTask              // 任务队列
+	pending         []*QueuedTask                 // 等待执行的任务，按执行顺序排列
+	queueSignal     chan struct{}                 // 有新任务入队时通知队列处理器
+	queueSeq        uint64                        // 入队序号计数器
 	queuedTasks     map[int64]map[int]*QueuedTask // user_id -> task_id -> queued task (用于取消队列中的任务)
 	currentTask     *Task                         // 当前正在执行的任务
 	queueProcessing bool                          // 队列是否正在处理
@@ -86,7 +288,7 @@ func NewTaskManager() *TaskManager {
 	return &TaskManager{
 		tasks:                make(map[int64]map[int]*Task),
 		counters:             make(map[int64]int),
-		queue:                make(chan *QueuedTask, 100), // 缓冲队列，最多100个任务
+		queueSignal:          make(chan struct{}, 1),
 		queuedTasks:          make(map[int64]map[int]*QueuedTask),
 		currentTask:          nil,
 		queueProcessing:      false,
@@ -144,7 +346,9 @@ func (tm *TaskManager) CountUserTasks(userID int64) int {
 
 // GetQueueSize 获取队列大小
 func (tm *TaskManager) GetQueueSize() int {
-	return len(tm.queue)
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return len(tm.pending)
 }
 
 // GetCurrentTask 获取当前正在执行的任务
@@ -168,9 +372,15 @@ func (tm *TaskManager) EnqueueTask(task *QueuedTask) {
 		tm.queuedTasks[task.UserID] = make(map[int]*QueuedTask)
 	}
 	tm.queuedTasks[task.UserID][task.TaskID] = task
+	tm.queueSeq++
+	task.seq = tm.queueSeq
+	if task.EnqueuedAt.IsZero() {
+		task.EnqueuedAt = time.Now()
+	}
+	tm.pending = append(tm.pending, task)
 	tm.mu.Unlock()
 
-	tm.queue <- task
+	tm.notifyQueue()
 }
 
 // RemoveQueuedTask 从队列任务映射中移除
@@ -236,6 +446,40 @@ func (tm *TaskManager) DecrementSummaryPending(chatID int64, messageID int) int
 	return remaining
 }
 
+// SetSummaryPending 直接设置汇总消息的待完成计数（用于恢复停机前保存的汇总）
+func (tm *TaskManager) SetSummaryPending(chatID int64, messageID int, count int) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.summaryPendingCounts[chatID] == nil {
+		tm.summaryPendingCounts[chatID] = make(map[int]int)
+	}
+	tm.summaryPendingCounts[chatID][messageID] = count
+}
+
+// RemoveSummary 删除汇总消息的全部缓存（行、键盘与待完成计数）
+func (tm *TaskManager) RemoveSummary(chatID int64, messageID int) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if m, ok := tm.summaryLines[chatID]; ok {
+		delete(m, messageID)
+		if len(m) == 0 {
+			delete(tm.summaryLines, chatID)
+		}
+	}
+	if m, ok := tm.summaryKeyboards[chatID]; ok {
+		delete(m, messageID)
+		if len(m) == 0 {
+			delete(tm.summaryKeyboards, chatID)
+		}
+	}
+	if m, ok := tm.summaryPendingCounts[chatID]; ok {
+		delete(m, messageID)
+		if len(m) == 0 {
+			delete(tm.summaryPendingCounts, chatID)
+		}
+	}
+}
+
 // GetSummaryLines 返回缓存的汇总行（只读）
 func (tm *TaskManager) GetSummaryLines(chatID int64, messageID int) ([]string, bool) {
 	tm.mu.RLock()
@@ -302,6 +546,7 @@ func (tm *TaskManager) CancelQueuedTask(userID int64, taskID int) bool {
 			if len(tasks) == 0 {
 				delete(tm.queuedTasks, userID)
 			}
+			tm.removePendingLocked(userID, taskID)
 			found = true
 		}
 	}
@@ -323,25 +568,7 @@ func (tm *TaskManager) CancelTask(userID int64, taskID int) bool {
 
 	if tasks, exists := tm.tasks[userID]; exists {
 		if task, ok := tasks[taskID]; ok {
-			if task.Cancel != nil {
-				task.Cancel()
-			}
-			// 尝试先终止整个进程组（类 Unix 系统），以确保子进程也被清理
-			if task.Cmd != nil && task.Cmd.Process != nil {
-				p := task.Cmd.Process
-				// 如果我们事先记录了 PGID，优先使用它来终止整组进程。
-				if task.PGID != 0 {
-					// 先尝试温和终止，再强制结束
-					_ = syscall.Kill(-task.PGID, syscall.SIGTERM)
-					time.Sleep(500 * time.Millisecond)
-					_ = syscall.Kill(-task.PGID, syscall.SIGKILL)
-				} else {
-					// 先尝试通过进程组清理（在 Unix 上实现），否则回退到直接 Kill
-					if err := killProcessGroup(p.Pid); err != nil {
-						_ = p.Kill()
-					}
-				}
-			}
+			terminateTask(task)
 			delete(tasks, taskID)
 			if len(tasks) == 0 {
 				delete(tm.tasks, userID)
@@ -352,26 +579,81 @@ func (tm *TaskManager) CancelTask(userID int64, taskID int) bool {
 	return false
 }
 
-// SubscriptionRequest 订阅请求结构
-type SubscriptionRequest struct {
-	SubURL string `json:"sub_url"`
+// InterruptCurrentTask 因停机中断当前任务：任务保留在管理器中并标记为已中断，
+// 以便执行方识别为"暂停"而非用户终止。返回被中断任务对应的队列任务。
+func (tm *TaskManager) InterruptCurrentTask() *QueuedTask {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	task := tm.currentTask
+	if task == nil {
+		return nil
+	}
+	task.Interrupted = true
+	terminateTask(task)
+	return task.Source
 }
 
-// SubscriptionResponse 订阅响应结构
-type SubscriptionResponse struct {
-	Message string `json:"message,omitempty"`
-	Error   string `json:"error,omitempty"`
+// terminateTask 取消任务上下文并终止其进程组
+func terminateTask(task *Task) {
+	if task.Cancel != nil {
+		task.Cancel()
+	}
+	// 尝试先终止整个进程组（类 Unix 系统），以确保子进程也被清理
+	if task.Cmd != nil && task.Cmd.Process != nil {
+		p := task.Cmd.Process
+		// 如果我们事先记录了 PGID，优先使用它来终止整组进程。
+		if task.PGID != 0 {
+			// 先尝试温和终止，再强制结束
+			_ = syscall.Kill(-task.PGID, syscall.SIGTERM)
+			time.Sleep(500 * time.Millisecond)
+			_ = syscall.Kill(-task.PGID, syscall.SIGKILL)
+		} else {
+			// 先尝试通过进程组清理（在 Unix 上实现），否则回退到直接 Kill
+			if err := killProcessGroup(p.Pid); err != nil {
+				_ = p.Kill()
+			}
+		}
+	}
 }
 
 // Bot 主结构
 type Bot struct {
-	api         *tgbotapi.BotAPI
-	taskManager *TaskManager
-	logger      *log.Logger
+	api            *tgbotapi.BotAPI
+	taskManager    *TaskManager
+	logger         *slog.Logger
+	subRouter      *subscriptionRouter
+	linkRouter     *linkRouter
+	subStore       *subscriptionStore
+	subPreviews    *subPreviewStore
+	subOutbox      *subscriptionOutbox
+	control        *botControl
+	userLangs      *userLangStore
+	notifySettings *notifySettingsStore
+
+	commands   *commandRouter
+	inbound    *inboundLimiter
+	chatAdmins *chatAdminCache
+	topics     *topicIndex
+	albums     *albumCollector
+
+	history         *forwardHistory
+	accounts        *accountPool
+	health          *sessionMonitor
+	tdl             *tdlTool
+	pendingForwards *pendingForwardStore
+	// tdl 的会话数据不能被多个进程同时打开：执行任务、预览来源、会话检查与切换版本时都持有 tdlMu
+	tdlMu sync.Mutex
+
+	shuttingDown atomic.Bool   // 停机中，不再接受新链接
+	stopping     chan struct{} // 停机开始时关闭，通知后台任务退出
+	stopQueue    chan struct{} // 关闭后队列处理器在当前任务结束后退出
+	queueDone    chan struct{} // 队列处理器退出后关闭
+	unstarted    *QueuedTask   // 停机时已出队但未启动的任务
 }
 
 // NewBot 创建新的 Bot 实例
-func NewBot(token string) (*Bot, error) {
+func NewBot(token string, logger *slog.Logger) (*Bot, error) {
 	api, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, fmt.Errorf("创建 Bot API 失败: %w", err)
@@ -379,11 +661,44 @@ func NewBot(token string) (*Bot, error) {
 
 	api.Debug = false
 
-	return &Bot{
-		api:         api,
-		taskManager: NewTaskManager(),
-		logger:      log.New(os.Stdout, "[BOT] ", log.LstdFlags|log.Lshortfile),
-	}, nil
+	subRouter, err := newSubscriptionRouter(SubscriptionBackends, SubscriptionRoutes)
+	if err != nil {
+		return nil, fmt.Errorf("初始化订阅后端失败: %w", err)
+	}
+	linkRouter, err := newLinkRouter(linkHandlers, LinkHandlerOrder, UserLinkHandlers)
+	if err != nil {
+		return nil, fmt.Errorf("初始化链接处理器失败: %w", err)
+	}
+
+	b := &Bot{
+		api:            api,
+		taskManager:    NewTaskManager(),
+		logger:         logger,
+		subRouter:      subRouter,
+		linkRouter:     linkRouter,
+		subStore:       newSubscriptionStore(),
+		subPreviews:    newSubPreviewStore(),
+		subOutbox:      newSubscriptionOutbox(),
+		control:        newBotControl(),
+		userLangs:      newUserLangStore(),
+		notifySettings: newNotifySettingsStore(),
+		stopping:       make(chan struct{}),
+		stopQueue:      make(chan struct{}),
+		queueDone:      make(chan struct{}),
+
+		inbound:    newInboundLimiter(),
+		chatAdmins: newChatAdminCache(),
+		topics:     newTopicIndex(),
+		albums:     newAlbumCollector(),
+
+		history:         newForwardHistory(),
+		accounts:        newAccountPool(),
+		health:          newSessionMonitor(),
+		tdl:             newTDLTool(),
+		pendingForwards: newPendingForwardStore(),
+	}
+	b.commands = b.newCommandRouter()
+	return b, nil
 }
 
 // checkUserPermission 检查用户权限
@@ -394,367 +709,350 @@ func checkUserPermission(userID int64) bool {
 	return AllowedUsers[userID]
 }
 
-// handleStart 处理 /start 命令
-func (b *Bot) handleStart(message *tgbotapi.Message) {
+// requirePermission 检查消息发送者权限，无权限时回复提示并返回 false
+func (b *Bot) requirePermission(message *tgbotapi.Message) bool {
 	user := message.From
-	b.logger.Printf("用户 %d (%s) 发送了 /start 命令", user.ID, user.UserName)
-
-	welcomeText := fmt.Sprintf(
-		"👋 你好 %s!\n\n"+
-			"🤖 这是一个多功能机器人\n\n"+
-			"📋 支持功能:\n"+
-			"• TDL 转发 - 发送 Telegram 链接 (https://t.me/xxx)\n"+
-			"• 订阅管理 - 发送订阅链接 (http/https 格式)\n\n"+
-			"💡 直接发送链接即可，Bot 会自动识别类型",
-		user.FirstName,
-	)
+	if checkUserPermission(user.ID) {
+		return true
+	}
+	b.logger.Warn("未授权用户尝试使用 Bot", "user_id", user.ID, "username", user.UserName)
+	b.replyText(message, T(b.userLang(user), "common.no_permission"))
+	return false
+}
 
-	msg := tgbotapi.NewMessage(message.Chat.ID, welcomeText)
+// replyText 以回复形式发送一条纯文本消息
+func (b *Bot) replyText(message *tgbotapi.Message, text string) {
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
 	msg.ReplyToMessageID = message.MessageID
-	b.api.Send(msg)
+	if _, err := b.send(msg); err != nil {
+		b.logger.Warn("发送消息失败", "chat_id", message.Chat.ID, "error", err)
+	}
+}
+
+// handleStart 处理 /start 命令
+func (b *Bot) handleStart(c *commandContext) {
+	user := c.Message.From
+	b.logger.Info("收到 /start 命令", "user_id", user.ID, "username", user.UserName)
+
+	welcomeText := T(b.userLang(user), "start.welcome", "name", user.FirstName)
+
+	msg := tgbotapi.NewMessage(c.Message.Chat.ID, welcomeText)
+	msg.ReplyToMessageID = c.Message.MessageID
+	b.send(msg)
 }
 
 // handleHelp 处理 /help 命令
-func (b *Bot) handleHelp(message *tgbotapi.Message) {
-	helpText := "📖 使用帮助\n\n" +
-		"1️⃣ 发送 Telegram 链接进行转发\n" +
-		"   格式: https://t.me/channel/123\n\n" +
-		"2️⃣ 发送订阅链接进行添加\n" +
-		"   格式: 任意 http/https 链接 (非 t.me)\n\n" +
-		"3️⃣ 支持的命令:\n" +
-		"   /start - 开始使用\n" +
-		"   /help - 查看帮助\n" +
-		"   /status - 检查状态\n\n" +
-		"❓ 遇到问题请联系管理员"
-
-	msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
-	msg.ReplyToMessageID = message.MessageID
-	b.api.Send(msg)
+func (b *Bot) handleHelp(c *commandContext) {
+	lang := c.Lang
+	helpText := T(lang, "help.text", "commands", b.helpCommands(c.Message.From.ID, lang))
+
+	msg := tgbotapi.NewMessage(c.Message.Chat.ID, helpText)
+	msg.ReplyToMessageID = c.Message.MessageID
+	b.send(msg)
 }
 
 // handleStatus 处理 /status 命令
-func (b *Bot) handleStatus(message *tgbotapi.Message) {
-	userID := message.From.ID
+func (b *Bot) handleStatus(c *commandContext) {
+	userID := c.Message.From.ID
+	lang := c.Lang
 
 	// 检查 TDL 脚本是否存在
-	scriptExists := "❌ 未找到"
+	scriptExists := T(lang, "status.script_missing")
 	if _, err := os.Stat(TDLScriptPath); err == nil {
-		scriptExists = "✅ 存在"
+		scriptExists = T(lang, "status.script_found")
 	}
 
 	// 获取队列状态
 	queueSize := b.taskManager.GetQueueSize()
 	currentTask := b.taskManager.GetCurrentTask()
-	isProcessing := "空闲"
+	isProcessing := T(lang, "status.idle")
 	var processingInfo string
 
 	if currentTask != nil {
-		isProcessing = "处理中"
-		processingInfo = fmt.Sprintf("\n⚡ 正在处理: 任务 #%d (用户 %d)", currentTask.ID, currentTask.UserID)
-	}
-
-	statusText := fmt.Sprintf(
-		"✅ Bot 运行正常\n"+
-			"📁 TDL 脚本: %s (%s)\n"+
-			"🌐 订阅 API: %s\n"+
-			"👤 当前用户: %d\n"+
-			"📊 队列模式: 排队执行 (一次一个)\n"+
-			"🔄 当前状态: %s\n"+
-			"📋 等待队列: %d 个任务%s",
-		TDLScriptPath, scriptExists,
-		SubscriptionAPIHost,
-		userID,
-		isProcessing,
-		queueSize,
-		processingInfo,
+		isProcessing = T(lang, "status.busy")
+		processingInfo = T(lang, "status.processing", "id", currentTask.ID, "user", currentTask.UserID)
+	}
+
+	statusText := T(lang, "status.text",
+		"script", TDLScriptPath,
+		"script_state", scriptExists,
+		"backends", b.subscriptionBackendsStatus(lang),
+		"outbox", b.subOutbox.Len(),
+		"user", userID,
+		"control", b.controlStatusText(lang),
+		"strategy", AccountStrategy,
+		"accounts", b.accounts.Status(lang),
+		"health", b.health.Status(lang),
+		"tdl", b.tdlStatusText(lang),
+		"state", isProcessing,
+		"queue", queueSize,
+		"processing", processingInfo,
 	)
 
-	msg := tgbotapi.NewMessage(message.Chat.ID, statusText)
-	msg.ReplyToMessageID = message.MessageID
-	b.api.Send(msg)
+	msg := tgbotapi.NewMessage(c.Message.Chat.ID, statusText)
+	msg.ReplyToMessageID = c.Message.MessageID
+	b.send(msg)
 }
 
-// addSubscription 添加订阅到 API
-func (b *Bot) addSubscription(subURL string) (bool, string) {
-	apiURL := fmt.Sprintf("http://%s/api/config/add", SubscriptionAPIHost)
-
-	reqBody := SubscriptionRequest{SubURL: subURL}
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		b.logger.Printf("JSON 序列化失败: %v", err)
-		return false, fmt.Sprintf("❌ 请求失败: %v", err)
-	}
+// handleMessage 处理用户消息
+func (b *Bot) handleMessage(message *tgbotapi.Message) {
+	user := message.From
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		b.logger.Printf("创建请求失败: %v", err)
-		return false, fmt.Sprintf("❌ 请求失败: %v", err)
+	// 权限检查
+	if !b.requireSubmitPermission(message) {
+		return
 	}
 
-	req.Header.Set("X-API-Key", SubscriptionAPIKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	b.logger.Printf("发送订阅请求到 %s", apiURL)
+	lang := b.userLang(user)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		b.logger.Printf("订阅 API 请求失败: %v", err)
-		if os.IsTimeout(err) {
-			return false, "❌ 请求超时，请稍后重试"
-		}
-		return false, "❌ 无法连接到服务器"
+	// 停机过程中不再接受新任务
+	if b.shuttingDown.Load() {
+		msg := tgbotapi.NewMessage(message.Chat.ID, T(lang, "msg.restarting"))
+		msg.ReplyToMessageID = message.MessageID
+		b.send(msg)
+		return
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		b.logger.Printf("读取响应失败: %v", err)
-		return false, "❌ 读取响应失败"
+	// 维护模式下只有管理员可以提交新任务
+	if notice, on := b.control.MaintenanceNotice(lang); on && !isAdmin(user.ID) {
+		b.replyText(message, notice)
+		return
 	}
 
-	var response SubscriptionResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		b.logger.Printf("解析响应失败: %v", err)
-		return false, fmt.Sprintf("❌ 订阅添加失败 (状态码: %d)", resp.StatusCode)
+	// 从频道或超级群组转发的消息直接转发原消息 (包括只有媒体没有文字的消息)
+	if link, ok := forwardSourceLink(message); ok {
+		b.handleForwardedMessage(message, link)
+		return
 	}
 
-	if resp.StatusCode == 200 {
-		successMsg := response.Message
-		if successMsg == "" {
-			successMsg = "订阅添加成功"
-		}
-		b.logger.Printf("订阅添加成功: %s - %s", subURL, successMsg)
-		return true, fmt.Sprintf("✅ %s", successMsg)
+	text := message.Text
+	if text == "" {
+		text = message.Caption
 	}
+	b.logger.Info("收到消息", "user_id", user.ID, "chat_id", message.Chat.ID, "text", truncateString(text, 100))
 
-	errorMsg := response.Error
-	if errorMsg == "" {
-		errorMsg = response.Message
-	}
-	if errorMsg == "" {
-		errorMsg = fmt.Sprintf("订阅添加失败 (状态码: %d)", resp.StatusCode)
+	// 随消息发送的文本文档需要先下载，异步处理避免阻塞消息处理
+	if isTextDocument(message.Document) {
+		go b.handleMessageLinks(message)
+		return
 	}
+	b.handleMessageLinks(message)
+}
 
-	b.logger.Printf("订阅添加失败: %s", errorMsg)
+// handleMessageLinks 从正文、说明、隐藏链接和文本文档中提取全部链接并交给链接处理器，没有链接时提示用户
+func (b *Bot) handleMessageLinks(message *tgbotapi.Message) {
+	user := message.From
+	lang := b.userLang(user)
 
-	// 特殊处理重复订阅
-	if strings.Contains(errorMsg, "已存在") || strings.Contains(strings.ToLower(errorMsg), "already exists") {
-		return false, fmt.Sprintf("⚠️ %s", errorMsg)
+	links, err := b.extractMessageLinks(message)
+	if err != nil {
+		// 下载错误中包含带 Bot Token 的文件地址，只写入 (脱敏后的) 日志，不回复给用户
+		b.logger.Warn("读取文本文档失败", "user_id", user.ID, "file", message.Document.FileName, "error", err)
+		if errors.Is(err, errTextDocumentTooLarge) {
+			b.replyText(message, T(lang, "file.too_large", "size", TextDocumentMaxBytes/1024))
+			return
+		}
+		b.replyText(message, T(lang, "file.download_failed"))
+		return
 	}
-	return false, fmt.Sprintf("❌ %s", errorMsg)
-}
 
-// handleMessage 处理用户消息
-func (b *Bot) handleMessage(message *tgbotapi.Message) {
-	user := message.From
+	// 按顺序交给各链接处理器 (t.me 链接转发、其他 http/https 链接提交订阅等)
+	if len(links) > 0 {
+		b.dispatchLinks(message, links)
+		return
+	}
 
-	// 权限检查
-	if !checkUserPermission(user.ID) {
-		b.logger.Printf("未授权用户 %d (%s) 尝试使用 Bot", user.ID, user.UserName)
-		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ 您没有权限使用此 Bot")
-		msg.ReplyToMessageID = message.MessageID
-		b.api.Send(msg)
+	// 转发的消息无法定位来源 (来源已隐藏或来自私聊/普通群组)
+	if isForwarded(message) {
+		b.logger.Debug("转发消息没有可用的来源", "user_id", user.ID)
+		b.replyText(message, T(lang, "forward.no_source"))
 		return
 	}
 
-	text := message.Text
-	b.logger.Printf("收到来自用户 %d 的消息: %s", user.ID, truncateString(text, 100))
-
-	// 优先检查是否包含一个或多个 Telegram 链接 (支持多行或空格分隔)
-	// 正则支持带或不带协议的 t.me 链接
-	reTMe := regexp.MustCompile(`(?i)(?:https?://)?t\.me/[^\s]+`)
-	matches := reTMe.FindAllString(text, -1)
-	if len(matches) > 0 {
-		b.logger.Printf("检测到 %d 个 Telegram 链接", len(matches))
-
-		// 如果包含多条链接, 使用单条汇总消息展示并在内部按行更新
-		if len(matches) > 1 {
-			// 去重链接，保持原有顺序
-			var links []string
-			seen := make(map[string]bool)
-			for _, raw := range matches {
-				link := strings.TrimSpace(raw)
-				if !strings.HasPrefix(strings.ToLower(link), "http") {
-					link = "https://" + link
-				}
-				// 规范化用于去重（小写，去尾斜杠）
-				norm := strings.ToLower(strings.TrimSuffix(link, "/"))
-				if seen[norm] {
-					continue
-				}
-				seen[norm] = true
-				links = append(links, link)
-			}
+	// 无效的消息（不包含任何链接）
+	b.logger.Debug("收到无效消息", "user_id", user.ID)
+	warningMsg := tgbotapi.NewMessage(message.Chat.ID, T(lang, "msg.invalid", "handlers", b.linkRouter.Describe(user.ID, lang)))
+	warningMsg.ReplyToMessageID = message.MessageID
+	sentWarning, err := b.send(warningMsg)
+	if err != nil || isGroupChat(message.Chat) {
+		// 群组中不删除他人的消息
+		return
+	}
 
-			// 为每个链接生成独立 taskID
-			taskIDs := make([]int, len(links))
-			b.taskManager.mu.Lock()
-			for i := range links {
-				b.taskManager.counters[user.ID]++
-				taskIDs[i] = b.taskManager.counters[user.ID]
-			}
-			b.taskManager.mu.Unlock()
-
-			// 构造初始原始行（包含链接与排队信息），并为每个链接预创建 QueuedTask（尚未设置 StatusMsg）
-			baseQueue := b.taskManager.GetQueueSize()
-			rawLines := make([]string, len(links))
-			queuedTasks := make([]*QueuedTask, len(links))
-			for i, link := range links {
-				queuePos := baseQueue + i + 1
-				line := fmt.Sprintf("⏳ 任务 #%d - 已加入队列\n%s", taskIDs[i], link)
-				if queuePos > 1 {
-					line += fmt.Sprintf("\n📋 当前排队位置: 第 %d 位", queuePos)
-				} else {
-					line += "\n⚡ 即将开始处理"
-				}
-				rawLines[i] = line
-
-				queuedTasks[i] = &QueuedTask{
-					Link:      link,
-					Message:   message,
-					UserID:    user.ID,
-					StatusMsg: nil, // will set after sending
-					TaskID:    taskIDs[i],
-					Cancelled: false,
-					Index:     i,
-					Shared:    true,
-				}
-			}
+	// 异步删除提示和原消息(不阻塞主线程)
+	go func() {
+		time.Sleep(5 * time.Second)
+		deleteMsg := tgbotapi.NewDeleteMessage(message.Chat.ID, sentWarning.MessageID)
+		b.api.Send(deleteMsg)
+		deleteOriginal := tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)
+		b.api.Send(deleteOriginal)
+	}()
 
-			// 生成已格式化的展示行（单行样式）用于首次发送和缓存
-			formatted := make([]string, len(links))
-			for i := range links {
-				formatted[i] = b.formatSummaryLine(queuedTasks[i], rawLines[i])
-			}
+}
 
-			// 单个按钮用于终止整个汇总消息下的所有任务
-			cancelCallback := fmt.Sprintf("cancel_summary_%d", user.ID)
-			btn := tgbotapi.NewInlineKeyboardButtonData("🛑 终止全部任务", cancelCallback)
-			markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(btn))
-			summaryText := strings.Join(formatted, "\n\n")
-			msg := tgbotapi.NewMessage(message.Chat.ID, summaryText)
-			msg.ReplyToMessageID = message.MessageID
-			msg.ReplyMarkup = markup
-			sentMsg, err := b.api.Send(msg)
-			if err != nil {
-				b.logger.Printf("发送汇总消息失败: %v", err)
-				return
-			}
+// enqueueLinks 执行 pre_enqueue 钩子后将链接加入队列。配置了钩子时在后台执行，不阻塞更新循环
+func (b *Bot) enqueueLinks(message *tgbotapi.Message, links []string, priority TaskPriority) {
+	if len(links) == 0 {
+		return
+	}
+	if len(hooksFor(HookPreEnqueue)) == 0 {
+		b.addLinks(message, links, priority)
+		return
+	}
+	go b.hookLinks(message, links, priority)
+}
 
-			// 保存汇总行缓存为已格式化的单行样式
-			b.taskManager.InitSummary(message.Chat.ID, sentMsg.MessageID, formatted, &markup)
+// enqueueAlbum 执行 pre_enqueue 钩子后将相册作为一个任务加入队列，钩子同样在后台执行
+func (b *Bot) enqueueAlbum(message *tgbotapi.Message, album []string, priority TaskPriority) {
+	if len(hooksFor(HookPreEnqueue)) == 0 {
+		b.enqueueLinkTask(message, album[0], album, priority)
+		return
+	}
+	go b.hookAlbum(message, album, priority)
+}
 
-			// 将每个任务加入队列，设置 StatusMsg 指向同一条状态消息
-			for i := range queuedTasks {
-				queuedTasks[i].StatusMsg = &sentMsg
-				b.taskManager.EnqueueTask(queuedTasks[i])
-			}
+// addLinks 不执行钩子，直接将链接加入队列：单条链接使用独立状态消息，多条链接使用一条汇总消息
+func (b *Bot) addLinks(message *tgbotapi.Message, links []string, priority TaskPriority) {
+	switch len(links) {
+	case 0:
+		return
+	case 1:
+		b.enqueueLinkTask(message, links[0], nil, priority)
+	default:
+		b.enqueueLinkBatch(message, links, priority)
+	}
+}
 
-			return
-		}
+// enqueueLinkBatch 为多条链接各创建一个任务，使用单条汇总消息展示并在内部按行更新
+func (b *Bot) enqueueLinkBatch(message *tgbotapi.Message, links []string, priority TaskPriority) {
+	user := message.From
+	lang := b.userLang(user)
 
-		// 仅一条链接，按单条任务处理
-		raw := matches[0]
-		link := raw
-		if !strings.HasPrefix(strings.ToLower(link), "http") {
-			link = "https://" + link
-		}
+	if b.taskManager.GetQueueSize()+len(links) > QueueCapacity {
+		b.replyText(message, T(lang, "queue.full"))
+		return
+	}
 
-		// 为该链接生成 taskID
-		b.taskManager.mu.Lock()
+	// 为每个链接生成独立 taskID
+	taskIDs := make([]int, len(links))
+	b.taskManager.mu.Lock()
+	for i := range links {
 		b.taskManager.counters[user.ID]++
-		taskID := b.taskManager.counters[user.ID]
-		b.taskManager.mu.Unlock()
-
-		// 获取队列位置
-		queuePosition := b.taskManager.GetQueueSize() + 1
+		taskIDs[i] = b.taskManager.counters[user.ID]
+	}
+	b.taskManager.mu.Unlock()
 
-		// 创建终止按钮（单条任务）
-		keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("🛑 终止任务", fmt.Sprintf("cancel_%d_%d", user.ID, taskID)),
-			),
-		)
+	// 构造初始原始行（包含链接与排队信息），并为每个链接预创建 QueuedTask（尚未设置 StatusMsg）
+	baseQueue := b.taskManager.PositionForNew(priority) - 1
+	rawLines := make([]string, len(links))
+	queuedTasks := make([]*QueuedTask, len(links))
+	for i, link := range links {
+		queuePos := baseQueue + i + 1
+		line := T(lang, "task.queued", "id", taskIDs[i]) + "\n" + link + "\n" + b.queueStatusText(lang, queuePos, priority)
+		rawLines[i] = line
 
-		// 创建 queuedTask 以便 formatLine 能访问链接与 taskID
-		queuedTask := &QueuedTask{
+		queuedTasks[i] = &QueuedTask{
 			Link:      link,
 			Message:   message,
 			UserID:    user.ID,
-			StatusMsg: nil,
-			TaskID:    taskID,
+			StatusMsg: nil, // will set after sending
+			TaskID:    taskIDs[i],
 			Cancelled: false,
-			Shared:    false,
+			Index:     i,
+			Shared:    true,
+			Priority:  priority,
+			Lang:      lang,
 		}
+	}
 
-		// 构造单行初始状态（与汇总样式一致）
-		var statusText string
-		if queuePosition > 1 {
-			statusText = fmt.Sprintf("📋 当前排队位置: 第 %d 位", queuePosition)
-		} else {
-			statusText = "⚡ 即将开始处理"
-		}
-		text := b.formatLine(queuedTask, statusText, false)
-
-		statusMsg := tgbotapi.NewMessage(message.Chat.ID, text)
-		statusMsg.ReplyToMessageID = message.MessageID
-		statusMsg.ReplyMarkup = keyboard
-		sentMsg, err := b.api.Send(statusMsg)
-		if err != nil {
-			b.logger.Printf("发送消息失败: %v", err)
-			return
-		}
+	// 生成已格式化的展示行（单行样式）用于首次发送和缓存
+	formatted := make([]string, len(links))
+	for i := range links {
+		formatted[i] = b.formatSummaryLine(queuedTasks[i], rawLines[i])
+	}
 
-		queuedTask.StatusMsg = &sentMsg
-		b.taskManager.EnqueueTask(queuedTask)
+	// 单个按钮用于终止整个汇总消息下的所有任务
+	cancelCallback := fmt.Sprintf("cancel_summary_%d", user.ID)
+	btn := tgbotapi.NewInlineKeyboardButtonData(T(lang, "btn.cancel_all"), cancelCallback)
+	markup := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(btn))
+	summaryText := strings.Join(formatted, "\n\n")
+	msg := tgbotapi.NewMessage(message.Chat.ID, summaryText)
+	msg.ReplyToMessageID = message.MessageID
+	msg.ReplyMarkup = markup
+	sentMsg, err := b.send(msg)
+	if err != nil {
+		b.logger.Error("发送汇总消息失败", "chat_id", message.Chat.ID, "error", err)
 		return
 	}
 
-	// 如果没有 t.me 链接，检查是否是其他订阅链接 (http/https 但不是 t.me)
-	re := regexp.MustCompile(`https?://[^\s]+`)
-	if match := re.FindString(text); match != "" && !strings.Contains(match, "t.me") {
-		b.logger.Printf("检测到订阅链接: %s", match)
+	// 保存汇总行缓存为已格式化的单行样式
+	b.taskManager.InitSummary(message.Chat.ID, sentMsg.MessageID, formatted, &markup)
 
-		// 发送处理中消息
-		statusMsg := tgbotapi.NewMessage(message.Chat.ID, "⏳ 正在添加订阅...")
-		statusMsg.ReplyToMessageID = message.MessageID
-		sentMsg, err := b.api.Send(statusMsg)
-		if err != nil {
-			b.logger.Printf("发送消息失败: %v", err)
-			return
-		}
+	// 将每个任务加入队列，设置 StatusMsg 指向同一条状态消息
+	for i := range queuedTasks {
+		queuedTasks[i].StatusMsg = &sentMsg
+		b.taskManager.EnqueueTask(queuedTasks[i])
+		b.notifyTask(EventQueued, queuedTasks[i], "", "")
+	}
+}
 
-		// 添加订阅
-		_, responseMsg := b.addSubscription(match)
+// enqueueLinkTask 为一条链接创建单条任务 (带独立状态消息) 并加入队列。
+// album 不为空时为转发相册，相册中的全部消息作为一个任务转发
+func (b *Bot) enqueueLinkTask(message *tgbotapi.Message, link string, album []string, priority TaskPriority) {
+	user := message.From
+	lang := b.userLang(user)
 
-		// 更新消息
-		editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, sentMsg.MessageID, responseMsg)
-		b.api.Send(editMsg)
+	if b.taskManager.GetQueueSize() >= QueueCapacity {
+		b.replyText(message, T(lang, "queue.full"))
 		return
 	}
 
-	// 无效的消息（既不是 t.me 也不是订阅链接）
-	b.logger.Printf("用户 %d 发送了无效消息", user.ID)
-	warningMsg := tgbotapi.NewMessage(message.Chat.ID,
-		"⚠️ 请发送以下类型的链接:\n"+
-			"• Telegram 链接 (https://t.me/...)\n"+
-			"• 订阅链接 (http/https 格式)")
-	warningMsg.ReplyToMessageID = message.MessageID
-	sentWarning, err := b.api.Send(warningMsg)
+	// 为该链接生成 taskID
+	b.taskManager.mu.Lock()
+	b.taskManager.counters[user.ID]++
+	taskID := b.taskManager.counters[user.ID]
+	b.taskManager.mu.Unlock()
+
+	// 获取队列位置（按优先级计算）
+	queuePosition := b.taskManager.PositionForNew(priority)
+
+	// 创建终止按钮（单条任务）
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "btn.cancel_task"), fmt.Sprintf("cancel_%d_%d", user.ID, taskID)),
+		),
+	)
+
+	// 创建 queuedTask 以便 formatLine 能访问链接与 taskID
+	queuedTask := &QueuedTask{
+		Link:      link,
+		Album:     album,
+		Message:   message,
+		UserID:    user.ID,
+		StatusMsg: nil,
+		TaskID:    taskID,
+		Cancelled: false,
+		Shared:    false,
+		Priority:  priority,
+		Lang:      lang,
+	}
+
+	// 构造单行初始状态（与汇总样式一致）
+	statusText := b.queueStatusText(lang, queuePosition, priority)
+	text := b.formatLine(queuedTask, statusText, false)
+
+	statusMsg := tgbotapi.NewMessage(message.Chat.ID, text)
+	statusMsg.ReplyToMessageID = message.MessageID
+	statusMsg.ReplyMarkup = keyboard
+	sentMsg, err := b.send(statusMsg)
 	if err != nil {
+		b.logger.Error("发送消息失败", "chat_id", message.Chat.ID, "error", err)
 		return
 	}
 
-	// 异步删除提示和原消息(不阻塞主线程)
-	go func() {
-		time.Sleep(5 * time.Second)
-		deleteMsg := tgbotapi.NewDeleteMessage(message.Chat.ID, sentWarning.MessageID)
-		b.api.Send(deleteMsg)
-		deleteOriginal := tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)
-		b.api.Send(deleteOriginal)
-	}()
-
+	queuedTask.StatusMsg = &sentMsg
+	b.taskManager.EnqueueTask(queuedTask)
+	b.notifyTask(EventQueued, queuedTask, "", "")
 }
 
 // startQueueProcessor 启动队列处理器
@@ -767,12 +1065,38 @@ func (b *Bot) startQueueProcessor() {
 	b.taskManager.queueProcessing = true
 	b.taskManager.mu.Unlock()
 
-	b.logger.Println("📋 队列处理器已启动")
+	b.logger.Info("📋 队列处理器已启动")
 
 	go func() {
-		for queuedTask := range b.taskManager.queue {
-			b.logger.Printf("📤 从队列中取出任务 #%d (用户 %d), 剩余队列: %d",
-				queuedTask.TaskID, queuedTask.UserID, b.taskManager.GetQueueSize())
+		defer close(b.queueDone)
+		for {
+			// 暂停、维护中或会话检查未通过时不启动新任务，等待 /resume、/maintenance off 或检查恢复后唤醒
+			// 出队前取得 tdl，任务结束后释放；预览或会话检查进行中时等待其完成
+			b.tdlMu.Lock()
+			var queuedTask *QueuedTask
+			if !b.queueHeld() {
+				queuedTask = b.taskManager.DequeueTask()
+			}
+			if queuedTask == nil {
+				b.tdlMu.Unlock()
+				select {
+				case <-b.stopQueue:
+					b.logger.Info("📋 队列处理器已停止")
+					return
+				case <-b.taskManager.queueSignal:
+				}
+				continue
+			}
+
+			// 停机信号与新任务同时到达时，不再启动新任务，交回停机流程保存
+			if b.shuttingDown.Load() {
+				b.unstarted = queuedTask
+				b.tdlMu.Unlock()
+				return
+			}
+
+			tlog := b.taskLogger(queuedTask)
+			tlog.Info("📤 从队列中取出任务", "queue_size", b.taskManager.GetQueueSize())
 
 			// 检查任务是否已被取消
 			queuedTask.CancelMutex.Lock()
@@ -780,15 +1104,16 @@ func (b *Bot) startQueueProcessor() {
 			queuedTask.CancelMutex.Unlock()
 
 			if cancelled {
-				b.logger.Printf("❌ 任务 #%d 已被取消，跳过执行", queuedTask.TaskID)
+				tlog.Info("❌ 任务已被取消，跳过执行")
 				b.taskManager.RemoveQueuedTask(queuedTask.UserID, queuedTask.TaskID)
+				b.tdlMu.Unlock()
 				// 消息已在取消时更新，这里不需要再更新
 				continue
 			}
 
 			// 更新消息状态为"处理中"
 			if queuedTask.StatusMsg != nil {
-				statusText := b.formatLine(queuedTask, "⏳ 已接收请求，处理中...", queuedTask.Shared)
+				statusText := b.formatLine(queuedTask, T(queuedTask.Lang, "task.processing"), queuedTask.Shared)
 				// 如果这是共享汇总消息，使用 updateSummaryLine 只替换对应行，保留其它行
 				if queuedTask.Shared {
 					b.updateSummaryLine(queuedTask.StatusMsg.Chat.ID, queuedTask.StatusMsg.MessageID, queuedTask.Index, statusText)
@@ -800,7 +1125,7 @@ func (b *Bot) startQueueProcessor() {
 					)
 					keyboard := tgbotapi.NewInlineKeyboardMarkup(
 						tgbotapi.NewInlineKeyboardRow(
-							tgbotapi.NewInlineKeyboardButtonData("🛑 终止任务",
+							tgbotapi.NewInlineKeyboardButtonData(T(queuedTask.Lang, "btn.cancel_task"),
 								fmt.Sprintf("cancel_%d_%d", queuedTask.UserID, queuedTask.TaskID)),
 						),
 					)
@@ -811,12 +1136,12 @@ func (b *Bot) startQueueProcessor() {
 
 			// 执行任务
 			b.processTDLForward(queuedTask)
+			b.tdlMu.Unlock()
 
 			// 从队列任务映射中移除
 			b.taskManager.RemoveQueuedTask(queuedTask.UserID, queuedTask.TaskID)
 
-			b.logger.Printf("✅ 任务 #%d 处理完成 (用户 %d), 剩余队列: %d",
-				queuedTask.TaskID, queuedTask.UserID, b.taskManager.GetQueueSize())
+			tlog.Info("✅ 任务处理完成", "queue_size", b.taskManager.GetQueueSize())
 		}
 	}()
 }
@@ -826,14 +1151,16 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 	userID := queuedTask.UserID
 	chatID := queuedTask.Message.Chat.ID
 	taskID := queuedTask.TaskID
-	link := queuedTask.Link
+	link := queuedTask.source()
 	sentMsg := queuedTask.StatusMsg
+	lang := queuedTask.Lang
 
 	// 创建任务
 	task := &Task{
 		UserID:  userID,
 		ID:      taskID,
 		Message: sentMsg,
+		Source:  queuedTask,
 	}
 
 	// 添加任务到管理器（用于跟踪执行中的任务）
@@ -852,23 +1179,43 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 		b.taskManager.SetCurrentTask(nil)
 	}()
 
-	b.logger.Printf("开始处理用户 %d 的转发请求 (任务 #%d)", userID, taskID)
+	tlog := b.taskLogger(queuedTask)
+	tlog.Info("开始处理转发请求", "link", link)
 
 	// 创建终止按钮
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🛑 终止任务", fmt.Sprintf("cancel_%d_%d", userID, taskID)),
+			tgbotapi.NewInlineKeyboardButtonData(T(lang, "btn.cancel_task"), fmt.Sprintf("cancel_%d_%d", userID, taskID)),
 		),
 	)
 
-	// 创建上下文用于取消
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	// 创建上下文用于取消；超时由看门狗按无进度时间和最长执行时间判定
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	task.Cancel = cancel
 
+	// 选择执行任务的账号，所有账号都在暂停中时等待
+	account, ok := b.waitForAccount(ctx, userID, func(until time.Time) {
+		tlog.Warn("所有账号暂停中，等待恢复", "until", until)
+		status := T(lang, "accounts.waiting", "until", until.Format("15:04:05"))
+		if queuedTask.Shared {
+			b.updateSummaryLine(chatID, sentMsg.MessageID, queuedTask.Index, b.formatSummaryLine(queuedTask, status))
+		} else {
+			b.updateTaskMessage(chatID, sentMsg.MessageID, b.formatLine(queuedTask, status, false), &keyboard)
+		}
+	})
+	if !ok {
+		tlog.Info("等待账号时任务被终止")
+		return
+	}
+	task.Account = account
+	tlog = tlog.With("account", account)
+	b.taskHooks(HookPreRun, queuedTask, account, nil, "")
+
 	// 构建命令
 	taskLockID := fmt.Sprintf("%d_%d", userID, taskID)
-	cmd := exec.CommandContext(ctx, "bash", TDLScriptPath, link, taskLockID)
+	cmd := exec.CommandContext(ctx, "bash", TDLScriptPath, link, taskLockID, ForwardTarget)
+	cmd.Env = append(os.Environ(), "TDL_NAMESPACE="+account)
 	// 尝试为子进程设置进程组
 	setProcessGroup(cmd)
 	task.Cmd = cmd
@@ -876,22 +1223,25 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 	// 创建管道读取输出
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		b.logger.Printf("创建管道失败: %v", err)
+		tlog.Error("创建管道失败", "error", err)
 		if queuedTask.Shared {
-			b.updateSummaryLine(chatID, sentMsg.MessageID, queuedTask.Index, b.formatSummaryLine(queuedTask, fmt.Sprintf("❌ 任务 #%d 启动失败", taskID)))
+			b.updateSummaryLine(chatID, sentMsg.MessageID, queuedTask.Index, b.formatSummaryLine(queuedTask, T(lang, "task.start_failed", "id", taskID)))
 		} else {
-			b.updateTaskMessage(chatID, sentMsg.MessageID, fmt.Sprintf("❌ 任务 #%d 启动失败", taskID), nil)
+			b.updateTaskMessage(chatID, sentMsg.MessageID, T(lang, "task.start_failed", "id", taskID), nil)
 		}
 		return
 	}
 
 	// 启动命令
 	if err := cmd.Start(); err != nil {
-		b.logger.Printf("启动命令失败: %v", err)
+		tlog.Error("启动命令失败", "error", err)
+		b.notifyTask(EventFailed, queuedTask, account, T(lang, "task.start_failed", "id", taskID))
+		exitCode := exitCodeOf(err)
+		go b.taskHooks(HookPostFailure, queuedTask, account, &exitCode, T(lang, "task.start_failed", "id", taskID))
 		if queuedTask.Shared {
-			b.updateSummaryLine(chatID, sentMsg.MessageID, queuedTask.Index, b.formatSummaryLine(queuedTask, fmt.Sprintf("❌ 任务 #%d 启动失败", taskID)))
+			b.updateSummaryLine(chatID, sentMsg.MessageID, queuedTask.Index, b.formatSummaryLine(queuedTask, T(lang, "task.start_failed", "id", taskID)))
 		} else {
-			b.updateTaskMessage(chatID, sentMsg.MessageID, fmt.Sprintf("❌ 任务 #%d 启动失败", taskID), nil)
+			b.updateTaskMessage(chatID, sentMsg.MessageID, T(lang, "task.start_failed", "id", taskID), nil)
 		}
 		return
 	}
@@ -902,12 +1252,19 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 			task.PGID = pgid
 		}
 	}
+	b.notifyTask(EventStarted, queuedTask, account, "")
 
 	// 读取输出
 	lastUpdate := time.Now()
-	currentStatus := fmt.Sprintf("⏳ 任务 #%d - 已接收请求，处理中...", taskID)
+	currentStatus := T(lang, "task.processing_id", "id", taskID)
 	qrDetected := false
 	statusSeen := false
+	authFailed := false
+
+	maxDuration := taskMaxDuration(queuedTask)
+	watchdog := newTaskWatchdog(TaskStallTimeout, maxDuration)
+	defer watchdog.Stop()
+	timeoutReason := ""
 
 	lineChan := make(chan string)
 	go func() {
@@ -920,25 +1277,67 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 	}()
 
 	// 处理输出
-	for line := range lineChan {
+outputLoop:
+	for {
+		var line string
+		select {
+		case l, ok := <-lineChan:
+			if !ok {
+				break outputLoop
+			}
+			line = l
+			watchdog.Touch()
+		case <-watchdog.StallC():
+			// 等待扫码登录期间没有输出是正常的，不计为无进度 (等待时间由 LoginTimeout 限制)
+			if qrDetected && !statusSeen {
+				watchdog.Touch()
+				continue
+			}
+			timeoutReason = timeoutReasonStall
+			tlog.Warn("任务长时间无进度，终止执行", "stall_timeout", TaskStallTimeout)
+			watchdog.Stop()
+			terminateTask(task)
+			continue
+		case <-watchdog.DeadlineC():
+			timeoutReason = timeoutReasonDeadline
+			tlog.Warn("任务超过最长执行时间，终止执行", "max_duration", maxDuration)
+			watchdog.Stop()
+			terminateTask(task)
+			continue
+		case <-watchdog.LoginC():
+			timeoutReason = timeoutReasonLogin
+			tlog.Warn("等待扫码登录超时，终止执行", "login_timeout", LoginTimeout)
+			watchdog.Stop()
+			terminateTask(task)
+			continue
+		}
+
 		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
-		b.logger.Printf("TDL 输出 (任务 #%d): %s", taskID, line)
+		tlog.Log(ctx, tdlLineLevel(line), "TDL 输出", "line", line)
+
+		// 识别账号限流与会话失效，用于后续任务的账号选择
+		if wait, ok := parseFloodWait(line); ok {
+			tlog.Warn("账号触发限流", "wait", wait)
+			if err := b.accounts.Flood(account, wait); err != nil {
+				tlog.Error("保存账号状态失败", "error", err)
+			}
+		}
+		if accountAuthError(line) {
+			authFailed = true
+		}
 
 		// 检测二维码 ASCII 字符
 		if !qrDetected && (strings.Contains(line, "Scan QR code") || strings.Contains(line, "█")) {
 			qrDetected = true
-			b.logger.Printf("检测到登录二维码 (任务 #%d)", taskID)
-
-			qrMessage := fmt.Sprintf(
-				"🔐 任务 #%d - 需要登录\n\n"+
-					"📺 请到服务器控制台查看二维码并使用 Telegram 扫描登录\n\n"+
-					"⏰ 登录后任务将自动继续",
-				taskID,
-			)
+			watchdog.StartLogin(LoginTimeout)
+			tlog.Warn("检测到登录二维码")
+			b.notifyTask(EventLoginRequired, queuedTask, account, "")
+
+			qrMessage := T(lang, "task.login_console", "id", taskID)
 			b.updateTaskMessage(chatID, sentMsg.MessageID, qrMessage, &keyboard)
 			continue
 		}
@@ -947,15 +1346,10 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 		if strings.Contains(line, "[QRCODE]") {
 			qrLink := strings.ReplaceAll(line, "[QRCODE]", "")
 			qrLink = strings.TrimSpace(qrLink)
-			b.logger.Printf("检测到登录二维码链接: %s", qrLink)
-
-			qrMessage := fmt.Sprintf(
-				"🔐 任务 #%d - 需要登录\n\n"+
-					"📱 请点击以下链接在 Telegram 中完成登录:\n"+
-					"%s\n\n"+
-					"⏰ 登录后任务将自动继续",
-				taskID, qrLink,
-			)
+			watchdog.StartLogin(LoginTimeout)
+			tlog.Warn("检测到登录二维码链接", "qr_link", qrLink)
+
+			qrMessage := T(lang, "task.login_link", "id", taskID, "link", qrLink)
 			b.updateTaskMessage(chatID, sentMsg.MessageID, qrMessage, &keyboard)
 			continue
 		} // 只处理带 [STATUS] 标记的消息
@@ -964,6 +1358,7 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 			cleanLine = strings.TrimSpace(cleanLine)
 			currentStatus = cleanLine
 			statusSeen = true
+			watchdog.EndLogin()
 
 			// 限制更新频率 (至少间隔1秒)
 			if time.Since(lastUpdate) >= time.Second {
@@ -976,17 +1371,24 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 			}
 		}
 
+		// 停机中断：状态消息由停机流程统一更新
+		if b.taskInterrupted(task) {
+			tlog.Info("任务因停机被中断")
+			return
+		}
+
 		// 检查任务是否被取消
 		if _, exists := b.taskManager.GetTask(userID, taskID); !exists {
-			b.logger.Printf("用户 %d 的任务 #%d 已被取消", userID, taskID)
+			tlog.Info("任务已被取消")
+			b.notifyTask(EventCancelled, queuedTask, account, "")
 			if queuedTask.Shared {
-				b.updateSummaryLine(chatID, sentMsg.MessageID, queuedTask.Index, b.formatSummaryLine(queuedTask, fmt.Sprintf("❌ 任务 #%d 已被用户终止", taskID)))
+				b.updateSummaryLine(chatID, sentMsg.MessageID, queuedTask.Index, b.formatSummaryLine(queuedTask, T(lang, "task.terminated", "id", taskID)))
 				// 任务在运行中被取消：递减汇总待完成计数并在必要时清除键盘
 				if remaining := b.taskManager.DecrementSummaryPending(chatID, sentMsg.MessageID); remaining <= 0 {
 					b.clearSummaryKeyboard(chatID, sentMsg.MessageID)
 				}
 			} else {
-				b.updateTaskMessage(chatID, sentMsg.MessageID, fmt.Sprintf("❌ 任务 #%d 已被用户终止", taskID), nil)
+				b.updateTaskMessage(chatID, sentMsg.MessageID, T(lang, "task.terminated", "id", taskID), nil)
 			}
 			return
 		}
@@ -1012,26 +1414,50 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 		_ = syscall.Kill(-task.PGID, syscall.SIGKILL)
 	}
 
-	b.logger.Printf("TDL 脚本执行完成 (任务 #%d), 错误: %v", taskID, err)
+	tlog.Info("TDL 脚本执行完成", "error", err)
+
+	if b.taskInterrupted(task) {
+		tlog.Info("任务因停机被中断")
+		return
+	}
+
+	// 用户终止的任务不计入账号健康状态
+	if ctx.Err() == nil || timeoutReason != "" {
+		b.markAccountTask(task, err != nil, authFailed)
+	}
 
 	// 根据返回结果更新最终状态
 	var finalStatus string
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			finalStatus = fmt.Sprintf("❌ 任务 #%d 执行超时", taskID)
+		if timeoutReason != "" {
+			finalStatus = T(lang, "task.timeout", "id", taskID, "reason", T(lang, timeoutReason))
 		} else {
-			finalStatus = fmt.Sprintf("⚠️ 任务 #%d 执行失败", taskID)
+			finalStatus = T(lang, "task.failed", "id", taskID)
 		}
 	} else {
+		b.recordForwarded(queuedTask)
+
 		// 如果曾经接收到过 [STATUS] 行，优先使用最后一条非链接的 status 文本作为最终状态
 		if statusSeen && strings.TrimSpace(currentStatus) != "" {
 			finalStatus = strings.ReplaceAll(currentStatus, "[STATUS]", "")
 			finalStatus = strings.TrimSpace(finalStatus)
 		} else {
-			finalStatus = fmt.Sprintf("✅ 任务 #%d 处理完成", taskID)
+			finalStatus = T(lang, "task.done", "id", taskID)
 		}
 	}
 
+	exitCode := exitCodeOf(err)
+	switch {
+	case err == nil:
+		b.notifyTask(EventCompleted, queuedTask, account, finalStatus)
+		go b.taskHooks(HookPostSuccess, queuedTask, account, &exitCode, finalStatus)
+	case ctx.Err() != nil && timeoutReason == "":
+		b.notifyTask(EventCancelled, queuedTask, account, "")
+	default:
+		b.notifyTask(EventFailed, queuedTask, account, finalStatus)
+		go b.taskHooks(HookPostFailure, queuedTask, account, &exitCode, finalStatus)
+	}
+
 	// 更新为最终状态(移除按钮)
 	if queuedTask.Shared {
 		b.updateSummaryLine(chatID, sentMsg.MessageID, queuedTask.Index, b.formatSummaryDoneLine(queuedTask, finalStatus))
@@ -1047,6 +1473,27 @@ func (b *Bot) processTDLForward(queuedTask *QueuedTask) {
 
 // handleCallbackQuery 处理回调查询 (按钮点击)
 func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
+	if strings.HasPrefix(query.Data, "queue_") {
+		b.handleQueueCallback(query)
+		return
+	}
+	if strings.HasPrefix(query.Data, "subs_page_") {
+		b.handleSubsPageCallback(query)
+		return
+	}
+	if strings.HasPrefix(query.Data, "subok_") || strings.HasPrefix(query.Data, "subno_") {
+		b.handleSubPreviewCallback(query)
+		return
+	}
+	if strings.HasPrefix(query.Data, "fwdok_") || strings.HasPrefix(query.Data, "fwdno_") {
+		b.handleSourcePreviewCallback(query)
+		return
+	}
+	if strings.HasPrefix(query.Data, "fwdagain_") {
+		b.handleForwardOverrideCallback(query)
+		return
+	}
+
 	// 解析回调数据: 支持 cancel_summary_<userID> 和 cancel_<userID>_<taskID>
 	if !strings.HasPrefix(query.Data, "cancel_") {
 		return
@@ -1054,18 +1501,19 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 
 	parts := strings.Split(query.Data, "_")
 	if len(parts) < 2 {
-		callback := tgbotapi.NewCallback(query.ID, "⚠️ 无效的任务标识")
+		callback := tgbotapi.NewCallback(query.ID, T(b.userLang(query.From), "cb.invalid_task"))
 		callback.ShowAlert = true
 		b.api.Request(callback)
 		return
 	}
 
 	currentUserID := query.From.ID
+	lang := b.userLang(query.From)
 
 	// 处理汇总取消: cancel_summary_<userID>
 	if parts[1] == "summary" {
 		if len(parts) < 3 {
-			callback := tgbotapi.NewCallback(query.ID, "⚠️ 无效的任务标识")
+			callback := tgbotapi.NewCallback(query.ID, T(lang, "cb.invalid_task"))
 			callback.ShowAlert = true
 			b.api.Request(callback)
 			return
@@ -1073,9 +1521,9 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 		var targetUserID int64
 		fmt.Sscanf(parts[2], "%d", &targetUserID)
 
-		// 验证权限 (只能取消自己的汇总)
-		if currentUserID != targetUserID {
-			callback := tgbotapi.NewCallback(query.ID, "❌ 您无权终止此任务汇总")
+		// 验证权限 (任务所有者、管理员或所在群组的管理员)
+		if !b.canCancelFor(currentUserID, targetUserID, queryChat(query)) {
+			callback := tgbotapi.NewCallback(query.ID, T(lang, "cb.no_permission_summary"))
 			callback.ShowAlert = true
 			b.api.Request(callback)
 			return
@@ -1090,8 +1538,10 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 			if queuedMap != nil {
 				for _, q := range queuedMap {
 					if q.StatusMsg != nil && q.StatusMsg.MessageID == query.Message.MessageID {
-						b.taskManager.CancelQueuedTask(targetUserID, q.TaskID)
-						b.updateSummaryLine(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID, q.Index, b.formatSummaryLine(q, fmt.Sprintf("❌ 任务 #%d 已从汇总取消", q.TaskID)))
+						if b.taskManager.CancelQueuedTask(targetUserID, q.TaskID) {
+							b.notifyTask(EventCancelled, q, "", "")
+						}
+						b.updateSummaryLine(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID, q.Index, b.formatSummaryLine(q, T(q.Lang, "task.cancelled_summary", "id", q.TaskID)))
 						// 取消队列中的任务后应递减汇总待完成计数并在必要时清除键盘
 						if remaining := b.taskManager.DecrementSummaryPending(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID); remaining <= 0 {
 							b.clearSummaryKeyboard(q.StatusMsg.Chat.ID, q.StatusMsg.MessageID)
@@ -1110,7 +1560,7 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 						b.taskManager.CancelTask(targetUserID, tid)
 						// 尝试查找对应的 queued 以更新汇总行（若仍存在）
 						if q, ok := b.taskManager.GetQueuedTask(targetUserID, tid); ok {
-							b.updateSummaryLine(query.Message.Chat.ID, query.Message.MessageID, q.Index, b.formatSummaryLine(q, fmt.Sprintf("❌ 任务 #%d 已被用户终止", tid)))
+							b.updateSummaryLine(query.Message.Chat.ID, query.Message.MessageID, q.Index, b.formatSummaryLine(q, T(q.Lang, "task.terminated", "id", tid)))
 							// 对于仍在 queued 映射中的项，我们需要递减汇总计数
 							if remaining := b.taskManager.DecrementSummaryPending(query.Message.Chat.ID, query.Message.MessageID); remaining <= 0 {
 								b.clearSummaryKeyboard(query.Message.Chat.ID, query.Message.MessageID)
@@ -1128,7 +1578,7 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 
 	// 非汇总取消，解析 cancel_<userID>_<taskID>
 	if len(parts) < 3 {
-		callback := tgbotapi.NewCallback(query.ID, "⚠️ 无效的任务标识")
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "cb.invalid_task"))
 		callback.ShowAlert = true
 		b.api.Request(callback)
 		return
@@ -1137,37 +1587,17 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 	fmt.Sscanf(parts[1], "%d", &targetUserID)
 	fmt.Sscanf(parts[2], "%d", &taskID)
 
-	// 验证权限 (只能取消自己的任务)
-	if currentUserID != targetUserID {
-		callback := tgbotapi.NewCallback(query.ID, "❌ 您无权终止此任务")
+	// 验证权限 (任务所有者、管理员或所在群组的管理员)
+	if !b.canCancelFor(currentUserID, targetUserID, queryChat(query)) {
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "cb.no_permission_task"))
 		callback.ShowAlert = true
 		b.api.Request(callback)
 		return
 	}
 
 	// 先尝试取消队列中的任务
-	if queued, ok := b.taskManager.GetQueuedTask(targetUserID, int(taskID)); ok {
-		// 标记取消
-		cancelled := b.taskManager.CancelQueuedTask(targetUserID, int(taskID))
-		if cancelled {
-			b.logger.Printf("用户 %d 取消了队列中的任务 #%d", targetUserID, taskID)
-			// 如果是共享汇总消息，只更新对应行
-			if queued.Shared && queued.StatusMsg != nil {
-				b.updateSummaryLine(queued.StatusMsg.Chat.ID, queued.StatusMsg.MessageID, queued.Index, b.formatSummaryLine(queued, fmt.Sprintf("❌ 任务 #%d 已从队列中取消", taskID)))
-				// 递减汇总待完成计数并在必要时清除键盘
-				if remaining := b.taskManager.DecrementSummaryPending(queued.StatusMsg.Chat.ID, queued.StatusMsg.MessageID); remaining <= 0 {
-					b.clearSummaryKeyboard(queued.StatusMsg.Chat.ID, queued.StatusMsg.MessageID)
-				}
-			} else if queued.StatusMsg != nil {
-				// 非共享，直接替换整条状态消息
-				editMsg := tgbotapi.NewEditMessageText(
-					query.Message.Chat.ID,
-					queued.StatusMsg.MessageID,
-					fmt.Sprintf("❌ 任务 #%d 已从队列中取消", taskID),
-				)
-				b.api.Send(editMsg)
-			}
-
+	if queued, ok := b.taskManager.GetQueuedTask(targetUserID, int(taskID)); ok && b.taskManager.QueuePosition(targetUserID, int(taskID)) > 0 {
+		if b.cancelQueuedWithNotice(queued, T(queued.Lang, "task.cancelled_queue", "id", taskID)) {
 			callback := tgbotapi.NewCallback(query.ID, "")
 			b.api.Request(callback)
 			return
@@ -1176,7 +1606,7 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 
 	// 如果不在队列中，尝试终止正在执行的任务
 	if b.taskManager.CancelTask(targetUserID, int(taskID)) {
-		b.logger.Printf("用户 %d 终止了执行中的任务 #%d", targetUserID, taskID)
+		b.logger.Info("终止了执行中的任务", "user_id", targetUserID, "task_id", taskID)
 
 		// 如果该消息是汇总消息，更新对应行；否则替换整条
 		if query.Message != nil {
@@ -1184,12 +1614,12 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 				if _, ok2 := linesMap[query.Message.MessageID]; ok2 {
 					// 找 queued task to find index
 					if queued, ok3 := b.taskManager.GetQueuedTask(targetUserID, int(taskID)); ok3 && queued.Shared {
-						b.updateSummaryLine(query.Message.Chat.ID, query.Message.MessageID, queued.Index, b.formatSummaryDoneLine(queued, fmt.Sprintf("❌ 任务 #%d 已被用户终止", taskID)))
+						b.updateSummaryLine(query.Message.Chat.ID, query.Message.MessageID, queued.Index, b.formatSummaryDoneLine(queued, T(queued.Lang, "task.terminated", "id", taskID)))
 					} else {
 						editMsg := tgbotapi.NewEditMessageText(
 							query.Message.Chat.ID,
 							query.Message.MessageID,
-							fmt.Sprintf("❌ 任务 #%d 已被用户终止", taskID),
+							T(lang, "task.terminated", "id", taskID),
 						)
 						b.api.Send(editMsg)
 					}
@@ -1197,7 +1627,7 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 					editMsg := tgbotapi.NewEditMessageText(
 						query.Message.Chat.ID,
 						query.Message.MessageID,
-						fmt.Sprintf("❌ 任务 #%d 已被用户终止", taskID),
+						T(lang, "task.terminated", "id", taskID),
 					)
 					b.api.Send(editMsg)
 				}
@@ -1205,7 +1635,7 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 				editMsg := tgbotapi.NewEditMessageText(
 					query.Message.Chat.ID,
 					query.Message.MessageID,
-					fmt.Sprintf("❌ 任务 #%d 已被用户终止", taskID),
+					T(lang, "task.terminated", "id", taskID),
 				)
 				b.api.Send(editMsg)
 			}
@@ -1214,7 +1644,7 @@ func (b *Bot) handleCallbackQuery(query *tgbotapi.CallbackQuery) {
 		callback := tgbotapi.NewCallback(query.ID, "")
 		b.api.Request(callback)
 	} else {
-		callback := tgbotapi.NewCallback(query.ID, "⚠️ 任务已完成或不存在")
+		callback := tgbotapi.NewCallback(query.ID, T(lang, "cb.task_gone"))
 		callback.ShowAlert = true
 		b.api.Request(callback)
 	}
@@ -1228,7 +1658,7 @@ func (b *Bot) updateTaskMessage(chatID int64, messageID int, text string, keyboa
 	}
 	_, err := b.api.Send(editMsg)
 	if err != nil {
-		b.logger.Printf("更新消息失败: %v", err)
+		b.logger.Warn("更新消息失败", "chat_id", chatID, "message_id", messageID, "error", err)
 	}
 }
 
@@ -1241,7 +1671,7 @@ func (b *Bot) updateSummaryLine(chatID int64, messageID int, index int, text str
 		edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
 		_, err := b.api.Send(edit)
 		if err != nil {
-			b.logger.Printf("更新汇总消息失败: %v", err)
+			b.logger.Warn("更新汇总消息失败", "chat_id", chatID, "message_id", messageID, "error", err)
 		}
 		return
 	}
@@ -1254,7 +1684,7 @@ func (b *Bot) updateSummaryLine(chatID int64, messageID int, index int, text str
 	}
 	_, err := b.api.Send(edit)
 	if err != nil {
-		b.logger.Printf("更新汇总消息失败: %v", err)
+		b.logger.Warn("更新汇总消息失败", "chat_id", chatID, "message_id", messageID, "error", err)
 	}
 }
 
@@ -1269,7 +1699,7 @@ func (b *Bot) clearSummaryKeyboard(chatID int64, messageID int) {
 	// 不设置 ReplyMarkup，即移除键盘
 	_, err := b.api.Send(edit)
 	if err != nil {
-		b.logger.Printf("清除汇总键盘失败: %v", err)
+		b.logger.Warn("清除汇总键盘失败", "chat_id", chatID, "message_id", messageID, "error", err)
 	}
 }
 
@@ -1286,7 +1716,7 @@ func (b *Bot) formatSummaryDoneLine(q *QueuedTask, final string) string {
 	s = strings.TrimSpace(s)
 
 	// 尝试移除 final 中可能已经包含的 "任务 #<id>" 子串，避免重复
-	targ := fmt.Sprintf("任务 #%d", q.TaskID)
+	targ := T(q.Lang, "task.ref", "id", q.TaskID)
 	if idx := strings.Index(s, targ); idx != -1 {
 		s = strings.TrimSpace(s[idx+len(targ):])
 	}
@@ -1294,7 +1724,7 @@ func (b *Bot) formatSummaryDoneLine(q *QueuedTask, final string) string {
 	s = strings.TrimLeft(s, " -–—:：")
 	s = strings.TrimSpace(s)
 	if s == "" {
-		s = "已完成"
+		s = T(q.Lang, "task.done_default")
 	}
 
 	// 使用统一单行格式
@@ -1313,7 +1743,7 @@ func (b *Bot) formatTaskDoneLine(q *QueuedTask, final string) string {
 func (b *Bot) formatLine(q *QueuedTask, status string, includeIndex bool) string {
 	// 先清理 status 中可能包含的 "任务 #<id>" 前缀，避免重复显示任务编号
 	s := status
-	targ := fmt.Sprintf("任务 #%d", q.TaskID)
+	targ := T(q.Lang, "task.ref", "id", q.TaskID)
 	if idx := strings.Index(s, targ); idx != -1 {
 		// 仅移除位于开头或开头附近的前缀
 		// 找到 targ 后移除并去除常见分隔符
@@ -1357,7 +1787,7 @@ func (b *Bot) formatLine(q *QueuedTask, status string, includeIndex bool) string
 		progress = progress[:200] + "..."
 	}
 
-	base := fmt.Sprintf("[#%d] %s — %s", q.TaskID, q.Link, progress)
+	base := fmt.Sprintf("[#%d] %s — %s", q.TaskID, q.displayLink(), progress)
 	if includeIndex {
 		return fmt.Sprintf("%d. %s", q.Index+1, base)
 	}
@@ -1366,34 +1796,100 @@ func (b *Bot) formatLine(q *QueuedTask, status string, includeIndex bool) string
 
 // Run 启动 Bot
 func (b *Bot) Run() error {
-	b.logger.Println("=" + strings.Repeat("=", 49))
-	b.logger.Println("正在启动 Telegram TDL Bot...")
-	b.logger.Printf("TDL 脚本路径: %s", TDLScriptPath)
-	if AllowedUsers == nil {
-		b.logger.Println("权限模式: 开放")
-	} else {
-		b.logger.Println("权限模式: 白名单")
-	}
-	b.logger.Println("任务模式: 排队执行 (一次一个)")
-	b.logger.Println("=" + strings.Repeat("=", 49))
+	permissionMode := "开放"
+	if AllowedUsers != nil {
+		permissionMode = "白名单"
+	}
+	b.logger.Info("正在启动 Telegram TDL Bot...",
+		"tdl_script", TDLScriptPath,
+		"permission_mode", permissionMode,
+		"task_mode", "排队执行 (一次一个)",
+	)
 
 	// 检查 TDL 脚本是否存在
 	if _, err := os.Stat(TDLScriptPath); os.IsNotExist(err) {
-		b.logger.Printf("❌ TDL 脚本未找到: %s", TDLScriptPath)
-		b.logger.Println("请检查 TDL_SCRIPT_PATH 配置或脚本路径")
+		b.logger.Error("❌ TDL 脚本未找到，请检查 TDL_SCRIPT_PATH 配置或脚本路径", "tdl_script", TDLScriptPath)
 		return fmt.Errorf("TDL 脚本未找到")
 	}
 
+	// 检查消息目录完整性并加载用户语言设置
+	for _, problem := range checkLocales() {
+		b.logger.Warn("消息目录不完整", "problem", problem)
+	}
+	if err := b.userLangs.load(); err != nil {
+		b.logger.Error("加载用户语言设置失败", "error", err)
+	}
+
+	// 加载用户通知设置
+	if err := b.notifySettings.load(); err != nil {
+		b.logger.Error("加载通知设置失败", "error", err)
+	}
+
+	// 加载临时封禁记录
+	if err := b.inbound.load(); err != nil {
+		b.logger.Error("加载封禁记录失败", "error", err)
+	}
+
+	// 加载账号池与已转发记录
+	if err := b.accounts.load(); err != nil {
+		b.logger.Error("加载账号池失败", "error", err)
+	}
+	if err := b.history.load(); err != nil {
+		b.logger.Error("加载转发记录失败", "error", err)
+	}
+
+	// 加载暂停/维护状态
+	if err := b.control.load(); err != nil {
+		b.logger.Error("加载队列控制状态失败", "error", err)
+	}
+	if state := b.control.State(); state.Paused || state.Maintenance {
+		b.logger.Warn("队列处于暂停或维护状态", "paused", state.Paused, "maintenance", state.Maintenance)
+	}
+
+	// 加载订阅归属记录
+	if err := b.subStore.load(); err != nil {
+		b.logger.Error("加载订阅归属记录失败", "error", err)
+	}
+
+	// 加载待提交订阅队列并启动重试
+	if err := b.subOutbox.load(); err != nil {
+		b.logger.Error("加载待提交订阅队列失败", "error", err)
+	}
+	go b.runSubscriptionOutbox()
+
+	// 安装固定版本的 tdl，tdl.sh 不再自行检查更新
+	if TDLManaged {
+		os.Setenv("TDL_MANAGED", "1")
+		if err := b.tdl.ensure(context.Background()); err != nil {
+			b.logger.Error("安装 tdl 失败", "version", TDLVersion, "error", err)
+		} else {
+			b.logger.Info("tdl 已就绪", "version", b.tdl.Version())
+		}
+	}
+
+	// 定期检查账号会话与转发目标
+	go b.runSessionMonitor()
+
+	// 定期清理空闲的限流状态
+	go b.runInboundPrune()
+
+	// 发布命令菜单
+	if RegisterCommandMenus {
+		go b.registerCommandMenus()
+	}
+
 	// 启动队列处理器
 	b.startQueueProcessor()
 
+	// 恢复上次停机时保存的任务
+	b.restorePendingTasks()
+
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
-	updates := b.api.GetUpdatesChan(u)
+	updates := b.pollUpdates(u)
 
-	b.logger.Printf("✅ Bot 已启动 (@%s), 按 Ctrl-C 停止", b.api.Self.UserName)
-	b.logger.Println("等待接收消息...")
+	b.logger.Info("✅ Bot 已启动, 按 Ctrl-C 停止, 等待接收消息...", "bot", b.api.Self.UserName)
 
 	// 处理信号
 	sigChan := make(chan os.Signal, 1)
@@ -1401,28 +1897,25 @@ func (b *Bot) Run() error {
 
 	for {
 		select {
-		case <-sigChan:
-			b.logger.Println("收到停止信号，正在关闭...")
-			b.api.StopReceivingUpdates()
-			return nil
+		case sig := <-sigChan:
+			b.logger.Info("收到停止信号，正在关闭...", "signal", sig.String())
+			return b.shutdown()
 
 		case update := <-updates:
+			// 群组中只处理发给 Bot 的消息
+			if update.Message != nil && isGroupChat(update.Message.Chat) && !b.addressedToBot(update.Message) {
+				continue
+			}
+			// 命令由命令注册表的限流中间件限流
+			if update.Message != nil && update.Message.IsCommand() {
+				b.handleCommand(update.Message)
+				continue
+			}
+			if !b.allowInbound(update) {
+				continue
+			}
 			if update.Message != nil {
-				// 处理命令
-				if update.Message.IsCommand() {
-					switch update.Message.Command() {
-					case "start":
-						b.handleStart(update.Message)
-					case "help":
-						b.handleHelp(update.Message)
-					case "status":
-						b.handleStatus(update.Message)
-					default:
-						msg := tgbotapi.NewMessage(update.Message.Chat.ID, "❓ 未知命令，使用 /help 查看帮助")
-						msg.ReplyToMessageID = update.Message.MessageID
-						b.api.Send(msg)
-					}
-				} else if update.Message.Text != "" {
+				if update.Message.Text != "" || update.Message.Caption != "" || update.Message.Document != nil || isForwarded(update.Message) {
 					// 处理普通文本消息
 					b.handleMessage(update.Message)
 				}
@@ -1434,6 +1927,15 @@ func (b *Bot) Run() error {
 	}
 }
 
+// taskLogger 返回携带任务上下文 (task_id, user_id, chat_id) 的日志器
+func (b *Bot) taskLogger(q *QueuedTask) *slog.Logger {
+	var chatID int64
+	if q.Message != nil && q.Message.Chat != nil {
+		chatID = q.Message.Chat.ID
+	}
+	return b.logger.With("task_id", q.TaskID, "user_id", q.UserID, "chat_id", chatID)
+}
+
 // truncateString 截断字符串
 func truncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -1486,12 +1988,33 @@ func main() {
 		}
 	}
 
-	bot, err := NewBot(BotToken)
+	if BotDataDir == "" {
+		BotDataDir = filepath.Join(filepath.Dir(TDLScriptPath), ".bot")
+	}
+
+	// 登记敏感信息，日志输出时自动脱敏
+	registerSecret(BotToken)
+	registerSecret(SubscriptionAPIKey)
+	registerSecret(SMTPPassword)
+	for _, hook := range NotifyWebhooks {
+		registerSecret(hook.Secret)
+	}
+
+	logger, logCloser, err := newLogger()
+	if err != nil {
+		log.Fatalf("初始化日志失败: %v", err)
+	}
+	defer logCloser.Close()
+
+	bot, err := NewBot(BotToken, logger)
 	if err != nil {
-		log.Fatalf("创建 Bot 失败: %v", err)
+		logger.Error("创建 Bot 失败", "error", err)
+		os.Exit(1)
 	}
 
 	if err := bot.Run(); err != nil {
-		log.Fatalf("Bot 运行失败: %v", err)
+		logger.Error("Bot 运行失败", "error", err)
+		logCloser.Close()
+		os.Exit(1)
 	}
 }
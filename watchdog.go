@@ -0,0 +1,127 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "time"
+
+// 任务超时原因（消息目录中的键），显示在最终状态中
+const (
+	timeoutReasonStall    = "timeout.stall"
+	timeoutReasonDeadline = "timeout.deadline"
+	timeoutReasonLogin    = "timeout.login"
+)
+
+// 任务类型与角色，用于查找最长执行时间
+const (
+	taskKindForward = "forward"
+	roleAdmin       = "admin"
+	roleUser        = "user"
+)
+
+// taskKind 返回任务类型
+func taskKind(q *QueuedTask) string {
+	return taskKindForward
+}
+
+// userRole 返回用户角色
+func userRole(userID int64) string {
+	if isAdmin(userID) {
+		return roleAdmin
+	}
+	return roleUser
+}
+
+// taskMaxDuration 返回任务的最长执行时间：按任务类型和角色分别查找，
+// 两者都配置时取较小值，均未配置时返回 0 (不限制)
+func taskMaxDuration(q *QueuedTask) time.Duration {
+	var limit time.Duration
+	for _, d := range []time.Duration{TaskMaxDurationByKind[taskKind(q)], TaskMaxDurationByRole[userRole(q.UserID)]} {
+		if d > 0 && (limit == 0 || d < limit) {
+			limit = d
+		}
+	}
+	return limit
+}
+
+// taskWatchdog 任务看门狗：超过 stallTimeout 没有任何输出视为无进度，
+// 超过 maxDuration 视为总时长超时，等待扫码登录超过 LoginTimeout 视为登录超时。
+// 对应的 timeout 为 0 时不启用。只在执行任务的 goroutine 中使用
+type taskWatchdog struct {
+	stallTimeout time.Duration
+	stall        *time.Timer
+	deadline     *time.Timer
+	login        *time.Timer
+	stopped      bool
+}
+
+func newTaskWatchdog(stallTimeout, maxDuration time.Duration) *taskWatchdog {
+	w := &taskWatchdog{stallTimeout: stallTimeout}
+	if stallTimeout > 0 {
+		w.stall = time.NewTimer(stallTimeout)
+	}
+	if maxDuration > 0 {
+		w.deadline = time.NewTimer(maxDuration)
+	}
+	return w
+}
+
+// Touch 记录一次进度，重新开始计算无进度时间。Stop 之后不再重新计时
+func (w *taskWatchdog) Touch() {
+	if w.stall != nil && !w.stopped {
+		w.stall.Reset(w.stallTimeout)
+	}
+}
+
+// StartLogin 开始等待扫码登录，超过 timeout 后 LoginC 触发；已在等待时不重新计时
+func (w *taskWatchdog) StartLogin(timeout time.Duration) {
+	if w.login != nil || w.stopped || timeout <= 0 {
+		return
+	}
+	w.login = time.NewTimer(timeout)
+}
+
+// EndLogin 登录完成，停止登录计时
+func (w *taskWatchdog) EndLogin() {
+	if w.login != nil {
+		w.login.Stop()
+	}
+}
+
+// LoginC 登录超时通道，未在等待登录时返回 nil (永不触发)
+func (w *taskWatchdog) LoginC() <-chan time.Time {
+	if w.login == nil {
+		return nil
+	}
+	return w.login.C
+}
+
+// StallC 无进度超时通道，未启用时返回 nil (永不触发)
+func (w *taskWatchdog) StallC() <-chan time.Time {
+	if w.stall == nil {
+		return nil
+	}
+	return w.stall.C
+}
+
+// DeadlineC 总时长超时通道，未启用时返回 nil (永不触发)
+func (w *taskWatchdog) DeadlineC() <-chan time.Time {
+	if w.deadline == nil {
+		return nil
+	}
+	return w.deadline.C
+}
+
+// Stop 停止全部计时
+func (w *taskWatchdog) Stop() {
+	w.stopped = true
+	if w.login != nil {
+		w.login.Stop()
+	}
+	if w.stall != nil {
+		w.stall.Stop()
+	}
+	if w.deadline != nil {
+		w.deadline.Stop()
+	}
+}
@@ -0,0 +1,71 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskMaxDuration(t *testing.T) {
+	defer func(kind, role map[string]time.Duration, admins map[int64]bool) {
+		TaskMaxDurationByKind, TaskMaxDurationByRole, AdminUsers = kind, role, admins
+	}(TaskMaxDurationByKind, TaskMaxDurationByRole, AdminUsers)
+	AdminUsers = map[int64]bool{1: true}
+
+	tests := []struct {
+		name string
+		kind map[string]time.Duration
+		role map[string]time.Duration
+		user int64
+		want time.Duration
+	}{
+		{"unlimited", nil, nil, 2, 0},
+		{"role only", nil, map[string]time.Duration{roleUser: time.Hour}, 2, time.Hour},
+		{"kind only", map[string]time.Duration{taskKindForward: 2 * time.Hour}, nil, 2, 2 * time.Hour},
+		{"smaller wins", map[string]time.Duration{taskKindForward: 2 * time.Hour}, map[string]time.Duration{roleUser: time.Hour}, 2, time.Hour},
+		{"admin not limited by user role", nil, map[string]time.Duration{roleUser: time.Hour}, 1, 0},
+	}
+	for _, tt := range tests {
+		TaskMaxDurationByKind, TaskMaxDurationByRole = tt.kind, tt.role
+		if got := taskMaxDuration(&QueuedTask{UserID: tt.user}); got != tt.want {
+			t.Errorf("%s: taskMaxDuration = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWatchdogTouchAfterStop(t *testing.T) {
+	w := newTaskWatchdog(20*time.Millisecond, 0)
+	w.Stop()
+	w.Touch()
+	select {
+	case <-w.StallC():
+		t.Fatal("stall fired after Stop and Touch")
+	case <-time.After(60 * time.Millisecond):
+	}
+}
+
+func TestWatchdogLogin(t *testing.T) {
+	w := newTaskWatchdog(0, 0)
+	defer w.Stop()
+	if w.LoginC() != nil {
+		t.Fatal("LoginC set before StartLogin")
+	}
+	w.StartLogin(20 * time.Millisecond)
+	select {
+	case <-w.LoginC():
+	case <-time.After(time.Second):
+		t.Fatal("login timeout did not fire")
+	}
+
+	w2 := newTaskWatchdog(0, 0)
+	defer w2.Stop()
+	w2.StartLogin(20 * time.Millisecond)
+	w2.EndLogin()
+	select {
+	case <-w2.LoginC():
+		t.Fatal("login timeout fired after EndLogin")
+	case <-time.After(60 * time.Millisecond):
+	}
+}